@@ -18,6 +18,9 @@ const (
 
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum message size allowed from peer.
+	maxMessageSize = 512
 )
 
 // client is a middleman between the websocket connection and the hub.
@@ -31,6 +34,51 @@ type client struct {
 	send chan []byte
 }
 
+// initMessage returns the init message with the full state of the asteroid.
+func initMessage() []byte {
+	initData, _ := json.Marshal(&unityData{
+		Type: INIT,
+		Data: snapshot(),
+	})
+	return initData
+}
+
+// readPump reads the messages from the client until the connection is closed.
+// The client is unregistered if the pong message is not received in time, so the dead clients are removed.
+// The client can send an init message to request the full state again.
+func (c *client) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		_ = c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Error("Failed to read: %v", err)
+			}
+			return
+		}
+
+		var msg clientMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			continue
+		}
+		if msg.Type == INIT {
+			c.hub.direct <- directMessage{client: c, message: initMessage()}
+		}
+	}
+}
+
+// writePump sends the messages in the send channel and the ping messages to the client.
+// The hub closes the send channel when the client is unregistered.
 func (c *client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -38,13 +86,6 @@ func (c *client) writePump() {
 		_ = c.conn.Close()
 	}()
 
-	// Init message
-	initData, _ := json.Marshal(&unityData{
-		Type: INIT,
-		Data: refresh(),
-	})
-	c.send <- initData
-
 	for {
 		select {
 		case message, ok := <-c.send:
@@ -63,15 +104,11 @@ func (c *client) writePump() {
 			_, _ = w.Write(message)
 			if err := w.Close(); err != nil {
 				log.Error("Failed to close: %v", err)
-				delete(hub.clients, c)
-				close(c.send)
 				return
 			}
 		case <-ticker.C:
 			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				delete(hub.clients, c)
-				close(c.send)
 				return
 			}
 		}
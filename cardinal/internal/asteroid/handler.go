@@ -7,10 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// GetAsteroidStatus returns the full state of the asteroid, which is the same as the init message sent to the client.
 func GetAsteroidStatus(c *gin.Context) (int, interface{}) {
-	return utils.MakeSuccessJSON(gin.H{
-		// TODO
-	})
+	return utils.MakeSuccessJSON(snapshot())
 }
 
 func Attack(c *gin.Context) (int, interface{}) {
@@ -1,8 +1,14 @@
 package asteroid
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"net/http"
+	"strings"
+
+	"Cardinal/internal/dynamic_config"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -22,6 +28,15 @@ type Hub struct {
 
 	// Unregister requests from clients.
 	unregister chan *client
+
+	// Messages to a single client.
+	direct chan directMessage
+}
+
+// directMessage is a message which is only sent to the given client.
+type directMessage struct {
+	client  *client
+	message []byte
 }
 
 func newHub() *Hub {
@@ -29,6 +44,7 @@ func newHub() *Hub {
 		broadcast:  make(chan []byte),
 		register:   make(chan *client),
 		unregister: make(chan *client),
+		direct:     make(chan directMessage),
 		clients:    make(map[*client]bool),
 	}
 }
@@ -48,6 +64,17 @@ func (h *Hub) run() {
 				delete(h.clients, client)
 				close(client.send)
 			}
+		case direct := <-h.direct:
+			// The client may have been unregistered, and its send channel is closed.
+			if _, ok := h.clients[direct.client]; !ok {
+				continue
+			}
+			select {
+			case direct.client.send <- direct.message:
+			default:
+				close(direct.client.send)
+				delete(h.clients, direct.client)
+			}
 		case message := <-h.broadcast:
 			for client := range h.clients {
 				select {
@@ -61,11 +88,34 @@ func (h *Hub) run() {
 	}
 }
 
+// authorized checks the token of the websocket request if the asteroid token is set.
+// The token can be given in the `token` query, as the browsers can not set the header of the websocket request.
+func authorized(r *http.Request) bool {
+	token := dynamic_config.Get(utils.ASTEROID_TOKEN)
+	if token == "" {
+		return true
+	}
+
+	requestToken := r.URL.Query().Get("token")
+	if requestToken == "" {
+		requestToken = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) == 1
+}
+
 func (h *Hub) serve(c *gin.Context) {
+	if !authorized(c.Request) {
+		c.JSON(utils.MakeErrJSON(401, 40103,
+			locales.I18n.T(c.GetString("lang"), "general.no_auth"),
+		))
+		return
+	}
+
 	// Upgrade the request to websocket.
 	var upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
-			return true // Ignore origin checking.
+			// The Unity3D client is not served by Cardinal, the access is controlled by the asteroid token.
+			return true
 		},
 	}
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -74,14 +124,25 @@ func (h *Hub) serve(c *gin.Context) {
 		return
 	}
 	client := &client{hub: h, conn: conn, send: make(chan []byte, 256)}
+
+	// The init message is queued before the client is registered, so that it is sent before any broadcast.
+	client.send <- initMessage()
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
 	go client.writePump()
+	go client.readPump()
 }
 
+// sendMessage records the message and broadcasts it to the clients.
+// The message is not broadcast during the replay, or the clients will mix it with the replayed ones.
 func (h *Hub) sendMessage(messageType string, data interface{}) {
+	record(messageType, data)
+	if replaying() {
+		return
+	}
+
 	jsonData, _ := json.Marshal(&unityData{
 		Type: messageType,
 		Data: data,
@@ -1,15 +1,16 @@
 package asteroid
 
 const (
-	INIT      = "init"
-	ATTACK    = "attack"
-	RANK      = "rank"
-	STATUS    = "status"
-	ROUND     = "round"
-	EGG       = "easterEgg"
-	TIME      = "time"
-	CLEAR     = "clear"
-	CLEAR_ALL = "clearAll"
+	INIT        = "init"
+	ATTACK      = "attack"
+	FIRST_BLOOD = "firstBlood"
+	RANK        = "rank"
+	STATUS      = "status"
+	ROUND       = "round"
+	EGG         = "easterEgg"
+	TIME        = "time"
+	CLEAR       = "clear"
+	CLEAR_ALL   = "clearAll"
 )
 
 var hub *Hub
@@ -27,6 +28,10 @@ func Init(function func() Greet) {
 // NewRoundAction runs in the new round begin.
 // Refresh rank, clean all gameboxes' status, set round text, set time text.
 func NewRoundAction() {
+	if hub == nil || refresh == nil {
+		return
+	}
+
 	sendRank()
 	sendClearAll()
 	sendRound(refresh().Round)
@@ -43,6 +48,15 @@ func SendAttack(from int, to int) {
 	sendAttack(from, to)
 }
 
+// SendFirstBlood sends a first blood message.
+func SendFirstBlood(from int, to int, challenge string) {
+	hub.sendMessage(FIRST_BLOOD, firstBlood{
+		From:      from,
+		To:        to,
+		Challenge: challenge,
+	})
+}
+
 // sendAttack sends an attack action message.
 func sendAttack(from int, to int) {
 	hub.sendMessage(ATTACK, attack{
@@ -58,6 +72,7 @@ func sendRank() {
 
 // sendStatus sends the teams' status message.
 func sendStatus(team int, statusString string) {
+	setStatus(team, statusString)
 	hub.sendMessage(STATUS, status{
 		Id:     team,
 		Status: statusString,
@@ -66,6 +81,7 @@ func sendStatus(team int, statusString string) {
 
 // sendRound sends now round.
 func sendRound(roundNumber int) {
+	setRound(roundNumber)
 	hub.sendMessage(ROUND, round{Round: roundNumber})
 }
 
@@ -81,10 +97,12 @@ func sendTime(time int) {
 
 // sendClear removes the status of the team.
 func sendClear(team int) {
+	removeStatus(team)
 	hub.sendMessage(CLEAR, clearStatus{Id: team})
 }
 
 // sendClearAll removes all the teams' status.
 func sendClearAll() {
+	removeAllStatus()
 	hub.sendMessage(CLEAR_ALL, nil)
 }
@@ -16,6 +16,18 @@ type Team struct {
 	Score int
 }
 
+// initData is the full state sent to the client when it connects.
+type initData struct {
+	Greet
+	// Status is the current status of the teams, the teams which have no status are omitted.
+	Status []status
+}
+
+// clientMessage is the message sent by the client through the websocket.
+type clientMessage struct {
+	Type string
+}
+
 type unityData struct {
 	Type string
 	Data interface{}
@@ -26,6 +38,12 @@ type attack struct {
 	To   int
 }
 
+type firstBlood struct {
+	From      int
+	To        int
+	Challenge string
+}
+
 type rank struct {
 	Team []Team
 }
@@ -0,0 +1,294 @@
+package asteroid
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	log "unknwon.dev/clog/v2"
+)
+
+const (
+	// maxReplayGap is the maximum interval between two replayed messages,
+	// so that the pauses of the game are skipped.
+	maxReplayGap = 10 * time.Second
+	// maxReplaySpeed is the maximum speed multiplier of the replay.
+	maxReplaySpeed = 1000
+)
+
+// ReplayStatus is the status of the replay.
+type ReplayStatus struct {
+	Replaying bool
+	Speed     float64
+	// Round is the round of the last replayed message.
+	Round int
+	// Played is the number of the replayed messages, Total is the number of the messages to be replayed.
+	Played int
+	Total  int
+}
+
+var replayer = struct {
+	sync.Mutex
+	status ReplayStatus
+	stop   chan struct{}
+}{}
+
+// replaying returns whether the recorded game is being replayed.
+func replaying() bool {
+	replayer.Lock()
+	defer replayer.Unlock()
+	return replayer.status.Replaying
+}
+
+// record saves the broadcast message, the messages are replayed in the order they are saved.
+func record(messageType string, data interface{}) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		log.Error("Failed to marshal the asteroid message: %v", err)
+		return
+	}
+
+	if err := dbold.MySQL.Create(&dbold.AsteroidEvent{
+		Round: currentRound(),
+		Type:  messageType,
+		Data:  string(jsonData),
+	}).Error; err != nil {
+		log.Error("Failed to record the asteroid message: %v", err)
+	}
+}
+
+// replayMessage returns the message of the recorded event which is the same as the broadcast one.
+func replayMessage(event dbold.AsteroidEvent) []byte {
+	message, _ := json.Marshal(&struct {
+		Type string
+		Data json.RawMessage
+	}{
+		Type: event.Type,
+		Data: json.RawMessage(event.Data),
+	})
+	return message
+}
+
+// applyReplayed updates the replay state with the replayed event, so that the clients connected during the replay
+// receive the state of the replayed game. The state of the live game is not changed.
+func applyReplayed(event dbold.AsteroidEvent) {
+	state.Lock()
+	defer state.Unlock()
+	if state.replay == nil {
+		return
+	}
+
+	switch event.Type {
+	case STATUS:
+		var s status
+		if err := json.Unmarshal([]byte(event.Data), &s); err == nil {
+			setTeamStatus(state.replayStatus, s.Id, s.Status)
+		}
+	case CLEAR:
+		var s clearStatus
+		if err := json.Unmarshal([]byte(event.Data), &s); err == nil {
+			delete(state.replayStatus, s.Id)
+		}
+	case CLEAR_ALL:
+		state.replayStatus = make(map[int]string)
+	case RANK:
+		var r rank
+		if err := json.Unmarshal([]byte(event.Data), &r); err == nil {
+			state.replay.Team = r.Team
+		}
+	case ROUND:
+		var r round
+		if err := json.Unmarshal([]byte(event.Data), &r); err == nil {
+			state.replay.Round = r.Round
+		}
+	case TIME:
+		var t clock
+		if err := json.Unmarshal([]byte(event.Data), &t); err == nil {
+			state.replay.Time = t.Time
+		}
+	}
+}
+
+// startReplay replays the recorded messages from the given round at the speed multiplier.
+// It returns false if the game is being replayed or no message is recorded since the round.
+func startReplay(speed float64, fromRound int) bool {
+	var events []dbold.AsteroidEvent
+	dbold.MySQL.Model(&dbold.AsteroidEvent{}).Order("id").Find(&events)
+
+	// Seek to the first message of the round.
+	start := len(events)
+	for i, event := range events {
+		if event.Round >= fromRound {
+			start = i
+			break
+		}
+	}
+	if start == len(events) {
+		return false
+	}
+
+	replayer.Lock()
+	if replayer.status.Replaying {
+		replayer.Unlock()
+		return false
+	}
+	replayer.status = ReplayStatus{
+		Replaying: true,
+		Speed:     speed,
+		Round:     events[start].Round,
+		Total:     len(events) - start,
+	}
+	stop := make(chan struct{})
+	replayer.stop = stop
+	replayer.Unlock()
+
+	var title string
+	if refresh != nil {
+		title = refresh().Title
+	}
+	state.Lock()
+	state.replay = &Greet{Title: title, Round: events[start].Round}
+	state.replayStatus = make(map[int]string)
+	state.Unlock()
+
+	// The ranking list before the round is sent first, as it is only sent when a round begins.
+	seekEvents := make([]dbold.AsteroidEvent, 0, 2)
+	seekEvents = append(seekEvents, dbold.AsteroidEvent{Type: CLEAR_ALL, Data: "null"})
+	for i := start - 1; i >= 0; i-- {
+		if events[i].Type == RANK {
+			seekEvents = append(seekEvents, events[i])
+			break
+		}
+	}
+	for _, event := range seekEvents {
+		applyReplayed(event)
+		hub.broadcast <- replayMessage(event)
+	}
+
+	go replay(events[start:], speed, stop)
+	return true
+}
+
+// replay broadcasts the events with the recorded intervals divided by the speed until it is stopped.
+func replay(events []dbold.AsteroidEvent, speed float64, stop chan struct{}) {
+	defer finishReplay(stop)
+
+	for i, event := range events {
+		if i > 0 {
+			gap := time.Duration(float64(event.CreatedAt.Sub(events[i-1].CreatedAt)) / speed)
+			if gap > maxReplayGap {
+				gap = maxReplayGap
+			}
+
+			timer := time.NewTimer(gap)
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+		}
+
+		applyReplayed(event)
+		hub.broadcast <- replayMessage(event)
+
+		replayer.Lock()
+		replayer.status.Round = event.Round
+		replayer.status.Played = i + 1
+		replayer.Unlock()
+	}
+}
+
+// finishReplay drops the state of the replayed game, and sends the live one to the clients.
+func finishReplay(stop chan struct{}) {
+	replayer.Lock()
+	if replayer.stop != stop {
+		replayer.Unlock()
+		return
+	}
+	replayer.status.Replaying = false
+	replayer.stop = nil
+	replayer.Unlock()
+
+	state.Lock()
+	state.replay = nil
+	state.replayStatus = nil
+	state.Unlock()
+
+	hub.broadcast <- initMessage()
+}
+
+// stopReplay stops the replay, it returns false if the game is not being replayed.
+func stopReplay() bool {
+	replayer.Lock()
+	defer replayer.Unlock()
+
+	if !replayer.status.Replaying || replayer.stop == nil {
+		return false
+	}
+	close(replayer.stop)
+	return true
+}
+
+// GetReplayStatus returns the status of the replay.
+func GetReplayStatus(c *gin.Context) (int, interface{}) {
+	replayer.Lock()
+	defer replayer.Unlock()
+	return utils.MakeSuccessJSON(replayer.status)
+}
+
+// Replay replays the recorded game to the clients at the given speed from the given round.
+func Replay(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		Speed float64
+		Round int
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40065, locales.I18n.T(c.GetString("lang"), "general.error_payload"))
+	}
+	if inputForm.Speed == 0 {
+		inputForm.Speed = 1
+	}
+	if inputForm.Speed < 0 || inputForm.Speed > maxReplaySpeed {
+		return utils.MakeErrJSON(400, 40066, locales.I18n.T(c.GetString("lang"), "asteroid.error_speed", gin.H{"max": maxReplaySpeed}))
+	}
+
+	if replaying() {
+		return utils.MakeErrJSON(400, 40067, locales.I18n.T(c.GetString("lang"), "asteroid.replaying"))
+	}
+	if !startReplay(inputForm.Speed, inputForm.Round) {
+		return utils.MakeErrJSON(404, 40408, locales.I18n.T(c.GetString("lang"), "asteroid.no_event"))
+	}
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "asteroid.replay_started"))
+}
+
+// StopReplay stops the replay, and the clients return to the live game.
+func StopReplay(c *gin.Context) (int, interface{}) {
+	if !stopReplay() {
+		return utils.MakeErrJSON(400, 40068, locales.I18n.T(c.GetString("lang"), "asteroid.not_replaying"))
+	}
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "asteroid.replay_stopped"))
+}
+
+// GetEvents returns all the recorded messages, it can be published to replay the game offline.
+func GetEvents(c *gin.Context) (int, interface{}) {
+	var events []dbold.AsteroidEvent
+	dbold.MySQL.Model(&dbold.AsteroidEvent{}).Order("id").Find(&events)
+
+	result := make([]gin.H, 0, len(events))
+	for _, event := range events {
+		result = append(result, gin.H{
+			"ID":        event.ID,
+			"CreatedAt": event.CreatedAt,
+			"Round":     event.Round,
+			"Type":      event.Type,
+			"Data":      json.RawMessage(event.Data),
+		})
+	}
+	return utils.MakeSuccessJSON(result)
+}
@@ -0,0 +1,101 @@
+package asteroid
+
+import (
+	"sort"
+	"sync"
+)
+
+// state is the team status which has been sent to the clients, it is sent to the newly connected client.
+// It is cleared when a new round begins, as the clients do.
+var state = struct {
+	sync.RWMutex
+	status map[int]string
+	// round is the current round, it is recorded with the messages.
+	round int
+	// replay is the title, time, round and rank of the replayed game, it is nil if the game is not being replayed.
+	replay *Greet
+	// replayStatus is the team status of the replayed game, the live status is still updated during the replay.
+	replayStatus map[int]string
+}{
+	status: make(map[int]string),
+}
+
+func setStatus(team int, statusString string) {
+	state.Lock()
+	defer state.Unlock()
+	setTeamStatus(state.status, team, statusString)
+}
+
+func setTeamStatus(teamStatus map[int]string, team int, statusString string) {
+	// The down status is not overwritten by the attacked one in the same round.
+	if teamStatus[team] == "down" && statusString != "down" {
+		return
+	}
+	teamStatus[team] = statusString
+}
+
+func removeStatus(team int) {
+	state.Lock()
+	defer state.Unlock()
+	delete(state.status, team)
+}
+
+func removeAllStatus() {
+	state.Lock()
+	defer state.Unlock()
+	state.status = make(map[int]string)
+}
+
+// currentStatus returns the status of the teams ordered by the team ID.
+// The status of the replayed game is returned during the replay.
+func currentStatus() []status {
+	state.RLock()
+	defer state.RUnlock()
+
+	current := state.status
+	if state.replay != nil {
+		current = state.replayStatus
+	}
+
+	teamStatus := make([]status, 0, len(current))
+	for id, statusString := range current {
+		teamStatus = append(teamStatus, status{Id: id, Status: statusString})
+	}
+	sort.Slice(teamStatus, func(i, j int) bool { return teamStatus[i].Id < teamStatus[j].Id })
+	return teamStatus
+}
+
+func setRound(round int) {
+	state.Lock()
+	defer state.Unlock()
+	state.round = round
+}
+
+func currentRound() int {
+	state.RLock()
+	defer state.RUnlock()
+	return state.round
+}
+
+// snapshot returns the full state of the asteroid, which is the data of the init message.
+// The state of the replayed game is returned during the replay.
+func snapshot() initData {
+	state.RLock()
+	replay := state.replay
+	if replay != nil {
+		replayGreet := *replay
+		replay = &replayGreet
+	}
+	state.RUnlock()
+
+	var greet Greet
+	if replay != nil {
+		greet = *replay
+	} else if refresh != nil {
+		greet = refresh()
+	}
+	return initData{
+		Greet:  greet,
+		Status: currentStatus(),
+	}
+}
@@ -76,3 +76,14 @@ func ManagerRequired() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// QueryToken uses the `token` query as the Authorization header if the header is not set,
+// as the EventSource of the browsers can not set the header.
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.Query("token") != "" {
+			c.Request.Header.Set("Authorization", c.Query("token"))
+		}
+		c.Next()
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"Cardinal/internal/dbold"
 	"Cardinal/internal/locales"
 	"Cardinal/internal/logger"
+	"Cardinal/internal/misc/webhook"
 	"Cardinal/internal/utils"
 )
 
@@ -42,6 +43,8 @@ func TeamLogin(c *gin.Context) (int, interface{}) {
 			)
 		}
 		tx.Commit()
+
+		webhook.Add(webhook.TEAM_LOGIN_HOOK, gin.H{"team": team.ID})
 		return utils.MakeSuccessJSON(token)
 	}
 	return utils.MakeErrJSON(403, 40301,
@@ -32,6 +32,9 @@ func LinkStart() {
 		log.Fatal("Failed to load configuration file: %v", err)
 	}
 
+	// Rehearsal
+	conf.InitClock()
+
 	// Check version
 	misc.CheckVersion()
 
@@ -57,6 +60,8 @@ func LinkStart() {
 	// Cache
 	store.Init()
 	webhook.RefreshWebHookStore()
+	webhook.StartDispatcher()
+	ConfigToWebHookBridge()
 
 	// Unity3D Asteroid
 	asteroid.Init(game.AsteroidGreetData)
@@ -1,7 +1,9 @@
 package bootstrap
 
 import (
+	"Cardinal/internal/dynamic_config"
 	"Cardinal/internal/game"
+	"Cardinal/internal/misc/webhook"
 	"Cardinal/internal/timer"
 )
 
@@ -9,7 +11,14 @@ func GameToTimerBridge() {
 	timer.SetRankListTitle = game.SetRankListTitle
 	timer.SetRankList = game.SetRankList
 	timer.CleanGameBoxStatus = game.CleanGameBoxStatus
-	timer.GetLatestScoreRound = game.GetLatestScoreRound
 	timer.RefreshFlag = game.RefreshFlag
 	timer.CalculateRoundScore = game.CalculateRoundScore
 }
+
+// ConfigToWebHookBridge sends the webhook when the dynamic config is changed,
+// the dynamic config package can not import the webhook package which depends on it.
+func ConfigToWebHookBridge() {
+	dynamic_config.OnConfigChanged = func(keys []string) {
+		webhook.Add(webhook.CONFIG_CHANGED_HOOK, map[string]interface{}{"keys": keys})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"strconv"
 
 	"Cardinal/internal/dbold"
+	"Cardinal/internal/livelog"
 	"Cardinal/internal/locales"
+	"Cardinal/internal/misc/webhook"
 	"Cardinal/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -32,17 +34,23 @@ func NewBulletin(c *gin.Context) (int, interface{}) {
 		)
 	}
 
-	tx := dbold.MySQL.Begin()
-	if tx.Create(&dbold.Bulletin{
+	bulletin := &dbold.Bulletin{
 		Title:   inputForm.Title,
 		Content: inputForm.Content,
-	}).RowsAffected != 1 {
+	}
+	tx := dbold.MySQL.Begin()
+	if tx.Create(bulletin).RowsAffected != 1 {
 		tx.Rollback()
 		return utils.MakeErrJSON(500, 50019,
 			locales.I18n.T(c.GetString("lang"), "bulletin.post_error"),
 		)
 	}
 	tx.Commit()
+
+	webhook.Add(webhook.BULLETIN_HOOK, gin.H{"bulletin": bulletin.ID, "title": bulletin.Title})
+	go func() {
+		_ = livelog.WriteAllTeams(livelog.NewLine("bulletin", gin.H{"ID": bulletin.ID, "Title": bulletin.Title}))
+	}()
 	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "bulletin.post_success"))
 }
 
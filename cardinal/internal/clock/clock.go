@@ -5,7 +5,8 @@
 package clock
 
 import (
-	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,15 +26,22 @@ const (
 var T = new(Clock)
 
 type Clock struct {
-	StartAt             time.Time
-	EndAt               time.Time
-	RoundDuration       time.Duration
-	RestTime            [][]time.Time
-	RunTime             [][]time.Time
-	TotalRound          uint
-	CurrentRound        uint
-	RoundRemainDuration time.Duration
-	Status              Status
+	StartAt       time.Time
+	EndAt         time.Time
+	RoundDuration time.Duration
+	RestTime      [][]time.Time
+	RunTime       [][]time.Time
+	TotalRound    uint
+	// Phases are the phases of the game schedule with the rounds calculated from the run time.
+	Phases []*Phase
+
+	// snapshot is the latest *Snapshot published by the clock processor.
+	snapshot atomic.Value
+
+	// mu protects the time fields calculated by schedule.
+	mu sync.RWMutex
+	// configPhases are the phases from the configuration file.
+	configPhases []*Phase
 
 	stopChan chan struct{}
 }
@@ -47,6 +55,7 @@ func Init() error {
 		StartAt:       conf.Game.StartAt.In(time.Local),
 		EndAt:         conf.Game.EndAt.In(time.Local),
 		RoundDuration: time.Duration(conf.Game.RoundDuration) * time.Minute,
+		stopChan:      make(chan struct{}),
 	}
 
 	restTime := make([][]time.Time, 0, len(conf.Game.PauseTime))
@@ -54,37 +63,59 @@ func Init() error {
 		restTime = append(restTime, []time.Time{t.StartAt.In(time.Local), t.EndAt.In(time.Local)})
 	}
 	T.RestTime = restTime
+	T.configPhases = configPhases(T.StartAt, T.RoundDuration)
 
 	// Check timer configuration.
 	if err := T.checkConfig(); err != nil {
 		return errors.Wrap(err, "check config")
 	}
+	if err := T.checkPhases(); err != nil {
+		return errors.Wrap(err, "check phases")
+	}
 
 	T.RestTime = combineDuration(T.RestTime)
+	T.schedule()
+
+	return nil
+}
+
+// schedule calculates the rest time, run time, phases and total round from the time configuration.
+func (c *Clock) schedule() {
+	endAt := c.EndAt
+	restTime := c.RestTime
 
 	// Set competition run time cycle.
-	if len(T.RestTime) != 0 {
+	runTime := make([][]time.Time, 0, len(restTime)+1)
+	if len(restTime) != 0 {
 		// StartAt -> RestTime[0][Start]
-		T.RunTime = append(T.RunTime, []time.Time{T.StartAt, T.RestTime[0][0]})
-		for i := 0; i < len(T.RestTime)-1; i++ {
+		runTime = append(runTime, []time.Time{c.StartAt, restTime[0][0]})
+		for i := 0; i < len(restTime)-1; i++ {
 			// Runtime = RestHeadEnd -> RestNextBegin
-			T.RunTime = append(T.RunTime, []time.Time{T.RestTime[i][1], T.RestTime[i+1][0]})
+			runTime = append(runTime, []time.Time{restTime[i][1], restTime[i+1][0]})
 		}
 		// RestTime[Last][End] -> EndAt
-		T.RunTime = append(T.RunTime, []time.Time{T.RestTime[len(T.RestTime)-1][1], T.EndAt})
+		runTime = append(runTime, []time.Time{restTime[len(restTime)-1][1], endAt})
 
 	} else {
-		T.RunTime = append(T.RunTime, []time.Time{T.StartAt, T.EndAt})
+		runTime = append(runTime, []time.Time{c.StartAt, endAt})
 	}
 
-	// Calculate total round count.
-	var totalTime time.Duration
-	for _, duration := range T.RunTime {
-		totalTime += duration[1].Sub(duration[0])
+	// Calculate the rounds of each phase and the total round count.
+	phaseConfig := c.configPhases
+	if len(phaseConfig) == 0 {
+		phaseConfig = []*Phase{{StartAt: c.StartAt, RoundDuration: c.RoundDuration, ScoreMultiplier: 1}}
+	}
+	phases := schedulePhases(phaseConfig, runTime)
+	var totalRound uint
+	for _, phase := range phases {
+		totalRound += phase.TotalRound
 	}
-	T.TotalRound = uint(math.Ceil(totalTime.Minutes() / T.RoundDuration.Minutes()))
 
-	return nil
+	c.mu.Lock()
+	c.RunTime = runTime
+	c.Phases = phases
+	c.TotalRound = totalRound
+	c.mu.Unlock()
 }
 
 // checkConfig checks the time configuration from the configuration file.
@@ -160,6 +191,7 @@ func combineDuration(d [][]time.Time) [][]time.Time {
 	for i := 0; i < len(d); i++ {
 		if d[i] == nil {
 			d = append(d[:i], d[i+1:]...)
+			i--
 		}
 	}
 
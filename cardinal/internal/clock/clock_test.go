@@ -5,6 +5,7 @@
 package clock
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -143,6 +144,17 @@ func Test_combineDuration(t *testing.T) {
 				{date(2021, 10, 4, 13, 0, 0), date(2021, 10, 5, 8, 0, 0)},
 			},
 		},
+		{
+			name: "former includes the latter two",
+			durations: [][]time.Time{
+				{date(2021, 10, 3, 20, 0, 0), date(2021, 10, 4, 20, 0, 0)},
+				{date(2021, 10, 4, 0, 0, 0), date(2021, 10, 4, 8, 0, 0)},
+				{date(2021, 10, 4, 1, 0, 0), date(2021, 10, 4, 2, 0, 0)},
+			},
+			want: [][]time.Time{
+				{date(2021, 10, 3, 20, 0, 0), date(2021, 10, 4, 20, 0, 0)},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			got := combineDuration(tc.durations)
@@ -151,6 +163,276 @@ func Test_combineDuration(t *testing.T) {
 	}
 }
 
+func Test_schedule(t *testing.T) {
+	c := &Clock{
+		StartAt:       date(2021, 10, 3, 12, 0, 0),
+		RoundDuration: time.Hour,
+		EndAt:         date(2021, 10, 4, 12, 0, 0),
+		RestTime: [][]time.Time{
+			{date(2021, 10, 3, 20, 0, 0), date(2021, 10, 4, 8, 0, 0)},
+		},
+	}
+	c.schedule()
+
+	assert.Equal(t, date(2021, 10, 4, 12, 0, 0), c.EndAt)
+	assert.Equal(t, [][]time.Time{
+		{date(2021, 10, 3, 12, 0, 0), date(2021, 10, 3, 20, 0, 0)},
+		{date(2021, 10, 4, 8, 0, 0), date(2021, 10, 4, 12, 0, 0)},
+	}, c.RunTime)
+	assert.Equal(t, uint(12), c.TotalRound)
+}
+
+func Test_moment(t *testing.T) {
+	c := &Clock{
+		StartAt:       date(2021, 10, 3, 12, 0, 0),
+		RoundDuration: time.Hour,
+		EndAt:         date(2021, 10, 4, 12, 0, 0),
+		RestTime: [][]time.Time{
+			{date(2021, 10, 3, 20, 30, 0), date(2021, 10, 4, 8, 0, 0)},
+		},
+	}
+	c.schedule()
+	assert.Equal(t, uint(13), c.TotalRound)
+	phase := c.Phases[0]
+
+	for _, tc := range []struct {
+		name string
+		at   time.Time
+		want moment
+	}{
+		{
+			name: "not started",
+			at:   date(2021, 10, 3, 11, 0, 0),
+			want: moment{phase: phase, status: StatusWait, next: date(2021, 10, 3, 12, 0, 0)},
+		},
+		{
+			name: "first round",
+			at:   date(2021, 10, 3, 12, 0, 0),
+			want: moment{phase: phase, status: StatusRunning, round: 1, roundRemain: time.Hour, next: date(2021, 10, 3, 13, 0, 0)},
+		},
+		{
+			name: "in the middle of round",
+			at:   date(2021, 10, 3, 12, 20, 0),
+			want: moment{phase: phase, status: StatusRunning, round: 1, roundRemain: 40 * time.Minute, next: date(2021, 10, 3, 13, 0, 0)},
+		},
+		{
+			name: "suspended before the round ends",
+			at:   date(2021, 10, 3, 20, 0, 0),
+			want: moment{phase: phase, status: StatusRunning, round: 9, roundRemain: time.Hour, next: date(2021, 10, 3, 20, 30, 0)},
+		},
+		{
+			name: "paused",
+			at:   date(2021, 10, 3, 22, 0, 0),
+			want: moment{phase: phase, status: StatusPause, round: 9, roundRemain: 30 * time.Minute, next: date(2021, 10, 4, 8, 0, 0)},
+		},
+		{
+			name: "resumed",
+			at:   date(2021, 10, 4, 8, 0, 0),
+			want: moment{phase: phase, status: StatusRunning, round: 9, roundRemain: 30 * time.Minute, next: date(2021, 10, 4, 8, 30, 0)},
+		},
+		{
+			name: "last round",
+			at:   date(2021, 10, 4, 11, 40, 0),
+			want: moment{phase: phase, status: StatusRunning, round: 13, roundRemain: 20 * time.Minute, next: date(2021, 10, 4, 12, 0, 0)},
+		},
+		{
+			name: "game over",
+			at:   date(2021, 10, 4, 12, 0, 0),
+			want: moment{phase: phase, status: StatusEnd, round: 13},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.want.at = tc.at
+			tc.want.startAt, tc.want.endAt, tc.want.totalRound = c.StartAt, c.EndAt, c.TotalRound
+			assert.Equal(t, tc.want, c.moment(tc.at))
+		})
+	}
+}
+
+func Test_transit(t *testing.T) {
+	events := Events
+	defer func() { Events = events }()
+
+	Events = NewBus()
+	var got []Event
+	Subscribe(func(ctx context.Context, event Event) {
+		got = append(got, Event{Type: event.Type, Round: event.Round})
+	}, EventGameStart, EventRoundStart, EventRoundEnd, EventPause, EventResume, EventGameEnd)
+
+	c := &Clock{}
+	for _, m := range []moment{
+		{status: StatusWait},
+		{status: StatusRunning, round: 1},
+		{status: StatusRunning, round: 1},
+		{status: StatusRunning, round: 2},
+		{status: StatusPause, round: 2},
+		{status: StatusRunning, round: 3},
+		{status: StatusRunning, round: 5},
+		{status: StatusEnd, round: 5},
+		{status: StatusEnd, round: 5},
+	} {
+		c.transit(context.Background(), m)
+	}
+
+	want := []Event{
+		{Type: EventGameStart, Round: 1},
+		{Type: EventRoundStart, Round: 1},
+		{Type: EventRoundEnd, Round: 1},
+		{Type: EventRoundStart, Round: 2},
+		{Type: EventPause, Round: 2},
+		{Type: EventRoundEnd, Round: 2},
+		{Type: EventRoundStart, Round: 3},
+		{Type: EventResume, Round: 3},
+		{Type: EventRoundEnd, Round: 3},
+		{Type: EventRoundEnd, Round: 4},
+		{Type: EventRoundStart, Round: 5},
+		{Type: EventRoundEnd, Round: 5},
+		{Type: EventGameEnd, Round: 5},
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, StatusEnd, c.Snapshot().Status)
+	assert.Equal(t, uint(5), c.Snapshot().Round)
+
+	// The rounds ended before the clock processor started are not published.
+	got = nil
+	c = &Clock{}
+	c.transit(context.Background(), moment{status: StatusRunning, round: 3})
+	assert.Equal(t, []Event{{Type: EventRoundStart, Round: 3}}, got)
+}
+
+func TestSnapshot_RoundRemainDuration(t *testing.T) {
+	at := date(2021, 10, 3, 12, 20, 0)
+	running := &Snapshot{At: at, Status: StatusRunning, roundRemain: 40 * time.Minute}
+	assert.Equal(t, 30*time.Minute, running.RoundRemainDuration(at.Add(10*time.Minute)))
+	assert.Equal(t, time.Duration(0), running.RoundRemainDuration(at.Add(time.Hour)))
+
+	paused := &Snapshot{At: at, Status: StatusPause, roundRemain: 40 * time.Minute}
+	assert.Equal(t, 40*time.Minute, paused.RoundRemainDuration(at.Add(10*time.Minute)))
+}
+
+func Test_roundTime(t *testing.T) {
+	c := &Clock{
+		StartAt:       date(2021, 10, 3, 12, 0, 0),
+		RoundDuration: time.Hour,
+		EndAt:         date(2021, 10, 4, 12, 40, 0),
+		RestTime: [][]time.Time{
+			{date(2021, 10, 3, 20, 0, 0), date(2021, 10, 4, 8, 0, 0)},
+			{date(2021, 10, 4, 11, 30, 0), date(2021, 10, 4, 11, 40, 0)},
+		},
+	}
+	c.schedule()
+
+	for _, tc := range []struct {
+		name        string
+		round       uint
+		wantStartAt time.Time
+		wantEndAt   time.Time
+	}{
+		{
+			name:        "first round",
+			round:       1,
+			wantStartAt: date(2021, 10, 3, 12, 0, 0),
+			wantEndAt:   date(2021, 10, 3, 13, 0, 0),
+		},
+		{
+			name:        "round ends at the rest time",
+			round:       8,
+			wantStartAt: date(2021, 10, 3, 19, 0, 0),
+			wantEndAt:   date(2021, 10, 3, 20, 0, 0),
+		},
+		{
+			name:        "round starts after the rest time",
+			round:       9,
+			wantStartAt: date(2021, 10, 4, 8, 0, 0),
+			wantEndAt:   date(2021, 10, 4, 9, 0, 0),
+		},
+		{
+			name:        "round across the rest time",
+			round:       12,
+			wantStartAt: date(2021, 10, 4, 11, 0, 0),
+			wantEndAt:   date(2021, 10, 4, 12, 10, 0),
+		},
+		{
+			name:        "last round",
+			round:       13,
+			wantStartAt: date(2021, 10, 4, 12, 10, 0),
+			wantEndAt:   date(2021, 10, 4, 12, 40, 0),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			startAt, endAt := c.roundTime(tc.round)
+			assert.Equal(t, tc.wantStartAt, startAt)
+			assert.Equal(t, tc.wantEndAt, endAt)
+		})
+	}
+}
+
+func Test_phases(t *testing.T) {
+	c := &Clock{
+		StartAt:       date(2021, 10, 3, 12, 0, 0),
+		RoundDuration: time.Hour,
+		EndAt:         date(2021, 10, 3, 18, 0, 0),
+		RestTime: [][]time.Time{
+			{date(2021, 10, 3, 14, 0, 0), date(2021, 10, 3, 15, 0, 0)},
+		},
+		configPhases: []*Phase{
+			{StartAt: date(2021, 10, 3, 12, 0, 0), RoundDuration: time.Hour, ScoreMultiplier: 1},
+			{Name: "final", StartAt: date(2021, 10, 3, 16, 30, 0), RoundDuration: 30 * time.Minute, ScoreMultiplier: 2},
+		},
+	}
+	c.schedule()
+
+	assert.Equal(t, uint(7), c.TotalRound)
+	assert.Equal(t, 2, len(c.Phases))
+	assert.Equal(t, uint(1), c.Phases[0].StartRound)
+	assert.Equal(t, uint(4), c.Phases[0].TotalRound)
+	assert.Equal(t, uint(5), c.Phases[1].StartRound)
+	assert.Equal(t, uint(3), c.Phases[1].TotalRound)
+
+	t.Run("moment", func(t *testing.T) {
+		for _, tc := range []struct {
+			at   time.Time
+			want moment
+		}{
+			{
+				at:   date(2021, 10, 3, 14, 30, 0),
+				want: moment{status: StatusPause, round: 2, phase: c.Phases[0], next: date(2021, 10, 3, 15, 0, 0)},
+			},
+			{
+				at:   date(2021, 10, 3, 16, 10, 0),
+				want: moment{status: StatusRunning, round: 4, phase: c.Phases[0], roundRemain: 20 * time.Minute, next: date(2021, 10, 3, 16, 30, 0)},
+			},
+			{
+				at:   date(2021, 10, 3, 16, 30, 0),
+				want: moment{status: StatusRunning, round: 5, phase: c.Phases[1], roundRemain: 30 * time.Minute, next: date(2021, 10, 3, 17, 0, 0)},
+			},
+			{
+				at:   date(2021, 10, 3, 18, 0, 0),
+				want: moment{status: StatusEnd, round: 7, phase: c.Phases[1]},
+			},
+		} {
+			tc.want.at = tc.at
+			tc.want.startAt, tc.want.endAt, tc.want.totalRound = c.StartAt, c.EndAt, c.TotalRound
+			assert.Equal(t, tc.want, c.moment(tc.at))
+		}
+	})
+
+	t.Run("round time", func(t *testing.T) {
+		startAt, endAt := c.roundTime(4)
+		assert.Equal(t, date(2021, 10, 3, 16, 0, 0), startAt)
+		assert.Equal(t, date(2021, 10, 3, 16, 30, 0), endAt)
+
+		startAt, endAt = c.roundTime(7)
+		assert.Equal(t, date(2021, 10, 3, 17, 30, 0), startAt)
+		assert.Equal(t, date(2021, 10, 3, 18, 0, 0), endAt)
+	})
+
+	t.Run("score multiplier", func(t *testing.T) {
+		assert.Equal(t, float64(1), c.ScoreMultiplier(4))
+		assert.Equal(t, float64(2), c.ScoreMultiplier(5))
+	})
+}
+
 func date(year, month, day, hour, min, sec int) time.Time {
 	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.Local)
 }
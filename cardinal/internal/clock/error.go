@@ -9,10 +9,13 @@ import (
 )
 
 var (
-	ErrZeroRoundDuration = errors.New("round duration is zero")
-	ErrStartTimeOrder    = errors.New("start time should before end time")
-	ErrRestTimeFormat    = errors.New("rest time format error")
-	ErrRestTimeOrder     = errors.New("rest start time should before end time")
-	ErrRestTimeOverflow  = errors.New("rest time overflow")
-	ErrRestTimeListOrder = errors.New("rest time list should in order")
+	ErrZeroRoundDuration    = errors.New("round duration is zero")
+	ErrStartTimeOrder       = errors.New("start time should before end time")
+	ErrRestTimeFormat       = errors.New("rest time format error")
+	ErrRestTimeOrder        = errors.New("rest start time should before end time")
+	ErrRestTimeOverflow     = errors.New("rest time overflow")
+	ErrRestTimeListOrder    = errors.New("rest time list should in order")
+	ErrPhaseOverflow        = errors.New("phase should start in the game time")
+	ErrPhaseOrder           = errors.New("phase list should in order")
+	ErrPhaseScoreMultiplier = errors.New("phase score multiplier should not be negative")
 )
@@ -0,0 +1,39 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"Cardinal/internal/event"
+)
+
+// The event bus is shared with the live timer, see package event.
+type (
+	EventType    = event.Type
+	Event        = event.Event
+	EventHandler = event.Handler
+	Bus          = event.Bus
+)
+
+const (
+	EventGameStart  = event.GameStart
+	EventRoundStart = event.RoundStart
+	EventRoundEnd   = event.RoundEnd
+	EventPause      = event.Pause
+	EventResume     = event.Resume
+	EventGameEnd    = event.GameEnd
+)
+
+// NewBus returns a new event bus.
+func NewBus() *Bus {
+	return event.NewBus()
+}
+
+// Events is the event bus of the game clock.
+var Events = NewBus()
+
+// Subscribe registers the handler for the given event types of the game clock.
+func Subscribe(handler EventHandler, types ...EventType) {
+	Events.Subscribe(handler, types...)
+}
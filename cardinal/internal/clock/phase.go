@@ -0,0 +1,186 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"time"
+
+	"Cardinal/internal/conf"
+)
+
+// Phase is a part of the game schedule which has its own round duration and score multiplier.
+// The phase lasts until the next phase starts, the last round of the phase ends when the phase ends.
+type Phase struct {
+	Name            string
+	StartAt         time.Time
+	RoundDuration   time.Duration
+	ScoreMultiplier float64
+
+	// StartRound and TotalRound are the rounds of the phase calculated from the run time.
+	StartRound uint
+	TotalRound uint
+
+	// runningStart and runningEnd are the running time of the game when the phase starts and ends.
+	runningStart time.Duration
+	runningEnd   time.Duration
+}
+
+// configPhases returns the phases from the configuration file.
+// The phase with the game round duration is added if the first phase starts after the game starts.
+func configPhases(startAt time.Time, roundDuration time.Duration) []*Phase {
+	phases := make([]*Phase, 0, len(conf.Game.Phases)+1)
+	for _, phase := range conf.Game.Phases {
+		phaseRoundDuration := time.Duration(phase.RoundDuration) * time.Minute
+		if phaseRoundDuration == 0 {
+			phaseRoundDuration = roundDuration
+		}
+		scoreMultiplier := phase.ScoreMultiplier
+		if scoreMultiplier == 0 {
+			scoreMultiplier = 1
+		}
+
+		phases = append(phases, &Phase{
+			Name:            phase.Name,
+			StartAt:         phase.StartAt.In(time.Local),
+			RoundDuration:   phaseRoundDuration,
+			ScoreMultiplier: scoreMultiplier,
+		})
+	}
+
+	if len(phases) == 0 || phases[0].StartAt.After(startAt) {
+		phases = append([]*Phase{{
+			StartAt:         startAt,
+			RoundDuration:   roundDuration,
+			ScoreMultiplier: 1,
+		}}, phases...)
+	}
+	return phases
+}
+
+// checkPhases checks the phases are in order and start in the game time.
+func (c *Clock) checkPhases() error {
+	for key, phase := range c.configPhases {
+		if phase.RoundDuration <= 0 {
+			return ErrZeroRoundDuration
+		}
+		if phase.ScoreMultiplier < 0 {
+			return ErrPhaseScoreMultiplier
+		}
+		if phase.StartAt.Before(c.StartAt) || !phase.StartAt.Before(c.EndAt) {
+			return ErrPhaseOverflow
+		}
+		if key != 0 && !phase.StartAt.After(c.configPhases[key-1].StartAt) {
+			return ErrPhaseOrder
+		}
+	}
+	return nil
+}
+
+// schedulePhases calculates the running time and the rounds of the phases with the given run time.
+// The phase which starts after the game is over has no round.
+func schedulePhases(configPhases []*Phase, runTime [][]time.Time) []*Phase {
+	// runningAt returns the running time of the game at the given time.
+	runningAt := func(t time.Time) time.Duration {
+		var running time.Duration
+		for _, duration := range runTime {
+			if !t.After(duration[0]) {
+				break
+			}
+			if t.Before(duration[1]) {
+				return running + t.Sub(duration[0])
+			}
+			running += duration[1].Sub(duration[0])
+		}
+		return running
+	}
+	totalRunning := runningAt(runTime[len(runTime)-1][1])
+
+	phases := make([]*Phase, 0, len(configPhases))
+	startRound := uint(1)
+	for key, configPhase := range configPhases {
+		phase := *configPhase
+		phase.runningStart = runningAt(phase.StartAt)
+		phase.runningEnd = totalRunning
+		if key != len(configPhases)-1 {
+			phase.runningEnd = runningAt(configPhases[key+1].StartAt)
+		}
+
+		phase.StartRound = startRound
+		phase.TotalRound = uint((phase.runningEnd - phase.runningStart + phase.RoundDuration - 1) / phase.RoundDuration)
+		startRound += phase.TotalRound
+
+		phases = append(phases, &phase)
+	}
+	return phases
+}
+
+// roundAt returns the round, the running time when the round ends and the phase of the round at the given running time.
+// The round which ends at the running time is returned when the game is paused, or the round starts at it is returned.
+// It returns nil phase if there is no round in the game.
+func roundAt(phases []*Phase, running time.Duration, paused bool) (uint, time.Duration, *Phase) {
+	var current *Phase
+	for _, phase := range phases {
+		if phase.TotalRound == 0 {
+			continue
+		}
+		if current == nil ||
+			(!paused && phase.runningStart <= running) ||
+			(paused && phase.runningStart < running) {
+			current = phase
+		}
+	}
+	if current == nil {
+		return 0, 0, nil
+	}
+
+	elapsed := running - current.runningStart
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	index := uint(elapsed / current.RoundDuration)
+	if paused && elapsed != 0 && elapsed%current.RoundDuration == 0 {
+		index--
+	}
+	if index >= current.TotalRound {
+		index = current.TotalRound - 1
+	}
+
+	roundEnd := current.runningStart + time.Duration(index+1)*current.RoundDuration
+	if roundEnd > current.runningEnd {
+		roundEnd = current.runningEnd
+	}
+	return current.StartRound + index, roundEnd, current
+}
+
+// roundRunning returns the running time when the given round starts and ends.
+// It returns false if the round does not exist.
+func roundRunning(phases []*Phase, round uint) (time.Duration, time.Duration, bool) {
+	for _, phase := range phases {
+		if round < phase.StartRound || round >= phase.StartRound+phase.TotalRound {
+			continue
+		}
+
+		start := phase.runningStart + time.Duration(round-phase.StartRound)*phase.RoundDuration
+		end := start + phase.RoundDuration
+		if end > phase.runningEnd {
+			end = phase.runningEnd
+		}
+		return start, end, true
+	}
+	return 0, 0, false
+}
+
+// ScoreMultiplier returns the score multiplier of the given round.
+func (c *Clock) ScoreMultiplier(round uint) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, phase := range c.Phases {
+		if round >= phase.StartRound && round < phase.StartRound+phase.TotalRound {
+			return phase.ScoreMultiplier
+		}
+	}
+	return 1
+}
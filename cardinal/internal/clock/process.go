@@ -6,20 +6,21 @@ package clock
 
 import (
 	"context"
-	"math"
 	"sync"
 	"time"
 
 	log "unknwon.dev/clog/v2"
 
 	"Cardinal/internal/db"
-	"Cardinal/internal/misc/webhook"
-	"Cardinal/internal/rank"
-	//"Cardinal/internal/score"
+	"Cardinal/internal/timeutil"
 )
 
+var subscribeOnce sync.Once
+
 // Start starts the game clock processor routine.
 func Start() {
+	subscribeOnce.Do(subscribe)
+
 	// TODO: only one timer started.
 	go T.start()
 }
@@ -29,129 +30,224 @@ func Stop() {
 	T.stopChan <- struct{}{}
 }
 
+// start runs the game clock processor, it sleeps until the next round or pause boundary,
+// and publishes the events when the game status or the round changes.
 func (c *Clock) start() {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Cardinal may have been restarted by accident, score the rounds which have been missed.
+	c.recover(ctx, c.moment(timeutil.Now()))
+
 	// Refresh the ranking list.
-	if err := rank.SetTitle(ctx); err != nil {
-		log.Error("Failed to set rank title: %v", err)
+	refreshRankList(ctx, true)
+
+	timeutil.Schedule(func(now time.Time) time.Time {
+		m := c.moment(now)
+		c.transit(ctx, m)
+		return m.next
+	}, nil, c.stopChan)
+
+	cancel()
+	close(c.stopChan)
+}
+
+// transit publishes the snapshot of the given moment, and publishes the events of the changes.
+// The snapshot is published before the events, so that the event handlers see the new round.
+func (c *Clock) transit(ctx context.Context, m moment) {
+	var previousStatus Status
+	var previousRound uint
+	if previous, ok := c.snapshot.Load().(*Snapshot); ok {
+		previousStatus, previousRound = previous.Status, previous.Round
 	}
-	if err := rank.SetRankList(ctx); err != nil {
-		log.Error("Failed to set rank list: %v", err)
+
+	snapshot := newSnapshot(m)
+	if snapshot.Round < previousRound {
+		snapshot.Round = previousRound
 	}
+	c.snapshot.Store(snapshot)
+
+	switch m.status {
+	case StatusRunning, StatusPause:
+		if previousRound == 0 && m.round == 1 {
+			Events.Publish(ctx, Event{Type: EventGameStart, Round: m.round, Time: m.at})
+		}
+		// Only the rounds started in this process are ended by the events,
+		// the rounds ended before Cardinal started are recovered by `recover`.
+		for round := previousRound; round != 0 && round < m.round; round++ {
+			Events.Publish(ctx, Event{Type: EventRoundEnd, Round: round, Time: m.at})
+		}
+		if m.round > previousRound {
+			Events.Publish(ctx, Event{Type: EventRoundStart, Round: m.round, Time: m.at})
+		}
 
-	var latestCalculateRound uint
-	lastRound := sync.Once{}
-
-	for {
-		select {
-		case <-c.stopChan:
-			cancel()
-			close(c.stopChan)
-			return
-
-		default:
-			currentTime := time.Now()
-
-			if currentTime.Before(c.StartAt) {
-				// The game is not started.
-				c.Status = StatusWait
-				continue
-
-			} else if currentTime.After(c.EndAt) {
-				// The game is over.
-				// Calculate the score of the last round when the game is over.
-				lastRound.Do(func() {
-					if err := db.Scores.Calculate(ctx, c.TotalRound); err != nil {
-						log.Error("Failed to calculate the last round score: %v", err)
-					}
-
-					go webhook.Add(webhook.END_HOOK, nil)
-					// TODO logger.New(logger.IMPORTANT, "system", locales.T("timer.end"))
-				})
-
-				c.Status = StatusEnd
-				continue
+		if m.status == StatusPause && previousStatus != StatusPause {
+			Events.Publish(ctx, Event{Type: EventPause, Round: m.round, Time: m.at})
+		} else if m.status == StatusRunning && previousStatus == StatusPause {
+			Events.Publish(ctx, Event{Type: EventResume, Round: m.round, Time: m.at})
+		}
+
+	case StatusEnd:
+		if previousStatus != StatusEnd {
+			for round := previousRound; round != 0 && round <= m.round; round++ {
+				Events.Publish(ctx, Event{Type: EventRoundEnd, Round: round, Time: m.at})
 			}
+			Events.Publish(ctx, Event{Type: EventGameEnd, Round: m.round, Time: m.at})
+		}
+	}
+}
+
+// recover records the transitions of the rounds which have passed before the given moment,
+// then scores the ended rounds which have not been scored in order.
+func (c *Clock) recover(ctx context.Context, m moment) {
+	endedRound := m.round
+	if m.status != StatusEnd && endedRound != 0 {
+		// The current round is in progress.
+		endedRound--
+	}
 
-			// The game is running.
-			// Get which time cycle now.
-			currentRunTimeIndex := -1
-			for index, duration := range c.RunTime {
-				if currentTime.After(duration[0]) && currentTime.Before(duration[1]) {
-					currentRunTimeIndex = index
-					break
-				}
+	rounds, err := db.Rounds.Get(ctx)
+	if err != nil {
+		log.Error("Failed to get rounds: %v", err)
+		return
+	}
+	recorded := make(map[uint]*db.Round, len(rounds))
+	for _, r := range rounds {
+		recorded[r.Round] = r
+	}
+
+	for round := uint(1); round <= m.round; round++ {
+		r, ok := recorded[round]
+		startAt, endAt := c.roundTime(round)
+		if !ok || r.StartedAt == nil {
+			if err := db.Rounds.Start(ctx, round, startAt, c.ScoreMultiplier(round)); err != nil {
+				log.Error("Failed to record the start of round %d: %v", round, err)
 			}
+		}
+		if round <= endedRound && (!ok || r.EndedAt == nil) {
+			if err := db.Rounds.End(ctx, round, endAt); err != nil {
+				log.Error("Failed to record the end of round %d: %v", round, err)
+			}
+		}
+	}
+
+	unscoredRounds, err := db.Rounds.GetUnscored(ctx)
+	if err != nil {
+		log.Error("Failed to get unscored rounds: %v", err)
+		return
+	}
+	for _, r := range unscoredRounds {
+		log.Info("Calculate the score of the missed round %d.", r.Round)
+		calculateScore(ctx, r.Round)
+	}
+}
+
+// roundTime returns the start and end time of the given round calculated from the run time.
+func (c *Clock) roundTime(round uint) (startAt, endAt time.Time) {
+	c.mu.RLock()
+	runTime, gameEndAt, phases := c.RunTime, c.EndAt, c.Phases
+	c.mu.RUnlock()
+
+	runningStart, runningEnd, ok := roundRunning(phases, round)
+	if !ok {
+		return gameEndAt, gameEndAt
+	}
+
+	// timeAt returns the time when the game has been running for the given duration.
+	// When the duration is reached at the end of a time cycle, the end of it is returned if `end` is true,
+	// otherwise the start of the next time cycle is returned.
+	timeAt := func(running time.Duration, end bool) time.Time {
+		for _, duration := range runTime {
+			cycle := duration[1].Sub(duration[0])
+			if running < cycle || (end && running == cycle) {
+				return duration[0].Add(running)
+			}
+			running -= cycle
+		}
+		return gameEndAt
+	}
 
-			if currentRunTimeIndex == -1 {
-				// Suspended
-				if c.Status != StatusPause {
-					go webhook.Add(webhook.PAUSE_HOOK, nil)
-				}
-				c.Status = StatusPause
-
-			} else {
-				// In progress
-				c.Status = StatusRunning
-
-				// Cumulative time until now.
-				var runningDuration time.Duration
-				for index, duration := range c.RunTime {
-					if index < currentRunTimeIndex {
-						runningDuration += duration[1].Sub(duration[0])
-					} else {
-						// The last runtime cycle for now.
-						runningDuration += currentTime.Sub(duration[0])
-						break
-					}
-				}
-
-				// Get current round.
-				currentRound := uint(math.Ceil(runningDuration.Seconds() / c.RoundDuration.Seconds()))
-				// Calculate the time duration next round.
-				c.RoundRemainDuration = time.Duration(currentRound)*c.RoundDuration - runningDuration
-
-				// Check if it is a new round.
-				if c.CurrentRound < currentRound {
-					c.CurrentRound = currentRound
-					if c.CurrentRound == 1 {
-						go webhook.Add(webhook.BEGIN_HOOK, nil)
-					}
-
-					go webhook.Add(webhook.BEGIN_HOOK, c.CurrentRound)
-
-					// Clean the status of the game boxes.
-					if err := db.GameBoxes.CleanAllStatus(ctx); err != nil {
-						log.Error("Failed to clean game boxes' status: %v", err)
-					}
-
-					// Refresh the ranking list.
-					if err := rank.SetTitle(ctx); err != nil {
-						log.Error("Failed to set rank title: %v", err)
-					}
-					if err := rank.SetRankList(ctx); err != nil {
-						log.Error("Failed to set rank list: %v", err)
-					}
-
-					// If Cardinal has been restart by accident, get the latest round score and chick if it needs to calculate the scores of previous round.
-					// The default value of `latestCalculateRound` is 0, it means that Cardinal will calculate the score when started.
-					if latestCalculateRound < c.CurrentRound-1 {
-						if err := db.Scores.Calculate(ctx, c.CurrentRound-1); err != nil {
-							log.Error("Failed to calculate score: %v", err)
-						}
-						latestCalculateRound = c.CurrentRound - 1
-					}
-
-					// TODO Auto refresh flag
-					//RefreshFlag()
-
-					// TODO Asteroid Unity3D refresh.
-					//asteroid.NewRoundAction()
-				}
+	return timeAt(runningStart, false), timeAt(runningEnd, true)
+}
+
+// moment is the state of the game clock at a given time.
+type moment struct {
+	at     time.Time
+	status Status
+	round  uint
+	phase  *Phase
+	// startAt, endAt and totalRound are the game time when the moment is calculated.
+	startAt    time.Time
+	endAt      time.Time
+	totalRound uint
+	// roundRemain is the running time remaining in the current round, the pause time is not included.
+	roundRemain time.Duration
+	// next is the time when the status or the round changes next, it is zero if the game is over.
+	next time.Time
+}
+
+// moment returns the state of the game clock at the given time.
+func (c *Clock) moment(at time.Time) moment {
+	c.mu.RLock()
+	startAt, endAt, runTime, totalRound, phases := c.StartAt, c.EndAt, c.RunTime, c.TotalRound, c.Phases
+	c.mu.RUnlock()
+
+	m := moment{at: at, startAt: startAt, endAt: endAt, totalRound: totalRound}
+	if at.Before(startAt) {
+		// The game is not started.
+		m.status = StatusWait
+		m.next = startAt
+		if len(phases) != 0 {
+			m.phase = phases[0]
+		}
+		return m
+	} else if !at.Before(endAt) {
+		// The game is over.
+		m.status = StatusEnd
+		m.round = totalRound
+		for _, phase := range phases {
+			if phase.TotalRound != 0 {
+				m.phase = phase
 			}
+		}
+		return m
+	}
+
+	// Get which time cycle now, and the cumulative running time until now.
+	var runningDuration time.Duration
+	currentRunTimeIndex := -1
+	for index, duration := range runTime {
+		if at.Before(duration[0]) {
+			// Suspended until the next time cycle.
+			m.next = duration[0]
+			break
+		}
+		if at.Before(duration[1]) {
+			currentRunTimeIndex = index
+			runningDuration += at.Sub(duration[0])
+			break
+		}
+		runningDuration += duration[1].Sub(duration[0])
+	}
 
-			time.Sleep(1 * time.Second)
+	var roundEnd time.Duration
+	if currentRunTimeIndex == -1 {
+		// Suspended, the round in progress continues when resumed.
+		m.status = StatusPause
+		m.round, roundEnd, m.phase = roundAt(phases, runningDuration, true)
+		if m.next.IsZero() {
+			m.next = endAt
+		}
+	} else {
+		// In progress
+		m.status = StatusRunning
+		m.round, roundEnd, m.phase = roundAt(phases, runningDuration, false)
+
+		// The round ends or the game is suspended, whichever comes first.
+		m.next = runTime[currentRunTimeIndex][1]
+		if roundEndAt := at.Add(roundEnd - runningDuration); roundEndAt.Before(m.next) {
+			m.next = roundEndAt
 		}
 	}
+	m.roundRemain = roundEnd - runningDuration
+	return m
 }
@@ -0,0 +1,64 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"time"
+
+	"Cardinal/internal/timeutil"
+)
+
+// Snapshot is the state of the game clock at a moment, it is immutable once published.
+// The readers should get the snapshot once and use it for the whole request,
+// so that the round and the status they see are consistent.
+type Snapshot struct {
+	At         time.Time
+	Status     Status
+	Round      uint
+	TotalRound uint
+	StartAt    time.Time
+	EndAt      time.Time
+	// Phase is the phase of the round, it is the first phase before the game starts,
+	// and the last phase after the game is over.
+	Phase *Phase
+
+	// roundRemain is the running time remaining in the round at the time of the snapshot.
+	roundRemain time.Duration
+}
+
+func newSnapshot(m moment) *Snapshot {
+	return &Snapshot{
+		At:          m.at,
+		Status:      m.status,
+		Round:       m.round,
+		TotalRound:  m.totalRound,
+		StartAt:     m.startAt,
+		EndAt:       m.endAt,
+		Phase:       m.phase,
+		roundRemain: m.roundRemain,
+	}
+}
+
+// RoundRemainDuration returns the running time remaining in the round at the given time,
+// the pause time is not included.
+func (s *Snapshot) RoundRemainDuration(at time.Time) time.Duration {
+	remain := s.roundRemain
+	if s.Status == StatusRunning {
+		remain -= at.Sub(s.At)
+	}
+	if remain < 0 {
+		return 0
+	}
+	return remain
+}
+
+// Snapshot returns the latest snapshot published by the clock processor.
+// The snapshot is calculated from the current time if the clock processor is not started.
+func (c *Clock) Snapshot() *Snapshot {
+	if snapshot, ok := c.snapshot.Load().(*Snapshot); ok {
+		return snapshot
+	}
+	return newSnapshot(c.moment(timeutil.Now()))
+}
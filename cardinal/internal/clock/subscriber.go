@@ -0,0 +1,172 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/asteroid"
+	"Cardinal/internal/db"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/misc/webhook"
+	"Cardinal/internal/rank"
+	"Cardinal/internal/utils"
+)
+
+// subscribe registers the subsystems which are driven by the game clock to the event bus.
+// The handlers of the same event are called in the order below.
+func subscribe() {
+	// Record the round transitions before the score calculation,
+	// so the round which has ended but not been scored can be recovered when Cardinal restarts.
+	Subscribe(func(ctx context.Context, event Event) {
+		var err error
+		switch event.Type {
+		case EventRoundStart:
+			err = db.Rounds.Start(ctx, event.Round, event.Time, T.ScoreMultiplier(event.Round))
+		case EventRoundEnd:
+			err = db.Rounds.End(ctx, event.Round, event.Time)
+		}
+		if err != nil {
+			log.Error("Failed to record the %s of round %d: %v", event.Type, event.Round, err)
+		}
+	}, EventRoundStart, EventRoundEnd)
+
+	// Clean the status of the game boxes and refresh the ranking list when the new round starts.
+	Subscribe(func(ctx context.Context, event Event) {
+		if err := db.GameBoxes.CleanAllStatus(ctx); err != nil {
+			log.Error("Failed to clean game boxes' status: %v", err)
+		}
+		refreshRankList(ctx, true)
+	}, EventRoundStart)
+
+	// Plant the flags of the new round into the game boxes.
+	Subscribe(func(ctx context.Context, event Event) {
+		refreshFlag(ctx, event.Round)
+	}, EventRoundStart)
+
+	// Calculate the score when the round ends.
+	Subscribe(func(ctx context.Context, event Event) {
+		calculateScore(ctx, event.Round)
+	}, EventRoundEnd)
+
+	// Refresh the ranking list when the game is over, it will be unfrozen.
+	Subscribe(func(ctx context.Context, event Event) {
+		refreshRankList(ctx, false)
+	}, EventGameEnd)
+
+	// WebHook
+	Subscribe(func(ctx context.Context, event Event) {
+		switch event.Type {
+		case EventGameStart:
+			webhook.Add(webhook.BEGIN_HOOK, nil)
+		case EventRoundStart:
+			webhook.Add(webhook.NEW_ROUND_HOOK, event.Round)
+		case EventPause:
+			webhook.Add(webhook.PAUSE_HOOK, nil)
+		case EventGameEnd:
+			webhook.Add(webhook.END_HOOK, nil)
+		}
+	}, EventGameStart, EventRoundStart, EventPause, EventGameEnd)
+
+	// Asteroid Unity3D refresh.
+	Subscribe(func(ctx context.Context, event Event) {
+		asteroid.NewRoundAction()
+	}, EventRoundStart)
+
+	// Live log
+	Subscribe(func(ctx context.Context, event Event) {
+		if livelog.Stream == nil {
+			return
+		}
+		_ = livelog.Stream.Write(livelog.GlobalStream, livelog.NewLine(string(event.Type), map[string]interface{}{
+			"Round": event.Round,
+		}))
+	}, EventGameStart, EventRoundStart, EventRoundEnd, EventPause, EventResume, EventGameEnd)
+}
+
+// calculateScore calculates the score of the given round, then saves the rank history.
+func calculateScore(ctx context.Context, round uint) {
+	if err := db.Scores.Calculate(ctx, round); err != nil {
+		log.Error("Failed to calculate the score of round %d: %v", round, err)
+		return
+	}
+
+	webhook.Add(webhook.SCORE_RECOMPUTED_HOOK, map[string]interface{}{"round": round})
+
+	if err := db.RankHistories.Snapshot(ctx, round); err != nil {
+		log.Error("Failed to save the rank history of round %d: %v", round, err)
+	}
+
+	// Refresh the ranking list again, for the ranking list for teams may be built from the rank history.
+	refreshRankList(ctx, false)
+}
+
+// refreshFlag plants the flags of the given round into the game boxes whose challenge renews the flag automatically.
+// The renew flag command is executed in the game box through SSH, with the `{{FLAG}}` placeholder replaced by the flag.
+func refreshFlag(ctx context.Context, round uint) {
+	challenges, err := db.Challenges.Get(ctx)
+	if err != nil {
+		log.Error("Failed to get challenges: %v", err)
+		return
+	}
+
+	for _, challenge := range challenges {
+		if !challenge.AutoRenewFlag {
+			continue
+		}
+
+		gameBoxes, err := db.GameBoxes.Get(ctx, db.GetGameBoxesOption{ChallengeID: challenge.ID})
+		if err != nil {
+			log.Error("Failed to get the game boxes of challenge %d: %v", challenge.ID, err)
+			continue
+		}
+
+		for _, gameBox := range gameBoxes {
+			go func(gameBox *db.GameBox, challenge *db.Challenge) {
+				flags, _, err := db.Flags.Get(ctx, db.GetFlagOptions{GameBoxID: gameBox.ID, Round: round})
+				if err != nil {
+					log.Error("Failed to get the flag of game box %d in round %d: %v", gameBox.ID, round, err)
+					return
+				}
+				if len(flags) == 0 {
+					log.Warn("The flag of game box %d in round %d has not been generated.", gameBox.ID, round)
+					return
+				}
+
+				command := strings.ReplaceAll(challenge.RenewFlagCommand, "{{FLAG}}", flags[0].Value)
+				_, err = utils.SSHExecute(gameBox.IPAddress, strconv.Itoa(int(gameBox.InternalSSHPort)), gameBox.InternalSSHUser, gameBox.InternalSSHPassword, command)
+				if err != nil {
+					log.Error("Failed to plant the flag of team %d game box %d in round %d: %v", gameBox.TeamID, gameBox.ID, round, err)
+					webhook.Add(webhook.FLAG_PLANT_FAILED_HOOK, map[string]interface{}{
+						"team":      gameBox.TeamID,
+						"challenge": gameBox.ChallengeID,
+						"gamebox":   gameBox.ID,
+						"round":     round,
+					})
+					_ = livelog.WriteTeam(gameBox.TeamID, livelog.NewLine("flag_plant_failed", map[string]interface{}{
+						"Round":     round,
+						"Challenge": challenge.Title,
+					}))
+				}
+			}(gameBox, challenge)
+		}
+	}
+}
+
+// refreshRankList refreshes the ranking list, the title will be refreshed if withTitle is true.
+func refreshRankList(ctx context.Context, withTitle bool) {
+	if withTitle {
+		if err := rank.SetTitle(ctx); err != nil {
+			log.Error("Failed to set rank title: %v", err)
+		}
+	}
+	if err := rank.SetRankList(ctx); err != nil {
+		log.Error("Failed to set rank list: %v", err)
+	}
+}
@@ -35,6 +35,8 @@ func runWeb(c *cli.Context) error {
 	}
 	log.Trace(locales.T("config.load_success"))
 
+	conf.InitClock()
+
 	if err = db.Init(); err != nil {
 		log.Fatal("Failed to init database: %v", err)
 	}
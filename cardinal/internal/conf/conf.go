@@ -6,10 +6,13 @@ package conf
 
 import (
 	"os"
+	"time"
 
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/timeutil"
 )
 
 func init() {
@@ -31,6 +34,17 @@ func Init(customConf string) error {
 	return parse(config)
 }
 
+// InitClock runs the game at the rehearsal speed from the start time if the rehearsal mode is enabled,
+// it should be called after the configuration is loaded and before the game clock starts.
+func InitClock() {
+	if Game.RehearsalSpeed <= 0 {
+		return
+	}
+
+	timeutil.SetClock(timeutil.NewScaledClock(Game.StartAt.In(time.Local), Game.RehearsalSpeed))
+	log.Warn("Rehearsal mode is enabled, the game runs at %gx speed from the start time.", Game.RehearsalSpeed)
+}
+
 // parseTree parses the given toml Tree.
 func parse(config *toml.Tree) error {
 	if err := config.Get("App").(*toml.Tree).Unmarshal(&App); err != nil {
@@ -44,10 +58,30 @@ func parse(config *toml.Tree) error {
 	if err := config.Get("Game").(*toml.Tree).Unmarshal(&Game); err != nil {
 		return errors.Wrap(err, "mapping [Game] section")
 	}
+	if err := checkRankTieBreakers(Game.RankTieBreakers); err != nil {
+		return errors.Wrap(err, "check [Game] section")
+	}
 
 	return nil
 }
 
+// checkRankTieBreakers returns an error if any of the tie breakers is unknown or repeated.
+func checkRankTieBreakers(tieBreakers []string) error {
+	seen := make(map[string]bool, len(tieBreakers))
+	for _, tieBreaker := range tieBreakers {
+		switch tieBreaker {
+		case RankTieBreakerScoreReachedAt, RankTieBreakerFewestDowns, RankTieBreakerMostCaptures:
+		default:
+			return errors.Errorf("unexpected rank tie breaker %q", tieBreaker)
+		}
+		if seen[tieBreaker] {
+			return errors.Errorf("repeated rank tie breaker %q", tieBreaker)
+		}
+		seen[tieBreaker] = true
+	}
+	return nil
+}
+
 func Save(customConf string) error {
 	if customConf == "" {
 		customConf = "./conf/Cardinal.toml"
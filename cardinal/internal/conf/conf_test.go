@@ -13,3 +13,10 @@ import (
 func TestNewInit(t *testing.T) {
 	assert.Nil(t, Init("./testdata/custom.toml"))
 }
+
+func Test_checkRankTieBreakers(t *testing.T) {
+	assert.Nil(t, checkRankTieBreakers(nil))
+	assert.Nil(t, checkRankTieBreakers([]string{"score_reached_at", "fewest_downs", "most_captures"}))
+	assert.NotNil(t, checkRankTieBreakers([]string{"most_flags"}))
+	assert.NotNil(t, checkRankTieBreakers([]string{"fewest_downs", "fewest_downs"}))
+}
@@ -16,11 +16,31 @@ var (
 	BuildCommit string
 )
 
+// The keys of the rank tie breakers.
+const (
+	// RankTieBreakerScoreReachedAt ranks the team which reached its current score in an earlier round higher.
+	RankTieBreakerScoreReachedAt = "score_reached_at"
+	// RankTieBreakerFewestDowns ranks the team whose game boxes were checked down fewer times higher.
+	RankTieBreakerFewestDowns = "fewest_downs"
+	// RankTieBreakerMostCaptures ranks the team which captured more flags higher.
+	RankTieBreakerMostCaptures = "most_captures"
+)
+
 type Period struct {
 	StartAt toml.LocalDateTime
 	EndAt   toml.LocalDateTime
 }
 
+// Phase is a part of the game schedule which lasts until the next phase starts.
+type Phase struct {
+	Name    string
+	StartAt toml.LocalDateTime
+	// RoundDuration is the round duration of the phase in minutes, the game round duration is used if it is zero.
+	RoundDuration uint
+	// ScoreMultiplier multiplies the attack and check down score of the rounds in the phase, it is 1 if zero.
+	ScoreMultiplier float64
+}
+
 var (
 	// App is the application settings.
 	App struct {
@@ -52,11 +72,35 @@ var (
 		EndAt         toml.LocalDateTime
 		PauseTime     []Period
 		RoundDuration uint
+		// Phases splits the game schedule into phases, each with its own round duration and score multiplier.
+		// The game round duration is used before the first phase starts.
+		Phases []Phase
 
 		FlagPrefix string
 		FlagSuffix string
 
 		AttackScore    int
 		CheckDownScore int
+
+		// FirstBloodScore is the bonus for the first team which captures the challenge.
+		// The first blood is disabled if it is zero.
+		FirstBloodScore int
+		// FirstBloodPerVictim rewards the first attacker of each victim team
+		// rather than only the first attacker of the challenge.
+		FirstBloodPerVictim bool
+
+		// RankFreezeAt is the time when the ranking list for teams and public is frozen until the game is over.
+		// The freeze is disabled if it is not set.
+		RankFreezeAt toml.LocalDateTime
+		// RankDelayRound makes the ranking list for teams and public N rounds behind the live one.
+		RankDelayRound uint
+		// RankTieBreakers orders the teams with the same score by the keys in turn, the teams with the same keys
+		// share the same rank. The keys are "score_reached_at", "fewest_downs" and "most_captures".
+		RankTieBreakers []string
+
+		// RehearsalSpeed runs the whole game from the start time at the speed multiplier when Cardinal starts,
+		// so the schedule can be tested in minutes before the real event. The rehearsal mode is disabled if it is zero.
+		// NOTE: The time saved in the database is also simulated, do not use it with the database of the real event.
+		RehearsalSpeed float64
 	}
 )
@@ -32,6 +32,7 @@ MaxIdleConns = 50
 RoundDuration = 300
 AttackScore = 10
 CheckDownScore = 10
+FirstBloodScore = 50
 `)
 	if err != nil {
 		return errors.Wrap(err, "load test config")
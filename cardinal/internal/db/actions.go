@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgconn"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var _ ActionsStore = (*actions)(nil)
@@ -28,6 +29,9 @@ type ActionsStore interface {
 	SetScore(ctx context.Context, opts SetActionScoreOptions) error
 	// CountScore counts score with the given options.
 	CountScore(ctx context.Context, opts CountActionScoreOptions) (float64, error)
+	// CreateFirstBlood creates the first blood action on the victim's game box with the given score.
+	// It returns ErrFirstBloodTaken if the first blood of the challenge (or the victim) has been taken.
+	CreateFirstBlood(ctx context.Context, opts CreateFirstBloodOptions) (*Action, error)
 	// GetEmptyScore returns the empty score actions in the given round.
 	GetEmptyScore(ctx context.Context, round uint, actionType ActionType) ([]*Action, error)
 	// Delete deletes the actions with the given options.
@@ -48,9 +52,11 @@ const (
 	ActionTypeCheckDown
 	ActionTypeAttack
 	ActionTypeServiceOnline
+	ActionTypeFirstBlood
 )
 
 // Action represents the action such as check down or being attacked.
+// The first blood action is recorded on the victim's game box, the score belongs to the attacker.
 type Action struct {
 	gorm.Model
 
@@ -88,52 +94,43 @@ func (db *actions) Create(ctx context.Context, opts CreateActionOptions) (*Actio
 		return nil, err
 	}
 
-	tx := db.WithContext(ctx).Begin()
 	var action Action
-	err = tx.Model(&Action{}).Where(&Action{
-		Type:           opts.Type,
-		TeamID:         gameBox.TeamID,
-		ChallengeID:    gameBox.ChallengeID,
-		GameBoxID:      gameBox.ID,
-		AttackerTeamID: opts.AttackerTeamID,
-		Round:          opts.Round,
-	}).First(&action).Error
-	if err == nil {
-		tx.Rollback()
-		return nil, ErrDuplicateAction
-	} else if err != gorm.ErrRecordNotFound {
-		tx.Rollback()
-		return nil, errors.Wrap(err, "get action")
-	}
-
-	action = Action{
-		Type:           opts.Type,
-		TeamID:         gameBox.TeamID,
-		ChallengeID:    gameBox.ChallengeID,
-		GameBoxID:      gameBox.ID,
-		AttackerTeamID: opts.AttackerTeamID,
-		Round:          opts.Round,
-	}
-	err = tx.Create(&action).Error
-	if err != nil {
-		tx.Rollback()
-
-		// NOTE: How to check if error type is DUPLICATE KEY in GORM.
-		// https://github.com/go-gorm/gorm/issues/4037
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Action{}).Where(&Action{
+			Type:           opts.Type,
+			TeamID:         gameBox.TeamID,
+			ChallengeID:    gameBox.ChallengeID,
+			GameBoxID:      gameBox.ID,
+			AttackerTeamID: opts.AttackerTeamID,
+			Round:          opts.Round,
+		}).First(&action).Error
+		if err == nil {
+			return ErrDuplicateAction
+		} else if err != gorm.ErrRecordNotFound {
+			return errors.Wrap(err, "get action")
+		}
 
-		// Postgres
-		if pgError, ok := err.(*pgconn.PgError); ok && errors.Is(err, pgError) && pgError.Code == "23505" {
-			return nil, ErrDuplicateAction
+		action = Action{
+			Type:           opts.Type,
+			TeamID:         gameBox.TeamID,
+			ChallengeID:    gameBox.ChallengeID,
+			GameBoxID:      gameBox.ID,
+			AttackerTeamID: opts.AttackerTeamID,
+			Round:          opts.Round,
 		}
-		// MySQL
-		var mysqlErr mysql.MySQLError
-		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
-			return nil, ErrDuplicateAction
+		err = tx.Create(&action).Error
+		if err != nil {
+			if isDuplicateKeyError(err) {
+				return ErrDuplicateAction
+			}
+			return err
 		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-
-	return &action, tx.Commit().Error
+	return &action, nil
 }
 
 type GetActionOptions struct {
@@ -187,12 +184,12 @@ func (db *actions) SetScore(ctx context.Context, opts SetActionScoreOptions) err
 	action := actions[0]
 
 	// Check the action score sign, the BeenAttack and CheckDown score must be negative,
-	// the Attack and ServiceOnline score must be positive.
+	// the Attack, ServiceOnline and FirstBlood score must be positive.
 	if action.Type == ActionTypeBeenAttack || action.Type == ActionTypeCheckDown {
 		if opts.Score > 0 {
 			return ErrActionScoreInvalid
 		}
-	} else if action.Type == ActionTypeAttack || action.Type == ActionTypeServiceOnline {
+	} else if action.Type == ActionTypeAttack || action.Type == ActionTypeServiceOnline || action.Type == ActionTypeFirstBlood {
 		if opts.Score < 0 {
 			return ErrActionScoreInvalid
 		}
@@ -229,6 +226,88 @@ func (db *actions) CountScore(ctx context.Context, opts CountActionScoreOptions)
 	}).Find(&sum).Error
 }
 
+type CreateFirstBloodOptions struct {
+	GameBoxID      uint // The victim's game box ID.
+	AttackerTeamID uint
+	Round          uint
+	Score          float64
+	PerVictim      bool
+}
+
+var ErrFirstBloodTaken = errors.New("first blood has been taken")
+
+func (db *actions) CreateFirstBlood(ctx context.Context, opts CreateFirstBloodOptions) (*Action, error) {
+	if opts.Score < 0 {
+		return nil, ErrActionScoreInvalid
+	}
+
+	gameBoxStore := NewGameBoxesStore(db.DB)
+	gameBox, err := gameBoxStore.GetByID(ctx, opts.GameBoxID)
+	if err != nil {
+		return nil, err
+	}
+
+	var action Action
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the game boxes of the challenge, so the first blood of the challenge is taken by one submission at a time.
+		// Another submission waits here until this transaction is done, then it will find the first blood taken.
+		var gameBoxes []*GameBox
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("challenge_id = ?", gameBox.ChallengeID).Find(&gameBoxes).Error; err != nil {
+			return errors.Wrap(err, "lock challenge game boxes")
+		}
+
+		q := tx.Model(&Action{}).Where(&Action{
+			Type:        ActionTypeFirstBlood,
+			ChallengeID: gameBox.ChallengeID,
+		})
+		if opts.PerVictim {
+			q = q.Where("team_id = ?", gameBox.TeamID)
+		}
+
+		err := q.First(&action).Error
+		if err == nil {
+			return ErrFirstBloodTaken
+		} else if err != gorm.ErrRecordNotFound {
+			return errors.Wrap(err, "get first blood action")
+		}
+
+		action = Action{
+			Type:           ActionTypeFirstBlood,
+			TeamID:         gameBox.TeamID,
+			ChallengeID:    gameBox.ChallengeID,
+			GameBoxID:      gameBox.ID,
+			AttackerTeamID: opts.AttackerTeamID,
+			Round:          opts.Round,
+			Score:          opts.Score,
+		}
+		if err := tx.Create(&action).Error; err != nil {
+			if isDuplicateKeyError(err) {
+				return ErrFirstBloodTaken
+			}
+			return errors.Wrap(err, "create first blood action")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// isDuplicateKeyError returns true if the error is caused by violating a unique index.
+// NOTE: How to check if error type is DUPLICATE KEY in GORM.
+// https://github.com/go-gorm/gorm/issues/4037
+func isDuplicateKeyError(err error) bool {
+	// Postgres
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) && pgError.Code == "23505" {
+		return true
+	}
+	// MySQL
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
 func (db *actions) GetEmptyScore(ctx context.Context, round uint, actionType ActionType) ([]*Action, error) {
 	var actions []*Action
 	return actions, db.WithContext(ctx).Model(&Action{}).Where("round = ? AND type = ? AND score = 0", round, actionType).Find(&actions).Error
@@ -245,7 +324,9 @@ type DeleteActionOptions struct {
 }
 
 func (db *actions) Delete(ctx context.Context, opts DeleteActionOptions) error {
-	return db.WithContext(ctx).Where(&Action{
+	// The actions are deleted permanently, or the soft deleted rows will conflict with the unique index
+	// when the same action is created again, such as the service online actions of a re-scored round.
+	return db.WithContext(ctx).Unscoped().Where(&Action{
 		Model: gorm.Model{
 			ID: opts.ActionID,
 		},
@@ -6,6 +6,7 @@ package db
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -89,6 +90,8 @@ func TestActions(t *testing.T) {
 		test func(t *testing.T, ctx context.Context, db *actions)
 	}{
 		{"Create", testActionsCreate},
+		{"CreateFirstBlood", testActionsCreateFirstBlood},
+		{"CreateFirstBloodConcurrently", testActionsCreateFirstBloodConcurrently},
 		{"Get", testActionsGet},
 		{"SetScore", testActionsSetScore},
 		{"CountScore", testActionsCountScore},
@@ -152,6 +155,107 @@ func testActionsCreate(t *testing.T, ctx context.Context, db *actions) {
 	assert.Equal(t, ErrGameBoxNotExists, err)
 }
 
+func testActionsCreateFirstBlood(t *testing.T, ctx context.Context, db *actions) {
+	got, err := db.CreateFirstBlood(ctx, CreateFirstBloodOptions{
+		GameBoxID:      2,
+		AttackerTeamID: 1,
+		Round:          1,
+		Score:          100,
+	})
+	assert.Nil(t, err)
+
+	got.CreatedAt = time.Time{}
+	got.UpdatedAt = time.Time{}
+
+	want := &Action{
+		Model: gorm.Model{
+			ID: 1,
+		},
+		Type:           ActionTypeFirstBlood,
+		TeamID:         2,
+		ChallengeID:    1,
+		GameBoxID:      2,
+		AttackerTeamID: 1,
+		Round:          1,
+		Score:          100,
+	}
+	assert.Equal(t, want, got)
+
+	// The first blood of the challenge has been taken.
+	_, err = db.CreateFirstBlood(ctx, CreateFirstBloodOptions{
+		GameBoxID:      1,
+		AttackerTeamID: 2,
+		Round:          2,
+		Score:          100,
+	})
+	assert.Equal(t, ErrFirstBloodTaken, err)
+
+	// The first blood of the other victim is still available.
+	_, err = db.CreateFirstBlood(ctx, CreateFirstBloodOptions{
+		GameBoxID:      1,
+		AttackerTeamID: 2,
+		Round:          2,
+		Score:          100,
+		PerVictim:      true,
+	})
+	assert.Nil(t, err)
+
+	_, err = db.CreateFirstBlood(ctx, CreateFirstBloodOptions{
+		GameBoxID:      1,
+		AttackerTeamID: 2,
+		Round:          3,
+		Score:          100,
+		PerVictim:      true,
+	})
+	assert.Equal(t, ErrFirstBloodTaken, err)
+
+	// The first blood score must be positive.
+	_, err = db.CreateFirstBlood(ctx, CreateFirstBloodOptions{
+		GameBoxID:      1,
+		AttackerTeamID: 2,
+		Round:          3,
+		Score:          -100,
+	})
+	assert.Equal(t, ErrActionScoreInvalid, err)
+}
+
+func testActionsCreateFirstBloodConcurrently(t *testing.T, ctx context.Context, db *actions) {
+	const submissions = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, submissions)
+	for i := 0; i < submissions; i++ {
+		wg.Add(1)
+		go func(round uint) {
+			defer wg.Done()
+			_, err := db.CreateFirstBlood(ctx, CreateFirstBloodOptions{
+				GameBoxID:      2,
+				AttackerTeamID: 1,
+				Round:          round,
+				Score:          100,
+			})
+			errs <- err
+		}(uint(i + 1))
+	}
+	wg.Wait()
+	close(errs)
+
+	// Only one of the submissions takes the first blood.
+	taken := 0
+	for err := range errs {
+		if err == nil {
+			taken++
+			continue
+		}
+		assert.Equal(t, ErrFirstBloodTaken, err)
+	}
+	assert.Equal(t, 1, taken)
+
+	got, err := db.Get(ctx, GetActionOptions{Type: ActionTypeFirstBlood})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+}
+
 func testActionsGet(t *testing.T, ctx context.Context, db *actions) {
 	_, err := db.Create(ctx, CreateActionOptions{
 		Type:           ActionTypeBeenAttack,
@@ -24,8 +24,12 @@ var AllTables = []interface{}{
 	&Challenge{},
 	&Flag{},
 	&GameBox{},
+	&GameBoxHistory{},
 	&Log{},
 	&Manager{},
+	&RankHistory{},
+	&Round{},
+	&ScoreAdjustment{},
 	&Team{},
 }
 
@@ -93,7 +97,10 @@ func SetDatabaseStore(db *gorm.DB) {
 	Flags = NewFlagsStore(db)
 	GameBoxes = NewGameBoxesStore(db)
 	Ranks = NewRanksStore(db)
+	RankHistories = NewRankHistoriesStore(db)
+	Rounds = NewRoundsStore(db)
 	Scores = NewScoresStore(db)
+	ScoreAdjustments = NewScoreAdjustmentsStore(db)
 	Logs = NewLogsStore(db)
 	Managers = NewManagersStore(db)
 	Teams = NewTeamsStore(db)
@@ -51,6 +51,7 @@ type GameBoxInfo struct {
 	ChallengeID uint
 	IsCaptured  bool
 	IsDown      bool
+	FirstBlood  bool    // The team took the first blood of the challenge.
 	Score       float64 `json:",omitempty"` // Manager only
 }
 
@@ -74,22 +75,41 @@ func (db *ranks) List(ctx context.Context) ([]*RankItem, error) {
 
 	rankItems := make([]*RankItem, 0, len(teams))
 
-	gameBoxesStore := NewGameBoxesStore(db.DB)
-	for _, team := range teams {
-		gameBoxes, err := gameBoxesStore.Get(ctx, GetGameBoxesOption{
-			TeamID:  team.ID,
-			Visible: true,
-		})
-		if err != nil {
-			return nil, errors.Wrap(err, "get team game box")
-		}
+	firstBloodActions, err := NewActionsStore(db.DB).Get(ctx, GetActionOptions{
+		Type: ActionTypeFirstBlood,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get first blood actions")
+	}
 
-		gameBoxInfo := make(GameBoxInfoList, 0, len(gameBoxes))
-		for _, gameBox := range gameBoxes {
+	type firstBloodKey struct {
+		teamID      uint
+		challengeID uint
+	}
+	firstBloods := make(map[firstBloodKey]struct{}, len(firstBloodActions))
+	for _, action := range firstBloodActions {
+		firstBloods[firstBloodKey{teamID: action.AttackerTeamID, challengeID: action.ChallengeID}] = struct{}{}
+	}
+
+	// Get all the visible game boxes in one query, then group them by the team.
+	var gameBoxes []*GameBox
+	if err := db.WithContext(ctx).Model(&GameBox{}).Where("visible = ?", true).Order("id ASC").Find(&gameBoxes).Error; err != nil {
+		return nil, errors.Wrap(err, "get game boxes")
+	}
+	teamGameBoxes := make(map[uint][]*GameBox, len(teams))
+	for _, gameBox := range gameBoxes {
+		teamGameBoxes[gameBox.TeamID] = append(teamGameBoxes[gameBox.TeamID], gameBox)
+	}
+
+	for _, team := range teams {
+		gameBoxInfo := make(GameBoxInfoList, 0, len(teamGameBoxes[team.ID]))
+		for _, gameBox := range teamGameBoxes[team.ID] {
+			_, isFirstBlood := firstBloods[firstBloodKey{teamID: team.ID, challengeID: gameBox.ChallengeID}]
 			gameBoxInfo = append(gameBoxInfo, &GameBoxInfo{
 				ChallengeID: gameBox.ChallengeID,
 				IsCaptured:  gameBox.IsCaptured,
 				IsDown:      gameBox.IsDown,
+				FirstBlood:  isFirstBlood,
 				Score:       gameBox.Score,
 			})
 		}
@@ -0,0 +1,193 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var _ RankHistoriesStore = (*rankHistories)(nil)
+
+// RankHistories is the default instance of the RankHistoriesStore.
+var RankHistories RankHistoriesStore
+
+// RankHistoriesStore is the persistent interface for rank histories.
+type RankHistoriesStore interface {
+	// Snapshot saves every team's score, rank and game box scores at the end of the given round.
+	// The previous snapshot of the same round will be replaced.
+	Snapshot(ctx context.Context, round uint) error
+	// LatestRound returns the latest round which has been snapshotted before the given time.
+	// The time is not limited if it is zero. It returns zero if there is no snapshot.
+	LatestRound(ctx context.Context, before time.Time) (uint, error)
+	// Get returns the rank histories with the given options, ordered by round and rank.
+	Get(ctx context.Context, opts GetRankHistoryOptions) ([]*RankHistory, error)
+	// DeleteAll deletes all the rank histories.
+	DeleteAll(ctx context.Context) error
+}
+
+// NewRankHistoriesStore returns a RankHistoriesStore instance with the given database connection.
+func NewRankHistoriesStore(db *gorm.DB) RankHistoriesStore {
+	return &rankHistories{DB: db}
+}
+
+// RankHistory represents the score and rank of a team at the end of a round.
+type RankHistory struct {
+	gorm.Model
+
+	Round  uint `gorm:"uniqueIndex:rank_history_unique_idx"`
+	TeamID uint `gorm:"uniqueIndex:rank_history_unique_idx"`
+	Rank   uint
+	Score  float64
+
+	GameBoxes []*GameBoxHistory `gorm:"-"` // Ordered by challenge ID.
+}
+
+// GameBoxHistory represents the score of a game box at the end of a round.
+type GameBoxHistory struct {
+	gorm.Model
+
+	Round       uint `gorm:"uniqueIndex:game_box_history_unique_idx"`
+	TeamID      uint
+	ChallengeID uint
+	GameBoxID   uint `gorm:"uniqueIndex:game_box_history_unique_idx"`
+	Score       float64
+}
+
+type rankHistories struct {
+	*gorm.DB
+}
+
+func (db *rankHistories) Snapshot(ctx context.Context, round uint) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		gameBoxes, err := NewGameBoxesStore(tx).Get(ctx, GetGameBoxesOption{
+			Visible: true,
+		})
+		if err != nil {
+			return errors.Wrap(err, "get game boxes")
+		}
+
+		// The round may be calculated again, remove the previous snapshot of this round.
+		if err := tx.Unscoped().Where("round = ?", round).Delete(&RankHistory{}).Error; err != nil {
+			return errors.Wrap(err, "delete previous rank histories")
+		}
+		if err := tx.Unscoped().Where("round = ?", round).Delete(&GameBoxHistory{}).Error; err != nil {
+			return errors.Wrap(err, "delete previous game box histories")
+		}
+
+		// The teams are ranked after the previous snapshot is removed,
+		// as the rank tie breaker may depend on the snapshots of the previous rounds.
+		teams, err := NewTeamsStore(tx).Get(ctx, GetTeamsOptions{
+			OrderBy: "score",
+			Order:   "DESC",
+		})
+		if err != nil {
+			return errors.Wrap(err, "get teams")
+		}
+
+		if len(teams) != 0 {
+			rankHistories := make([]*RankHistory, 0, len(teams))
+			for _, team := range teams {
+				rankHistories = append(rankHistories, &RankHistory{
+					Round:  round,
+					TeamID: team.ID,
+					Rank:   team.Rank,
+					Score:  team.Score,
+				})
+			}
+			if err := tx.CreateInBatches(rankHistories, len(rankHistories)).Error; err != nil {
+				return errors.Wrap(err, "create rank histories")
+			}
+		}
+
+		if len(gameBoxes) != 0 {
+			gameBoxHistories := make([]*GameBoxHistory, 0, len(gameBoxes))
+			for _, gameBox := range gameBoxes {
+				gameBoxHistories = append(gameBoxHistories, &GameBoxHistory{
+					Round:       round,
+					TeamID:      gameBox.TeamID,
+					ChallengeID: gameBox.ChallengeID,
+					GameBoxID:   gameBox.ID,
+					Score:       gameBox.Score,
+				})
+			}
+			if err := tx.CreateInBatches(gameBoxHistories, len(gameBoxHistories)).Error; err != nil {
+				return errors.Wrap(err, "create game box histories")
+			}
+		}
+
+		return nil
+	})
+}
+
+func (db *rankHistories) LatestRound(ctx context.Context, before time.Time) (uint, error) {
+	var latest struct {
+		Round uint
+	}
+
+	q := db.WithContext(ctx).Model(&RankHistory{}).Select(`COALESCE(MAX(round), 0) AS round`)
+	if !before.IsZero() {
+		q = q.Where("created_at < ?", before)
+	}
+	return latest.Round, q.Find(&latest).Error
+}
+
+type GetRankHistoryOptions struct {
+	TeamID     uint
+	StartRound uint
+	EndRound   uint // If EndRound is zero, it returns the histories until the latest round.
+}
+
+func (db *rankHistories) Get(ctx context.Context, opts GetRankHistoryOptions) ([]*RankHistory, error) {
+	rankQuery := db.WithContext(ctx).Model(&RankHistory{}).Where(&RankHistory{TeamID: opts.TeamID})
+	gameBoxQuery := db.WithContext(ctx).Model(&GameBoxHistory{}).Where(&GameBoxHistory{TeamID: opts.TeamID})
+	if opts.StartRound != 0 {
+		rankQuery = rankQuery.Where("round >= ?", opts.StartRound)
+		gameBoxQuery = gameBoxQuery.Where("round >= ?", opts.StartRound)
+	}
+	if opts.EndRound != 0 {
+		rankQuery = rankQuery.Where("round <= ?", opts.EndRound)
+		gameBoxQuery = gameBoxQuery.Where("round <= ?", opts.EndRound)
+	}
+
+	var rankHistories []*RankHistory
+	if err := rankQuery.Order("round ASC, rank ASC, team_id ASC").Find(&rankHistories).Error; err != nil {
+		return nil, errors.Wrap(err, "get rank histories")
+	}
+
+	var gameBoxHistories []*GameBoxHistory
+	if err := gameBoxQuery.Order("round ASC, challenge_id ASC").Find(&gameBoxHistories).Error; err != nil {
+		return nil, errors.Wrap(err, "get game box histories")
+	}
+
+	type key struct {
+		round  uint
+		teamID uint
+	}
+	rankHistorySets := make(map[key]*RankHistory, len(rankHistories))
+	for _, rankHistory := range rankHistories {
+		rankHistory.GameBoxes = []*GameBoxHistory{}
+		rankHistorySets[key{round: rankHistory.Round, teamID: rankHistory.TeamID}] = rankHistory
+	}
+	for _, gameBoxHistory := range gameBoxHistories {
+		rankHistory, ok := rankHistorySets[key{round: gameBoxHistory.Round, teamID: gameBoxHistory.TeamID}]
+		if !ok {
+			continue
+		}
+		rankHistory.GameBoxes = append(rankHistory.GameBoxes, gameBoxHistory)
+	}
+
+	return rankHistories, nil
+}
+
+func (db *rankHistories) DeleteAll(ctx context.Context) error {
+	if err := db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&GameBoxHistory{}).Error; err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&RankHistory{}).Error
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRankHistories(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	t.Parallel()
+
+	db, cleanup := newTestDB(t)
+	rankHistoriesStore := NewRankHistoriesStore(db)
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, db *rankHistories)
+	}{
+		{"Snapshot", testRankHistoriesSnapshot},
+		{"LatestRound", testRankHistoriesLatestRound},
+		{"Get", testRankHistoriesGet},
+		{"DeleteAll", testRankHistoriesDeleteAll},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := cleanup("teams", "challenges", "game_boxes", "rank_histories", "game_box_histories")
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			ctx := context.Background()
+			// Create two teams.
+			teamsStore := NewTeamsStore(db)
+			_, err := teamsStore.Create(ctx, CreateTeamOptions{Name: "Vidar"})
+			assert.Nil(t, err)
+			_, err = teamsStore.Create(ctx, CreateTeamOptions{Name: "E99p1ant"})
+			assert.Nil(t, err)
+
+			// Create two challenges.
+			challengesStore := NewChallengesStore(db)
+			_, err = challengesStore.Create(ctx, CreateChallengeOptions{Title: "Web1", BaseScore: 1000})
+			assert.Nil(t, err)
+			_, err = challengesStore.Create(ctx, CreateChallengeOptions{Title: "Pwn1", BaseScore: 1000})
+			assert.Nil(t, err)
+
+			// Create game boxes for each team and challenge.
+			gameBoxesStore := NewGameBoxesStore(db)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 1, ChallengeID: 1, IPAddress: "192.168.1.1", Port: 80, Description: "Web1 For Vidar"})
+			assert.Nil(t, err)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 1, ChallengeID: 2, IPAddress: "192.168.2.1", Port: 8080, Description: "Pwn1 For Vidar"})
+			assert.Nil(t, err)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 2, ChallengeID: 1, IPAddress: "192.168.1.2", Port: 80, Description: "Web1 For E99p1ant"})
+			assert.Nil(t, err)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 2, ChallengeID: 2, IPAddress: "192.168.2.2", Port: 8080, Description: "Pwn1 For E99p1ant"})
+			assert.Nil(t, err)
+			for i := uint(1); i <= 4; i++ {
+				err := gameBoxesStore.SetVisible(ctx, i, true)
+				assert.Nil(t, err)
+			}
+
+			tc.test(t, ctx, rankHistoriesStore.(*rankHistories))
+		})
+	}
+}
+
+// setRankHistoryScore sets the game box scores and refreshes the team scores.
+func setRankHistoryScore(t *testing.T, ctx context.Context, db *gorm.DB, scores ...float64) {
+	gameBoxesStore := NewGameBoxesStore(db)
+	for i, score := range scores {
+		err := gameBoxesStore.SetScore(ctx, uint(i+1), score)
+		assert.Nil(t, err)
+	}
+
+	err := NewScoresStore(db).RefreshTeamScore(ctx)
+	assert.Nil(t, err)
+}
+
+func testRankHistoriesSnapshot(t *testing.T, ctx context.Context, db *rankHistories) {
+	setRankHistoryScore(t, ctx, db.DB, 1000, 1000, 900, 1000)
+	err := db.Snapshot(ctx, 1)
+	assert.Nil(t, err)
+
+	// Snapshot the same round again will replace the previous one.
+	setRankHistoryScore(t, ctx, db.DB, 1000, 900, 1100, 1000)
+	err = db.Snapshot(ctx, 1)
+	assert.Nil(t, err)
+
+	got, err := db.Get(ctx, GetRankHistoryOptions{})
+	assert.Nil(t, err)
+	clearRankHistoryModel(got)
+
+	want := []*RankHistory{
+		{
+			Round: 1, TeamID: 2, Rank: 1, Score: 2100,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 1, TeamID: 2, ChallengeID: 1, GameBoxID: 3, Score: 1100},
+				{Round: 1, TeamID: 2, ChallengeID: 2, GameBoxID: 4, Score: 1000},
+			},
+		},
+		{
+			Round: 1, TeamID: 1, Rank: 2, Score: 1900,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 1, TeamID: 1, ChallengeID: 1, GameBoxID: 1, Score: 1000},
+				{Round: 1, TeamID: 1, ChallengeID: 2, GameBoxID: 2, Score: 900},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func testRankHistoriesLatestRound(t *testing.T, ctx context.Context, db *rankHistories) {
+	got, err := db.LatestRound(ctx, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), got)
+
+	setRankHistoryScore(t, ctx, db.DB, 1000, 1000, 1000, 1000)
+	err = db.Snapshot(ctx, 1)
+	assert.Nil(t, err)
+	err = db.Snapshot(ctx, 2)
+	assert.Nil(t, err)
+
+	got, err = db.LatestRound(ctx, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), got)
+
+	// The snapshots are created after the time.
+	got, err = db.LatestRound(ctx, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), got)
+}
+
+func testRankHistoriesGet(t *testing.T, ctx context.Context, db *rankHistories) {
+	setRankHistoryScore(t, ctx, db.DB, 1000, 1000, 1000, 1000)
+	err := db.Snapshot(ctx, 1)
+	assert.Nil(t, err)
+	setRankHistoryScore(t, ctx, db.DB, 1100, 1000, 900, 1000)
+	err = db.Snapshot(ctx, 2)
+	assert.Nil(t, err)
+	setRankHistoryScore(t, ctx, db.DB, 1100, 900, 900, 1100)
+	err = db.Snapshot(ctx, 3)
+	assert.Nil(t, err)
+
+	got, err := db.Get(ctx, GetRankHistoryOptions{TeamID: 1})
+	assert.Nil(t, err)
+	clearRankHistoryModel(got)
+
+	want := []*RankHistory{
+		{
+			Round: 1, TeamID: 1, Rank: 1, Score: 2000,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 1, TeamID: 1, ChallengeID: 1, GameBoxID: 1, Score: 1000},
+				{Round: 1, TeamID: 1, ChallengeID: 2, GameBoxID: 2, Score: 1000},
+			},
+		},
+		{
+			Round: 2, TeamID: 1, Rank: 1, Score: 2100,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 2, TeamID: 1, ChallengeID: 1, GameBoxID: 1, Score: 1100},
+				{Round: 2, TeamID: 1, ChallengeID: 2, GameBoxID: 2, Score: 1000},
+			},
+		},
+		{
+			Round: 3, TeamID: 1, Rank: 1, Score: 2000,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 3, TeamID: 1, ChallengeID: 1, GameBoxID: 1, Score: 1100},
+				{Round: 3, TeamID: 1, ChallengeID: 2, GameBoxID: 2, Score: 900},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+
+	got, err = db.Get(ctx, GetRankHistoryOptions{StartRound: 2, EndRound: 2})
+	assert.Nil(t, err)
+	clearRankHistoryModel(got)
+
+	want = []*RankHistory{
+		{
+			Round: 2, TeamID: 1, Rank: 1, Score: 2100,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 2, TeamID: 1, ChallengeID: 1, GameBoxID: 1, Score: 1100},
+				{Round: 2, TeamID: 1, ChallengeID: 2, GameBoxID: 2, Score: 1000},
+			},
+		},
+		{
+			Round: 2, TeamID: 2, Rank: 2, Score: 1900,
+			GameBoxes: []*GameBoxHistory{
+				{Round: 2, TeamID: 2, ChallengeID: 1, GameBoxID: 3, Score: 900},
+				{Round: 2, TeamID: 2, ChallengeID: 2, GameBoxID: 4, Score: 1000},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func testRankHistoriesDeleteAll(t *testing.T, ctx context.Context, db *rankHistories) {
+	setRankHistoryScore(t, ctx, db.DB, 1000, 1000, 1000, 1000)
+	err := db.Snapshot(ctx, 1)
+	assert.Nil(t, err)
+
+	err = db.DeleteAll(ctx)
+	assert.Nil(t, err)
+
+	got, err := db.Get(ctx, GetRankHistoryOptions{})
+	assert.Nil(t, err)
+	want := []*RankHistory{}
+	assert.Equal(t, want, got)
+}
+
+// clearRankHistoryModel clears the gorm.Model fields of the given rank histories to make them comparable.
+func clearRankHistoryModel(rankHistories []*RankHistory) {
+	for _, rankHistory := range rankHistories {
+		rankHistory.Model = gorm.Model{}
+		for _, gameBox := range rankHistory.GameBoxes {
+			gameBox.Model = gorm.Model{}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"Cardinal/internal/conf"
+)
+
+// RankTieBreaker decides the order of the teams with the same score.
+type RankTieBreaker string
+
+// The tie breakers are checked when the configuration is loaded.
+const (
+	RankTieBreakerScoreReachedAt RankTieBreaker = conf.RankTieBreakerScoreReachedAt
+	RankTieBreakerFewestDowns    RankTieBreaker = conf.RankTieBreakerFewestDowns
+	RankTieBreakerMostCaptures   RankTieBreaker = conf.RankTieBreakerMostCaptures
+)
+
+// scoreEpsilon is the tolerance when comparing the float scores,
+// for the attack and service online scores are divided.
+const scoreEpsilon = 1e-6
+
+func scoreEqual(a, b float64) bool {
+	return math.Abs(a-b) < scoreEpsilon
+}
+
+// TeamRankKey contains the keys to order the team in the ranking list.
+// The tie breakers are compared in turn when the scores are the same, the lower one ranks higher.
+type TeamRankKey struct {
+	TeamID      uint
+	Score       float64
+	TieBreakers []float64
+}
+
+// equal returns whether the teams share the same rank.
+func (k *TeamRankKey) equal(other *TeamRankKey) bool {
+	if !scoreEqual(k.Score, other.Score) {
+		return false
+	}
+	for i := range k.TieBreakers {
+		if k.TieBreakers[i] != other.TieBreakers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// higher returns whether the team ranks higher than the other one.
+func (k *TeamRankKey) higher(other *TeamRankKey) bool {
+	if !scoreEqual(k.Score, other.Score) {
+		return k.Score > other.Score
+	}
+	for i := range k.TieBreakers {
+		if k.TieBreakers[i] != other.TieBreakers[i] {
+			return k.TieBreakers[i] < other.TieBreakers[i]
+		}
+	}
+	return false
+}
+
+// TeamRanking is the ranking of the teams.
+type TeamRanking struct {
+	// Order is the team IDs in the rank order, the teams sharing the same rank are ordered by the ID.
+	Order []uint
+	Ranks map[uint]uint
+}
+
+// Position returns the index of the teams in the rank order.
+func (r *TeamRanking) Position() map[uint]int {
+	position := make(map[uint]int, len(r.Order))
+	for i, teamID := range r.Order {
+		position[teamID] = i
+	}
+	return position
+}
+
+// setRanks sets the rank of the given teams.
+func (r *TeamRanking) setRanks(teams ...*Team) {
+	for _, team := range teams {
+		team.Rank = r.Ranks[team.ID]
+	}
+}
+
+// RankTeams orders the teams by the keys. The teams with the same keys share the same rank,
+// and the following ranks are skipped, e.g. 1, 2, 2, 4.
+func RankTeams(keys []*TeamRankKey) *TeamRanking {
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].higher(keys[j]) {
+			return true
+		}
+		if keys[j].higher(keys[i]) {
+			return false
+		}
+		return keys[i].TeamID < keys[j].TeamID
+	})
+
+	ranking := &TeamRanking{
+		Order: make([]uint, 0, len(keys)),
+		Ranks: make(map[uint]uint, len(keys)),
+	}
+	for i, key := range keys {
+		rank := uint(i + 1)
+		if i > 0 && key.equal(keys[i-1]) {
+			rank = ranking.Ranks[keys[i-1].TeamID]
+		}
+		ranking.Order = append(ranking.Order, key.TeamID)
+		ranking.Ranks[key.TeamID] = rank
+	}
+	return ranking
+}
+
+// ScoreSnapshot is the score of the team at the end of a round.
+type ScoreSnapshot struct {
+	TeamID uint
+	Round  int
+	Score  float64
+}
+
+// ScoreReachedAt returns the round in which each team reached its current score,
+// the snapshots should be ordered by the round descending.
+// The team which has no snapshot with its current score reached the score in the latest round.
+func ScoreReachedAt(scores map[uint]float64, snapshots []*ScoreSnapshot) map[uint]float64 {
+	values := make(map[uint]float64, len(scores))
+	for teamID := range scores {
+		values[teamID] = math.MaxUint32
+	}
+
+	// Walk back the snapshots until the score of the team is different from the current one.
+	changed := make(map[uint]bool, len(scores))
+	for _, snapshot := range snapshots {
+		score, ok := scores[snapshot.TeamID]
+		if !ok || changed[snapshot.TeamID] {
+			continue
+		}
+		if !scoreEqual(snapshot.Score, score) {
+			changed[snapshot.TeamID] = true
+			continue
+		}
+		values[snapshot.TeamID] = float64(snapshot.Round)
+	}
+	return values
+}
+
+// getTeamRanking computes the ranking of the given teams with the tie breakers in the config,
+// only the ID and the score of the teams are used.
+func getTeamRanking(ctx context.Context, db *gorm.DB, teams []*Team) (*TeamRanking, error) {
+	keys := make([]*TeamRankKey, 0, len(teams))
+	for _, team := range teams {
+		keys = append(keys, &TeamRankKey{
+			TeamID:      team.ID,
+			Score:       team.Score,
+			TieBreakers: make([]float64, 0, len(conf.Game.RankTieBreakers)),
+		})
+	}
+
+	for _, tieBreaker := range conf.Game.RankTieBreakers {
+		values, err := getTieBreakerValues(ctx, db, RankTieBreaker(tieBreaker), teams)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get %q tie breaker", tieBreaker)
+		}
+		for _, key := range keys {
+			key.TieBreakers = append(key.TieBreakers, values[key.TeamID])
+		}
+	}
+
+	return RankTeams(keys), nil
+}
+
+// getTieBreakerValues returns the tie breaker value of the given teams, the lower one ranks higher.
+func getTieBreakerValues(ctx context.Context, db *gorm.DB, tieBreaker RankTieBreaker, teams []*Team) (map[uint]float64, error) {
+	teamIDs := make([]uint, 0, len(teams))
+	for _, team := range teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	switch tieBreaker {
+	case RankTieBreakerScoreReachedAt:
+		var snapshots []*ScoreSnapshot
+		if err := db.WithContext(ctx).Model(&RankHistory{}).
+			Select("team_id", "round", "score").
+			Where("team_id IN ?", teamIDs).
+			Order("round DESC").
+			Scan(&snapshots).Error; err != nil {
+			return nil, errors.Wrap(err, "get rank histories")
+		}
+
+		scores := make(map[uint]float64, len(teams))
+		for _, team := range teams {
+			scores[team.ID] = team.Score
+		}
+		return ScoreReachedAt(scores, snapshots), nil
+
+	case RankTieBreakerFewestDowns, RankTieBreakerMostCaptures:
+		actionType, teamColumn, sign := ActionTypeCheckDown, "team_id", 1.0
+		if tieBreaker == RankTieBreakerMostCaptures {
+			actionType, teamColumn, sign = ActionTypeBeenAttack, "attacker_team_id", -1.0
+		}
+
+		var counts []struct {
+			TeamID uint
+			Count  int
+		}
+		if err := db.WithContext(ctx).Model(&Action{}).
+			Select(teamColumn+" AS team_id, COUNT(*) AS count").
+			Where("type = ? AND "+teamColumn+" IN ?", actionType, teamIDs).
+			Group(teamColumn).
+			Scan(&counts).Error; err != nil {
+			return nil, errors.Wrap(err, "count actions")
+		}
+
+		values := make(map[uint]float64, len(teams))
+		for _, count := range counts {
+			values[count.TeamID] = sign * float64(count.Count)
+		}
+		return values, nil
+
+	default:
+		return nil, errors.Errorf("unexpected rank tie breaker %q", tieBreaker)
+	}
+}
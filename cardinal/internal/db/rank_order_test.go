@@ -0,0 +1,87 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankTeams(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		keys      []*TeamRankKey
+		wantOrder []uint
+		wantRanks map[uint]uint
+	}{
+		{
+			name:      "empty",
+			keys:      []*TeamRankKey{},
+			wantOrder: []uint{},
+			wantRanks: map[uint]uint{},
+		},
+		{
+			name: "same score share the rank",
+			keys: []*TeamRankKey{
+				{TeamID: 1, Score: 900},
+				{TeamID: 2, Score: 1000},
+				{TeamID: 3, Score: 1000},
+				{TeamID: 4, Score: 800},
+			},
+			wantOrder: []uint{2, 3, 1, 4},
+			wantRanks: map[uint]uint{2: 1, 3: 1, 1: 3, 4: 4},
+		},
+		{
+			name: "tie breakers in turn",
+			keys: []*TeamRankKey{
+				{TeamID: 1, Score: 1000, TieBreakers: []float64{3, 2}},
+				{TeamID: 2, Score: 1000, TieBreakers: []float64{3, 1}},
+				{TeamID: 3, Score: 1000, TieBreakers: []float64{2, 5}},
+				{TeamID: 4, Score: 1000, TieBreakers: []float64{3, 1}},
+				{TeamID: 5, Score: 1200, TieBreakers: []float64{9, 9}},
+			},
+			wantOrder: []uint{5, 3, 2, 4, 1},
+			wantRanks: map[uint]uint{5: 1, 3: 2, 2: 3, 4: 3, 1: 5},
+		},
+		{
+			name: "score epsilon",
+			keys: []*TeamRankKey{
+				{TeamID: 1, Score: 0.1 + 0.2},
+				{TeamID: 2, Score: 0.3},
+			},
+			wantOrder: []uint{1, 2},
+			wantRanks: map[uint]uint{1: 1, 2: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RankTeams(tc.keys)
+			assert.Equal(t, tc.wantOrder, got.Order)
+			assert.Equal(t, tc.wantRanks, got.Ranks)
+		})
+	}
+}
+
+func TestScoreReachedAt(t *testing.T) {
+	got := ScoreReachedAt(
+		map[uint]float64{1: 1000, 2: 1000, 3: 900},
+		[]*ScoreSnapshot{
+			{TeamID: 1, Round: 3, Score: 1000},
+			{TeamID: 2, Round: 3, Score: 950},
+			{TeamID: 1, Round: 2, Score: 1000},
+			{TeamID: 2, Round: 2, Score: 1000},
+			{TeamID: 1, Round: 1, Score: 800},
+			{TeamID: 2, Round: 1, Score: 1000},
+		},
+	)
+	assert.Equal(t, map[uint]float64{1: 2, 2: math.MaxUint32, 3: math.MaxUint32}, got)
+}
+
+func TestGetTieBreakerValues(t *testing.T) {
+	_, err := getTieBreakerValues(context.Background(), nil, "most_flags", nil)
+	assert.NotNil(t, err)
+}
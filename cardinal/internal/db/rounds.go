@@ -0,0 +1,116 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ RoundsStore = (*rounds)(nil)
+
+// Rounds is the default instance of the RoundsStore.
+var Rounds RoundsStore
+
+// RoundsStore is the persistent interface for rounds.
+type RoundsStore interface {
+	// Get returns all the rounds ordered by the round number.
+	Get(ctx context.Context) ([]*Round, error)
+	// GetByRound returns the round with the given round number.
+	// It returns ErrRoundNotExists when not found.
+	GetByRound(ctx context.Context, round uint) (*Round, error)
+	// GetUnscored returns the rounds which have ended but not been scored, ordered by the round number.
+	GetUnscored(ctx context.Context) ([]*Round, error)
+	// Start records the start time and the score multiplier of the round,
+	// they will not be changed once recorded.
+	Start(ctx context.Context, round uint, at time.Time, scoreMultiplier float64) error
+	// End records the end time of the round, the time which has been recorded will not be changed.
+	End(ctx context.Context, round uint, at time.Time) error
+	// DeleteAll deletes all the rounds.
+	DeleteAll(ctx context.Context) error
+}
+
+// NewRoundsStore returns a RoundsStore instance with the given database connection.
+func NewRoundsStore(db *gorm.DB) RoundsStore {
+	return &rounds{DB: db}
+}
+
+// Round represents a game round, it records the round transitions and whether the round has been scored.
+type Round struct {
+	gorm.Model
+
+	Round           uint `gorm:"uniqueIndex"`
+	StartedAt       *time.Time
+	EndedAt         *time.Time
+	ScoredAt        *time.Time
+	ScoringDuration time.Duration
+	// ScoreMultiplier multiplies the score of the round, it is 1 if zero.
+	ScoreMultiplier float64
+}
+
+type rounds struct {
+	*gorm.DB
+}
+
+func (db *rounds) Get(ctx context.Context) ([]*Round, error) {
+	var rounds []*Round
+	return rounds, db.WithContext(ctx).Model(&Round{}).Order("round ASC").Find(&rounds).Error
+}
+
+var ErrRoundNotExists = errors.New("round does not exist")
+
+func (db *rounds) GetByRound(ctx context.Context, round uint) (*Round, error) {
+	var r Round
+	if err := db.WithContext(ctx).Model(&Round{}).Where("round = ?", round).First(&r).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrRoundNotExists
+		}
+		return nil, errors.Wrap(err, "get")
+	}
+	return &r, nil
+}
+
+func (db *rounds) GetUnscored(ctx context.Context) ([]*Round, error) {
+	var rounds []*Round
+	return rounds, db.WithContext(ctx).Model(&Round{}).
+		Where("ended_at IS NOT NULL AND scored_at IS NULL").
+		Order("round ASC").Find(&rounds).Error
+}
+
+func (db *rounds) Start(ctx context.Context, round uint, at time.Time, scoreMultiplier float64) error {
+	return db.record(ctx, round, "started_at", map[string]interface{}{
+		"started_at":       at,
+		"score_multiplier": scoreMultiplier,
+	})
+}
+
+func (db *rounds) End(ctx context.Context, round uint, at time.Time) error {
+	return db.record(ctx, round, "ended_at", map[string]interface{}{
+		"ended_at": at,
+	})
+}
+
+// record updates the round with the values if the time column has not been set, the round is created if not exists.
+func (db *rounds) record(ctx context.Context, round uint, column string, values map[string]interface{}) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Round{Round: round}).Error; err != nil {
+			return errors.Wrap(err, "create round")
+		}
+
+		if err := tx.Model(&Round{}).Where("round = ? AND "+column+" IS NULL", round).Updates(values).Error; err != nil {
+			return errors.Wrapf(err, "update %s", column)
+		}
+		return nil
+	})
+}
+
+func (db *rounds) DeleteAll(ctx context.Context) error {
+	// The rounds are deleted permanently, or the soft deleted rows will conflict with the unique round index.
+	return db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&Round{}).Error
+}
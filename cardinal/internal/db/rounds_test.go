@@ -0,0 +1,159 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRounds(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	t.Parallel()
+
+	db, cleanup := newTestDB(t)
+	roundsStore := NewRoundsStore(db)
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, db *rounds)
+	}{
+		{"Get", testRoundsGet},
+		{"GetByRound", testRoundsGetByRound},
+		{"GetUnscored", testRoundsGetUnscored},
+		{"Start", testRoundsStart},
+		{"End", testRoundsEnd},
+		{"DeleteAll", testRoundsDeleteAll},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := cleanup("rounds")
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+			tc.test(t, context.Background(), roundsStore.(*rounds))
+		})
+	}
+}
+
+func testRoundsGet(t *testing.T, ctx context.Context, db *rounds) {
+	got, err := db.Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(got))
+
+	scoresStore := NewScoresStore(db.DB)
+	err = scoresStore.Calculate(ctx, 2)
+	assert.Nil(t, err)
+	err = scoresStore.Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	got, err = db.Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, uint(1), got[0].Round)
+	assert.Equal(t, uint(2), got[1].Round)
+}
+
+func testRoundsGetByRound(t *testing.T, ctx context.Context, db *rounds) {
+	err := NewScoresStore(db.DB).Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	got, err := db.GetByRound(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), got.Round)
+	assert.NotNil(t, got.ScoredAt)
+
+	_, err = db.GetByRound(ctx, 2)
+	assert.Equal(t, ErrRoundNotExists, err)
+}
+
+func testRoundsGetUnscored(t *testing.T, ctx context.Context, db *rounds) {
+	now := time.Now()
+
+	for round := uint(1); round <= 3; round++ {
+		err := db.Start(ctx, round, now, 1)
+		assert.Nil(t, err)
+	}
+	err := db.End(ctx, 2, now)
+	assert.Nil(t, err)
+	err = db.End(ctx, 1, now)
+	assert.Nil(t, err)
+
+	got, err := db.GetUnscored(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, uint(1), got[0].Round)
+	assert.Equal(t, uint(2), got[1].Round)
+
+	err = NewScoresStore(db.DB).Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	got, err = db.GetUnscored(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, uint(2), got[0].Round)
+}
+
+func testRoundsStart(t *testing.T, ctx context.Context, db *rounds) {
+	now := time.Now()
+
+	err := db.Start(ctx, 1, now, 2)
+	assert.Nil(t, err)
+
+	// The start time which has been recorded will not be changed.
+	err = db.Start(ctx, 1, now.Add(time.Hour), 3)
+	assert.Nil(t, err)
+
+	got, err := db.GetByRound(ctx, 1)
+	assert.Nil(t, err)
+	assert.NotNil(t, got.StartedAt)
+	assert.WithinDuration(t, now, *got.StartedAt, time.Second)
+	assert.Equal(t, float64(2), got.ScoreMultiplier)
+	assert.Nil(t, got.EndedAt)
+	assert.Nil(t, got.ScoredAt)
+}
+
+func testRoundsEnd(t *testing.T, ctx context.Context, db *rounds) {
+	now := time.Now()
+
+	// The round which has been scored can also record the end time.
+	err := NewScoresStore(db.DB).Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	err = db.End(ctx, 1, now)
+	assert.Nil(t, err)
+	err = db.End(ctx, 1, now.Add(time.Hour))
+	assert.Nil(t, err)
+
+	got, err := db.GetByRound(ctx, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, got.StartedAt)
+	assert.NotNil(t, got.EndedAt)
+	assert.WithinDuration(t, now, *got.EndedAt, time.Second)
+	assert.NotNil(t, got.ScoredAt)
+}
+
+func testRoundsDeleteAll(t *testing.T, ctx context.Context, db *rounds) {
+	err := NewScoresStore(db.DB).Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	err = db.DeleteAll(ctx)
+	assert.Nil(t, err)
+
+	got, err := db.Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(got))
+
+	// The round can be scored again after deleted.
+	err = NewScoresStore(db.DB).Calculate(ctx, 1)
+	assert.Nil(t, err)
+}
@@ -6,9 +6,12 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	log "unknwon.dev/clog/v2"
 
 	"Cardinal/internal/conf"
 )
@@ -20,11 +23,16 @@ var Scores ScoresStore
 
 // ScoresStore is the persistent interface for scores.
 type ScoresStore interface {
-	Calculate(ctx context.Context, round uint) error
+	// Calculate calculates the score of the given round, and marks the round as scored.
+	// The round which has been scored will be skipped, unless `force` is true.
+	Calculate(ctx context.Context, round uint, forces ...bool) error
 	RefreshAttackScore(ctx context.Context, round uint, replaces ...bool) error
 	RefreshCheckScore(ctx context.Context, round uint, replaces ...bool) error
 	RefreshGameBoxScore(ctx context.Context) error
 	RefreshTeamScore(ctx context.Context) error
+	// Check verifies the scores are consistent after the given round calculated.
+	// It returns the violations found, the list is empty if everything is fine.
+	Check(ctx context.Context, round uint) ([]*ScoreViolation, error)
 }
 
 // NewScoresStore returns a ScoresStore instance with the given database connection.
@@ -36,87 +44,134 @@ type scores struct {
 	*gorm.DB
 }
 
-// Calculate calculates the score until the given round.
-func (db *scores) Calculate(ctx context.Context, round uint) error {
-	if err := db.RefreshAttackScore(ctx, round); err != nil {
-		return errors.Wrap(err, "refresh attack score")
-	}
+// Calculate calculates the score of the given round in one transaction, and marks the round as scored.
+// It does nothing if the round has been scored, unless `force` is true.
+// The scores are checked after the transaction is committed, the violations are logged as warnings.
+func (db *scores) Calculate(ctx context.Context, round uint, forces ...bool) error {
+	force := len(forces) != 0 && forces[0]
+
+	var scored bool
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Create the round marker if not exists, and lock it to prevent the round from being scored concurrently.
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Round{Round: round}).Error; err != nil {
+			return errors.Wrap(err, "create round")
+		}
 
-	if err := db.RefreshCheckScore(ctx, round); err != nil {
-		return errors.Wrap(err, "refresh check score")
-	}
+		var r Round
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("round = ?", round).First(&r).Error; err != nil {
+			return errors.Wrap(err, "lock round")
+		}
+		if r.ScoredAt != nil && !force {
+			return nil
+		}
 
-	if err := db.RefreshGameBoxScore(ctx); err != nil {
-		return errors.Wrap(err, "refresh game box score")
-	}
+		startAt := time.Now()
+		s := &scores{DB: tx}
+		if err := s.RefreshAttackScore(ctx, round, force); err != nil {
+			return errors.Wrap(err, "refresh attack score")
+		}
 
-	if err := db.RefreshTeamScore(ctx); err != nil {
-		return errors.Wrap(err, "refresh team score")
-	}
+		if err := s.RefreshCheckScore(ctx, round, force); err != nil {
+			return errors.Wrap(err, "refresh check score")
+		}
 
-	// TODO: Logger
+		if err := s.RefreshGameBoxScore(ctx); err != nil {
+			return errors.Wrap(err, "refresh game box score")
+		}
 
-	// TODO: health check
+		if err := s.RefreshTeamScore(ctx); err != nil {
+			return errors.Wrap(err, "refresh team score")
+		}
 
+		if err := tx.Model(&Round{}).Where("id = ?", r.ID).Updates(map[string]interface{}{
+			"scored_at":        tx.NowFunc(),
+			"scoring_duration": time.Since(startAt),
+		}).Error; err != nil {
+			return errors.Wrap(err, "mark round scored")
+		}
+		scored = true
+		return nil
+	})
+	if err != nil || !scored {
+		return err
+	}
+
+	violations, err := db.Check(ctx, round)
+	if err != nil {
+		log.Error("Failed to check the score of round %d: %v", round, err)
+		return nil
+	}
+	for _, violation := range violations {
+		log.Warn("Score check of round %d: %s", round, violation.Message)
+	}
 	return nil
 }
 
 func (db *scores) RefreshAttackScore(ctx context.Context, round uint, replaces ...bool) error {
 	replace := len(replaces) != 0 && replaces[0]
 
-	gameBoxesStore := NewGameBoxesStore(db.DB)
-	gameBoxes, err := gameBoxesStore.Get(ctx, GetGameBoxesOption{})
-	if err != nil {
-		return errors.Wrap(err, "get game boxes")
+	// Only the actions of the existing game boxes will be scored.
+	gameBoxIDs := db.WithContext(ctx).Model(&GameBox{}).Select("id")
+	attackedGameBoxIDs := db.WithContext(ctx).Model(&Action{}).Select("game_box_id").
+		Where("type = ? AND round = ? AND game_box_id IN (?)", ActionTypeBeenAttack, round, gameBoxIDs)
+
+	// Every attacked game box must have been attacked by at least one team.
+	var missingGameBoxIDs []uint
+	if err := db.WithContext(ctx).Model(&Action{}).Distinct("game_box_id").
+		Where("type = ? AND round = ? AND game_box_id IN (?)", ActionTypeBeenAttack, round, gameBoxIDs).
+		Where("game_box_id NOT IN (?)", db.WithContext(ctx).Model(&Action{}).Select("game_box_id").Where("type = ? AND round = ?", ActionTypeAttack, round)).
+		Pluck("game_box_id", &missingGameBoxIDs).Error; err != nil {
+		return errors.Wrap(err, "get game boxes without attack actions")
+	}
+	if len(missingGameBoxIDs) != 0 {
+		return errors.Errorf("unexpected count of attack actions of game box %d: 0", missingGameBoxIDs[0])
 	}
 
-	actionsStore := NewActionsStore(db.DB)
-
-	for _, gameBox := range gameBoxes {
-		// [-] Been attacked score
-		beenAttackedActions, err := actionsStore.Get(ctx, GetActionOptions{
-			Type:      ActionTypeBeenAttack,
-			GameBoxID: gameBox.ID,
-			Round:     round,
-		})
-		if err != nil {
-			return errors.Wrap(err, "get been attacked actions")
-		}
+	// [-] Been attacked score
+	// Every game box can only be deducted once in one round, so only the first been attacked action is scored.
+	var beenAttackActionIDs []uint
+	if err := db.WithContext(ctx).Model(&Action{}).
+		Where("type = ? AND round = ? AND game_box_id IN (?)", ActionTypeBeenAttack, round, gameBoxIDs).
+		Group("game_box_id").Pluck("MIN(id)", &beenAttackActionIDs).Error; err != nil {
+		return errors.Wrap(err, "get been attacked actions")
+	}
+	scoreMultiplier, err := db.scoreMultiplier(ctx, round)
+	if err != nil {
+		return errors.Wrap(err, "get score multiplier")
+	}
+	attackScore := float64(conf.Game.AttackScore) * scoreMultiplier
+	if err := db.setActionsScore(ctx, beenAttackActionIDs, -attackScore, replace); err != nil {
+		return errors.Wrap(err, "set been attacked score")
+	}
 
-		if len(beenAttackedActions) == 0 {
-			continue
-		}
-		beenAttackAction := beenAttackedActions[0]
-		if err := actionsStore.SetScore(ctx, SetActionScoreOptions{
-			ActionID: beenAttackAction.ID,
-			Score:    -float64(conf.Game.AttackScore),
-			Replace:  replace,
-		}); err != nil {
-			return errors.Wrap(err, "set been attacked score")
-		}
+	// [+] Attacked score
+	// The attack score of the game box is divided equally by its attackers,
+	// so the attack actions are grouped by the count of the game box's attackers to be updated together.
+	var attackCounts []struct {
+		GameBoxID uint
+		Count     int
+	}
+	if err := db.WithContext(ctx).Model(&Action{}).Select("game_box_id, COUNT(*) AS count").
+		Where("type = ? AND round = ? AND game_box_id IN (?)", ActionTypeAttack, round, attackedGameBoxIDs).
+		Group("game_box_id").Scan(&attackCounts).Error; err != nil {
+		return errors.Wrap(err, "count attack actions")
+	}
 
-		// [+] Attacked score
-		attackActions, err := actionsStore.Get(ctx, GetActionOptions{
-			Type:      ActionTypeAttack,
-			GameBoxID: gameBox.ID,
-			Round:     round,
-		})
-		if err != nil {
+	countGameBoxIDs := make(map[int][]uint)
+	for _, attackCount := range attackCounts {
+		countGameBoxIDs[attackCount.Count] = append(countGameBoxIDs[attackCount.Count], attackCount.GameBoxID)
+	}
+	for count, ids := range countGameBoxIDs {
+		var attackActionIDs []uint
+		if err := db.WithContext(ctx).Model(&Action{}).
+			Where("type = ? AND round = ? AND game_box_id IN ?", ActionTypeAttack, round, ids).
+			Pluck("id", &attackActionIDs).Error; err != nil {
 			return errors.Wrap(err, "get attack actions")
 		}
-		if len(attackActions) == 0 {
-			return errors.Errorf("unexpected count of attack actions: %d", len(attackActions))
-		}
 
-		score := float64(conf.Game.AttackScore) / float64(len(attackActions))
-		for _, action := range attackActions {
-			if err := actionsStore.SetScore(ctx, SetActionScoreOptions{
-				ActionID: action.ID,
-				Score:    score,
-				Replace:  replace,
-			}); err != nil {
-				return errors.Wrap(err, "set attack score")
-			}
+		score := attackScore / float64(count)
+		if err := db.setActionsScore(ctx, attackActionIDs, score, replace); err != nil {
+			return errors.Wrap(err, "set attack score")
 		}
 	}
 
@@ -126,142 +181,133 @@ func (db *scores) RefreshAttackScore(ctx context.Context, round uint, replaces .
 func (db *scores) RefreshCheckScore(ctx context.Context, round uint, replaces ...bool) error {
 	replace := len(replaces) != 0 && replaces[0]
 
-	challengesStore := NewChallengesStore(db.DB)
-	gameBoxesStore := NewGameBoxesStore(db.DB)
-	actionsStore := NewActionsStore(db.DB)
+	// Skip the invisible challenges.
+	visibleChallengeIDs := db.WithContext(ctx).Model(&GameBox{}).Distinct("challenge_id").Where("visible = ?", true)
 
-	challenges, err := challengesStore.Get(ctx)
+	// [-] Been checked down
+	var checkDownActions []*Action
+	if err := db.WithContext(ctx).Model(&Action{}).
+		Where("type = ? AND round = ? AND challenge_id IN (?)", ActionTypeCheckDown, round, visibleChallengeIDs).
+		Find(&checkDownActions).Error; err != nil {
+		return errors.Wrap(err, "get check down actions")
+	}
+
+	checkDownActionIDs := make([]uint, 0, len(checkDownActions))
+	// We need save the check down game box IDs into a map,
+	// for we can get the service online game boxes when traversal all the game boxes.
+	checkDownGameBoxIDs := make(map[uint]struct{}, len(checkDownActions))
+	challengeCheckDownCount := make(map[uint]int)
+	for _, action := range checkDownActions {
+		checkDownActionIDs = append(checkDownActionIDs, action.ID)
+		checkDownGameBoxIDs[action.GameBoxID] = struct{}{}
+		challengeCheckDownCount[action.ChallengeID]++
+	}
+	scoreMultiplier, err := db.scoreMultiplier(ctx, round)
 	if err != nil {
-		return errors.Wrap(err, "get challenges")
+		return errors.Wrap(err, "get score multiplier")
+	}
+	checkDownScore := float64(conf.Game.CheckDownScore) * scoreMultiplier
+	if err := db.setActionsScore(ctx, checkDownActionIDs, -checkDownScore, replace); err != nil {
+		return errors.Wrap(err, "set check down score")
 	}
 
-	for _, challenge := range challenges {
-		// Get the game boxes of the challenge.
-		gameBoxes, err := gameBoxesStore.Get(ctx, GetGameBoxesOption{
-			ChallengeID: challenge.ID,
-		})
-		if err != nil {
-			return errors.Wrap(err, "get game boxes")
-		}
+	// [+] Service online
+	// Remove service online actions of the visible challenges in given round first.
+	if err := db.WithContext(ctx).Unscoped().
+		Where("type = ? AND round = ? AND challenge_id IN (?)", ActionTypeServiceOnline, round, visibleChallengeIDs).
+		Delete(&Action{}).Error; err != nil {
+		return errors.Wrap(err, "delete previous service online actions")
+	}
+
+	var gameBoxes []*GameBox
+	if err := db.WithContext(ctx).Model(&GameBox{}).
+		Where("challenge_id IN (?)", visibleChallengeIDs).
+		Order("id ASC").Find(&gameBoxes).Error; err != nil {
+		return errors.Wrap(err, "get game boxes")
+	}
 
-		// Skip the invisible challenge.
-		if len(gameBoxes) == 0 || !gameBoxes[0].Visible {
+	challengeGameBoxCount := make(map[uint]int)
+	for _, gameBox := range gameBoxes {
+		challengeGameBoxCount[gameBox.ChallengeID]++
+	}
+
+	serviceOnlineActions := make([]*Action, 0, len(gameBoxes))
+	for _, gameBox := range gameBoxes {
+		if _, ok := checkDownGameBoxIDs[gameBox.ID]; ok {
 			continue
 		}
 
-		// Get the game box check down actions of the challenge.
-		checkDownActions, err := actionsStore.Get(ctx, GetActionOptions{
-			Type:        ActionTypeCheckDown,
-			ChallengeID: challenge.ID,
+		checkDownCount := challengeCheckDownCount[gameBox.ChallengeID]
+		serviceOnlineGameBoxCount := challengeGameBoxCount[gameBox.ChallengeID] - checkDownCount
+		serviceOnlineActions = append(serviceOnlineActions, &Action{
+			Type:        ActionTypeServiceOnline,
+			TeamID:      gameBox.TeamID,
+			ChallengeID: gameBox.ChallengeID,
+			GameBoxID:   gameBox.ID,
 			Round:       round,
+			Score:       checkDownScore * float64(checkDownCount) / float64(serviceOnlineGameBoxCount),
 		})
-		if err != nil {
-			return errors.Wrap(err, "get challenge check down boxes")
-		}
-
-		// We need save the check down game box IDs of this challenge into a map,
-		// for we can get the service online game boxes when traversal all the game boxes.
-		checkDownGameBoxIDs := make(map[uint]struct{}, len(checkDownActions))
-		// [-] Been checked down
-		for _, action := range checkDownActions {
-			checkDownGameBoxIDs[action.GameBoxID] = struct{}{}
-
-			if err := actionsStore.SetScore(ctx, SetActionScoreOptions{
-				ActionID: action.ID,
-				Score:    -float64(conf.Game.CheckDownScore),
-				Replace:  replace,
-			}); err != nil {
-				return errors.Wrap(err, "set check down score")
-			}
-		}
-
-		// [+] Service online
-		// Remove service online actions in given round first.
-		if err := actionsStore.Delete(ctx, DeleteActionOptions{
-			Type:  ActionTypeServiceOnline,
-			Round: round,
-		}); err != nil {
-			return errors.Wrap(err, "delete previous service online actions")
-		}
-
-		serviceOnlineGameBoxCount := len(gameBoxes) - len(checkDownActions)
-		score := float64(conf.Game.CheckDownScore*len(checkDownActions)) / float64(serviceOnlineGameBoxCount)
-
-		for _, gameBox := range gameBoxes {
-			if _, ok := checkDownGameBoxIDs[gameBox.ID]; ok {
-				continue
-			}
-
-			action, err := actionsStore.Create(ctx, CreateActionOptions{
-				Type:      ActionTypeServiceOnline,
-				GameBoxID: gameBox.ID,
-				Round:     round,
-			})
-			if err != nil {
-				return errors.Wrap(err, "create service online action")
-			}
-
-			if err := actionsStore.SetScore(ctx, SetActionScoreOptions{
-				ActionID: action.ID,
-				Score:    score,
-				Replace:  true,
-			}); err != nil {
-				return errors.Wrap(err, "set service online action score")
-			}
-		}
+	}
+	if len(serviceOnlineActions) == 0 {
+		return nil
 	}
 
+	if err := db.WithContext(ctx).Create(&serviceOnlineActions).Error; err != nil {
+		return errors.Wrap(err, "create service online actions")
+	}
 	return nil
 }
 
-func (db *scores) RefreshGameBoxScore(ctx context.Context) error {
-	gameBoxesStore := NewGameBoxesStore(db.DB)
-	actionsStore := NewActionsStore(db.DB)
-
-	gameBoxes, err := gameBoxesStore.Get(ctx, GetGameBoxesOption{
-		Visible: true,
-	})
-	if err != nil {
-		return errors.Wrap(err, "get game boxes")
+// scoreMultiplier returns the score multiplier of the given round, it is 1 if the round has no multiplier.
+func (db *scores) scoreMultiplier(ctx context.Context, round uint) (float64, error) {
+	var scoreMultipliers []float64
+	if err := db.WithContext(ctx).Model(&Round{}).Where("round = ?", round).Pluck("score_multiplier", &scoreMultipliers).Error; err != nil {
+		return 0, err
+	}
+	if len(scoreMultipliers) == 0 || scoreMultipliers[0] == 0 {
+		return 1, nil
 	}
+	return scoreMultipliers[0], nil
+}
 
-	for _, gameBox := range gameBoxes {
-		score, err := actionsStore.CountScore(ctx, CountActionScoreOptions{
-			GameBoxID: gameBox.ID,
-		})
-		if err != nil {
-			return errors.Wrap(err, "count game box score")
-		}
+// setActionsScore sets the score of the given actions in one query.
+// The actions whose score is not zero will not be updated, only if `replace` is true.
+func (db *scores) setActionsScore(ctx context.Context, actionIDs []uint, score float64, replace bool) error {
+	if len(actionIDs) == 0 {
+		return nil
+	}
 
-		if err := gameBoxesStore.SetScore(ctx, gameBox.ID, score); err != nil {
-			return errors.Wrap(err, "set game box score")
-		}
+	q := db.WithContext(ctx).Model(&Action{}).Where("id IN ?", actionIDs)
+	if !replace {
+		q = q.Where("score = 0")
 	}
+	return q.Update("score", score).Error
+}
 
+func (db *scores) RefreshGameBoxScore(ctx context.Context) error {
+	// The first blood actions are recorded on the victim's game box,
+	// move their score to the attacker's game box of the same challenge.
+	score := gorm.Expr(`COALESCE((SELECT SUM(actions.score) FROM actions WHERE actions.game_box_id = game_boxes.id AND actions.type <> ? AND actions.deleted_at IS NULL), 0) + `+
+		`COALESCE((SELECT SUM(actions.score) FROM actions WHERE actions.challenge_id = game_boxes.challenge_id AND actions.attacker_team_id = game_boxes.team_id AND actions.type = ? AND actions.deleted_at IS NULL), 0) + `+
+		`COALESCE((SELECT SUM(score_adjustments.score) FROM score_adjustments WHERE score_adjustments.game_box_id = game_boxes.id AND score_adjustments.reverted_at IS NULL AND score_adjustments.deleted_at IS NULL), 0)`,
+		ActionTypeFirstBlood, ActionTypeFirstBlood,
+	)
+
+	if err := db.WithContext(ctx).Model(&GameBox{}).Where("visible = ?", true).Update("score", score).Error; err != nil {
+		return errors.Wrap(err, "update game box score")
+	}
 	return nil
 }
 
 func (db *scores) RefreshTeamScore(ctx context.Context) error {
-	teamsStore := NewTeamsStore(db.DB)
-	teams, err := teamsStore.Get(ctx, GetTeamsOptions{})
-	if err != nil {
-		return errors.Wrap(err, "get teams")
-	}
-
-	gameBoxesStore := NewGameBoxesStore(db.DB)
-	for _, team := range teams {
-		score, err := gameBoxesStore.CountScore(ctx, GameBoxCountScoreOptions{
-			TeamID:  team.ID,
-			Visible: true,
-		})
-		if err != nil {
-			return errors.Wrap(err, "get game box score")
-		}
-
-		if err := teamsStore.SetScore(ctx, team.ID, score); err != nil {
-			return errors.Wrap(err, "set team score")
-		}
+	// The adjustments of the game boxes have been counted in the game box score.
+	score := gorm.Expr(`COALESCE((SELECT SUM(game_boxes.score) FROM game_boxes WHERE game_boxes.team_id = teams.id AND game_boxes.visible = ? AND game_boxes.deleted_at IS NULL), 0) + `+
+		`COALESCE((SELECT SUM(score_adjustments.score) FROM score_adjustments WHERE score_adjustments.team_id = teams.id AND score_adjustments.game_box_id = 0 AND score_adjustments.reverted_at IS NULL AND score_adjustments.deleted_at IS NULL), 0)`,
+		true,
+	)
+
+	if err := db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&Team{}).Update("score", score).Error; err != nil {
+		return errors.Wrap(err, "update team score")
 	}
-
 	return nil
 }
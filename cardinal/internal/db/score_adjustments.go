@@ -0,0 +1,183 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var _ ScoreAdjustmentsStore = (*scoreAdjustments)(nil)
+
+// ScoreAdjustments is the default instance of the ScoreAdjustmentsStore.
+var ScoreAdjustments ScoreAdjustmentsStore
+
+// ScoreAdjustmentsStore is the persistent interface for score adjustments.
+type ScoreAdjustmentsStore interface {
+	// Create creates a new score adjustment and persists to database.
+	// It returns the score adjustment if succeeded.
+	Create(ctx context.Context, opts CreateScoreAdjustmentOptions) (*ScoreAdjustment, error)
+	// Get returns the score adjustments with the given options.
+	Get(ctx context.Context, opts GetScoreAdjustmentsOptions) ([]*ScoreAdjustment, error)
+	// GetByID returns the score adjustment with given id.
+	// It returns ErrScoreAdjustmentNotExists when not found.
+	GetByID(ctx context.Context, id uint) (*ScoreAdjustment, error)
+	// Revert marks the score adjustment with given id as reverted by the given manager.
+	// The reverted score adjustment will not be counted anymore.
+	// It returns ErrScoreAdjustmentReverted if it has been reverted.
+	Revert(ctx context.Context, id, managerID uint) error
+	// CountScore counts the score of the not reverted score adjustments with the given options.
+	CountScore(ctx context.Context, opts CountScoreAdjustmentOptions) (float64, error)
+	// DeleteAll deletes all the score adjustments.
+	DeleteAll(ctx context.Context) error
+}
+
+// NewScoreAdjustmentsStore returns a ScoreAdjustmentsStore instance with the given database connection.
+func NewScoreAdjustmentsStore(db *gorm.DB) ScoreAdjustmentsStore {
+	return &scoreAdjustments{DB: db}
+}
+
+// ScoreAdjustment represents the score awarded or deducted by the manager manually.
+type ScoreAdjustment struct {
+	gorm.Model
+
+	TeamID      uint
+	ChallengeID uint
+	GameBoxID   uint // The adjustment is applied to the team directly if the game box ID is zero.
+	Round       uint
+
+	Score     float64 // Positive for award, negative for deduction.
+	Reason    string
+	ManagerID uint
+
+	RevertedAt        *time.Time
+	RevertedManagerID uint
+}
+
+type scoreAdjustments struct {
+	*gorm.DB
+}
+
+type CreateScoreAdjustmentOptions struct {
+	TeamID    uint
+	GameBoxID uint // The TeamID will be ignored if the GameBoxID is given.
+	Round     uint
+	Score     float64
+	Reason    string
+	ManagerID uint
+}
+
+var ErrScoreAdjustmentZeroScore = errors.New("score adjustment can not be zero")
+
+func (db *scoreAdjustments) Create(ctx context.Context, opts CreateScoreAdjustmentOptions) (*ScoreAdjustment, error) {
+	if opts.Score == 0 {
+		return nil, ErrScoreAdjustmentZeroScore
+	}
+
+	scoreAdjustment := &ScoreAdjustment{
+		TeamID:    opts.TeamID,
+		Round:     opts.Round,
+		Score:     opts.Score,
+		Reason:    opts.Reason,
+		ManagerID: opts.ManagerID,
+	}
+
+	if opts.GameBoxID != 0 {
+		gameBox, err := NewGameBoxesStore(db.DB).GetByID(ctx, opts.GameBoxID)
+		if err != nil {
+			return nil, err
+		}
+		scoreAdjustment.TeamID = gameBox.TeamID
+		scoreAdjustment.ChallengeID = gameBox.ChallengeID
+		scoreAdjustment.GameBoxID = gameBox.ID
+	} else {
+		team, err := NewTeamsStore(db.DB).GetByID(ctx, opts.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		scoreAdjustment.TeamID = team.ID
+	}
+
+	if err := db.WithContext(ctx).Create(scoreAdjustment).Error; err != nil {
+		return nil, errors.Wrap(err, "create score adjustment")
+	}
+	return scoreAdjustment, nil
+}
+
+type GetScoreAdjustmentsOptions struct {
+	TeamID    uint
+	GameBoxID uint
+	Round     uint
+}
+
+func (db *scoreAdjustments) Get(ctx context.Context, opts GetScoreAdjustmentsOptions) ([]*ScoreAdjustment, error) {
+	var scoreAdjustments []*ScoreAdjustment
+	return scoreAdjustments, db.WithContext(ctx).Model(&ScoreAdjustment{}).Where(&ScoreAdjustment{
+		TeamID:    opts.TeamID,
+		GameBoxID: opts.GameBoxID,
+		Round:     opts.Round,
+	}).Order("id ASC").Find(&scoreAdjustments).Error
+}
+
+var ErrScoreAdjustmentNotExists = errors.New("score adjustment does not exist")
+
+func (db *scoreAdjustments) GetByID(ctx context.Context, id uint) (*ScoreAdjustment, error) {
+	var scoreAdjustment ScoreAdjustment
+	if err := db.WithContext(ctx).Model(&ScoreAdjustment{}).Where("id = ?", id).First(&scoreAdjustment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrScoreAdjustmentNotExists
+		}
+		return nil, errors.Wrap(err, "get")
+	}
+	return &scoreAdjustment, nil
+}
+
+var ErrScoreAdjustmentReverted = errors.New("score adjustment has been reverted")
+
+func (db *scoreAdjustments) Revert(ctx context.Context, id, managerID uint) error {
+	scoreAdjustment, err := db.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if scoreAdjustment.RevertedAt != nil {
+		return ErrScoreAdjustmentReverted
+	}
+
+	return db.WithContext(ctx).Model(&ScoreAdjustment{}).Where("id = ?", scoreAdjustment.ID).Updates(map[string]interface{}{
+		"reverted_at":         db.NowFunc(),
+		"reverted_manager_id": managerID,
+	}).Error
+}
+
+type CountScoreAdjustmentOptions struct {
+	TeamID    uint
+	GameBoxID uint
+	// TeamOnly counts the adjustments which applied to the team directly,
+	// the adjustments of the team's game boxes will be excluded.
+	TeamOnly bool
+}
+
+func (db *scoreAdjustments) CountScore(ctx context.Context, opts CountScoreAdjustmentOptions) (float64, error) {
+	var sum struct {
+		Score float64
+	}
+
+	q := db.WithContext(ctx).Model(&ScoreAdjustment{}).Select(`SUM(score) AS score`).Where(&ScoreAdjustment{
+		TeamID:    opts.TeamID,
+		GameBoxID: opts.GameBoxID,
+	}).Where("reverted_at IS NULL")
+	if opts.TeamOnly {
+		q = q.Where("game_box_id = 0")
+	}
+	return sum.Score, q.Find(&sum).Error
+}
+
+func (db *scoreAdjustments) DeleteAll(ctx context.Context) error {
+	return db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&ScoreAdjustment{}).Error
+}
@@ -0,0 +1,252 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreAdjustments(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	t.Parallel()
+
+	db, cleanup := newTestDB(t)
+	scoreAdjustmentsStore := NewScoreAdjustmentsStore(db)
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, db *scoreAdjustments)
+	}{
+		{"Create", testScoreAdjustmentsCreate},
+		{"Get", testScoreAdjustmentsGet},
+		{"GetByID", testScoreAdjustmentsGetByID},
+		{"Revert", testScoreAdjustmentsRevert},
+		{"CountScore", testScoreAdjustmentsCountScore},
+		{"RefreshScore", testScoreAdjustmentsRefreshScore},
+		{"DeleteAll", testScoreAdjustmentsDeleteAll},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := cleanup("teams", "challenges", "game_boxes", "score_adjustments")
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			ctx := context.Background()
+			// Create two teams.
+			teamsStore := NewTeamsStore(db)
+			_, err := teamsStore.Create(ctx, CreateTeamOptions{Name: "Vidar"})
+			assert.Nil(t, err)
+			_, err = teamsStore.Create(ctx, CreateTeamOptions{Name: "E99p1ant"})
+			assert.Nil(t, err)
+
+			// Create a challenge.
+			challengesStore := NewChallengesStore(db)
+			_, err = challengesStore.Create(ctx, CreateChallengeOptions{Title: "Web1", BaseScore: 1000})
+			assert.Nil(t, err)
+
+			// Create game boxes for each team.
+			gameBoxesStore := NewGameBoxesStore(db)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 1, ChallengeID: 1, IPAddress: "192.168.1.1", Port: 80, Description: "Web1 For Vidar"})
+			assert.Nil(t, err)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 2, ChallengeID: 1, IPAddress: "192.168.1.2", Port: 80, Description: "Web1 For E99p1ant"})
+			assert.Nil(t, err)
+
+			tc.test(t, ctx, scoreAdjustmentsStore.(*scoreAdjustments))
+		})
+	}
+}
+
+func testScoreAdjustmentsCreate(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	// Adjust the team score.
+	got, err := db.Create(ctx, CreateScoreAdjustmentOptions{
+		TeamID:    1,
+		Round:     1,
+		Score:     -100,
+		Reason:    "Attack the platform",
+		ManagerID: 1,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), got.TeamID)
+	assert.Equal(t, uint(0), got.GameBoxID)
+	assert.Equal(t, float64(-100), got.Score)
+
+	// Adjust the game box score, the team ID should be ignored.
+	got, err = db.Create(ctx, CreateScoreAdjustmentOptions{
+		TeamID:    1,
+		GameBoxID: 2,
+		Round:     1,
+		Score:     50,
+		Reason:    "Infrastructure failure",
+		ManagerID: 1,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), got.TeamID)
+	assert.Equal(t, uint(1), got.ChallengeID)
+	assert.Equal(t, uint(2), got.GameBoxID)
+
+	// Zero score.
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: 0, Reason: "Nothing", ManagerID: 1})
+	assert.Equal(t, ErrScoreAdjustmentZeroScore, err)
+
+	// Team not found.
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 3, Round: 1, Score: 10, Reason: "Bonus", ManagerID: 1})
+	assert.Equal(t, ErrTeamNotExists, err)
+
+	// Game box not found.
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{GameBoxID: 3, Round: 1, Score: 10, Reason: "Bonus", ManagerID: 1})
+	assert.Equal(t, ErrGameBoxNotExists, err)
+}
+
+func testScoreAdjustmentsGet(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	_, err := db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: -100, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{GameBoxID: 2, Round: 2, Score: 50, Reason: "Infrastructure failure", ManagerID: 1})
+	assert.Nil(t, err)
+
+	got, err := db.Get(ctx, GetScoreAdjustmentsOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(got))
+
+	got, err = db.Get(ctx, GetScoreAdjustmentsOptions{TeamID: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, "Infrastructure failure", got[0].Reason)
+
+	got, err = db.Get(ctx, GetScoreAdjustmentsOptions{Round: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(got))
+}
+
+func testScoreAdjustmentsGetByID(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	_, err := db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: -100, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+
+	got, err := db.GetByID(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Attack the platform", got.Reason)
+	assert.Nil(t, got.RevertedAt)
+
+	_, err = db.GetByID(ctx, 2)
+	assert.Equal(t, ErrScoreAdjustmentNotExists, err)
+}
+
+func testScoreAdjustmentsRevert(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	_, err := db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: -100, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+
+	err = db.Revert(ctx, 1, 2)
+	assert.Nil(t, err)
+
+	got, err := db.GetByID(ctx, 1)
+	assert.Nil(t, err)
+	assert.NotNil(t, got.RevertedAt)
+	assert.Equal(t, uint(2), got.RevertedManagerID)
+
+	// Revert again.
+	err = db.Revert(ctx, 1, 2)
+	assert.Equal(t, ErrScoreAdjustmentReverted, err)
+
+	err = db.Revert(ctx, 2, 2)
+	assert.Equal(t, ErrScoreAdjustmentNotExists, err)
+}
+
+func testScoreAdjustmentsCountScore(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	_, err := db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: -100, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{GameBoxID: 1, Round: 1, Score: 50, Reason: "Infrastructure failure", ManagerID: 1})
+	assert.Nil(t, err)
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 2, Score: 30, Reason: "Bonus challenge", ManagerID: 1})
+	assert.Nil(t, err)
+
+	got, err := db.CountScore(ctx, CountScoreAdjustmentOptions{TeamID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-20), got)
+
+	got, err = db.CountScore(ctx, CountScoreAdjustmentOptions{TeamID: 1, TeamOnly: true})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-70), got)
+
+	got, err = db.CountScore(ctx, CountScoreAdjustmentOptions{GameBoxID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(50), got)
+
+	// The reverted adjustment should not be counted.
+	err = db.Revert(ctx, 1, 1)
+	assert.Nil(t, err)
+	got, err = db.CountScore(ctx, CountScoreAdjustmentOptions{TeamID: 1, TeamOnly: true})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(30), got)
+}
+
+func testScoreAdjustmentsRefreshScore(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	gameBoxesStore := NewGameBoxesStore(db.DB)
+	for i := uint(1); i <= 2; i++ {
+		err := gameBoxesStore.SetVisible(ctx, i, true)
+		assert.Nil(t, err)
+	}
+
+	_, err := db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: -100, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+	_, err = db.Create(ctx, CreateScoreAdjustmentOptions{GameBoxID: 2, Round: 1, Score: 50, Reason: "Infrastructure failure", ManagerID: 1})
+	assert.Nil(t, err)
+
+	scoresStore := NewScoresStore(db.DB)
+	err = scoresStore.RefreshGameBoxScore(ctx)
+	assert.Nil(t, err)
+	err = scoresStore.RefreshTeamScore(ctx)
+	assert.Nil(t, err)
+
+	teamsStore := NewTeamsStore(db.DB)
+	team, err := teamsStore.GetByID(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-100), team.Score)
+
+	team, err = teamsStore.GetByID(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(50), team.Score)
+
+	gameBox, err := gameBoxesStore.GetByID(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(50), gameBox.Score)
+
+	// Revert the adjustments.
+	err = db.Revert(ctx, 1, 1)
+	assert.Nil(t, err)
+	err = db.Revert(ctx, 2, 1)
+	assert.Nil(t, err)
+
+	err = scoresStore.RefreshGameBoxScore(ctx)
+	assert.Nil(t, err)
+	err = scoresStore.RefreshTeamScore(ctx)
+	assert.Nil(t, err)
+
+	team, err = teamsStore.GetByID(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), team.Score)
+
+	team, err = teamsStore.GetByID(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), team.Score)
+}
+
+func testScoreAdjustmentsDeleteAll(t *testing.T, ctx context.Context, db *scoreAdjustments) {
+	_, err := db.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 1, Score: -100, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+
+	err = db.DeleteAll(ctx)
+	assert.Nil(t, err)
+
+	got, err := db.Get(ctx, GetScoreAdjustmentsOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(got))
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type ScoreViolationKind string
+
+const (
+	// ScoreViolationRoundSum means the sum of the round's action scores is not the expected value.
+	ScoreViolationRoundSum ScoreViolationKind = "round_sum"
+	// ScoreViolationAttackMissing means the game box has been attacked but there is no attack action.
+	ScoreViolationAttackMissing ScoreViolationKind = "attack_missing"
+	// ScoreViolationGameBoxScore means the game box score is not equal to the sum of its actions.
+	ScoreViolationGameBoxScore ScoreViolationKind = "game_box_score"
+	// ScoreViolationTeamScore means the team score is not equal to the sum of its visible game boxes.
+	ScoreViolationTeamScore ScoreViolationKind = "team_score"
+)
+
+// ScoreViolation represents a single inconsistency found in the scores.
+type ScoreViolation struct {
+	Kind      ScoreViolationKind
+	Round     uint `json:",omitempty"`
+	TeamID    uint `json:",omitempty"`
+	GameBoxID uint `json:",omitempty"`
+	Expected  float64
+	Actual    float64
+	Message   string
+}
+
+func (db *scores) Check(ctx context.Context, round uint) ([]*ScoreViolation, error) {
+	violations := make([]*ScoreViolation, 0)
+
+	roundViolations, err := db.checkRound(ctx, round)
+	if err != nil {
+		return nil, errors.Wrap(err, "check round")
+	}
+	violations = append(violations, roundViolations...)
+
+	gameBoxViolations, err := db.checkGameBoxScore(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "check game box score")
+	}
+	violations = append(violations, gameBoxViolations...)
+
+	teamViolations, err := db.checkTeamScore(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "check team score")
+	}
+	violations = append(violations, teamViolations...)
+
+	return violations, nil
+}
+
+// checkRound checks the sum of the round's action scores and the attack actions.
+//
+// The attack and check down scores are zero-sum, so the sum of the round should be equal to
+// the first blood bonuses, plus the check down scores of the challenges which all the game boxes were down,
+// for there is no service online game box to share the check down score.
+func (db *scores) checkRound(ctx context.Context, round uint) ([]*ScoreViolation, error) {
+	var actions []*Action
+	if err := db.WithContext(ctx).Model(&Action{}).Where("round = ?", round).Order("id ASC").Find(&actions).Error; err != nil {
+		return nil, errors.Wrap(err, "get round actions")
+	}
+
+	serviceOnlineChallengeIDs := make(map[uint]struct{})
+	attackGameBoxIDs := make(map[uint]struct{})
+	for _, action := range actions {
+		switch action.Type {
+		case ActionTypeServiceOnline:
+			serviceOnlineChallengeIDs[action.ChallengeID] = struct{}{}
+		case ActionTypeAttack:
+			attackGameBoxIDs[action.GameBoxID] = struct{}{}
+		}
+	}
+
+	violations := make([]*ScoreViolation, 0)
+	var actual, expected float64
+	for _, action := range actions {
+		actual += action.Score
+
+		switch action.Type {
+		case ActionTypeFirstBlood:
+			expected += action.Score
+		case ActionTypeCheckDown:
+			if _, ok := serviceOnlineChallengeIDs[action.ChallengeID]; !ok {
+				expected += action.Score
+			}
+		case ActionTypeBeenAttack:
+			if _, ok := attackGameBoxIDs[action.GameBoxID]; !ok {
+				violations = append(violations, &ScoreViolation{
+					Kind:      ScoreViolationAttackMissing,
+					Round:     round,
+					TeamID:    action.TeamID,
+					GameBoxID: action.GameBoxID,
+					Expected:  -action.Score,
+					Message:   fmt.Sprintf("game box %d has been attacked in round %d without attack action", action.GameBoxID, round),
+				})
+			}
+		}
+	}
+
+	if !scoreEqual(actual, expected) {
+		violations = append(violations, &ScoreViolation{
+			Kind:     ScoreViolationRoundSum,
+			Round:    round,
+			Expected: expected,
+			Actual:   actual,
+			Message:  fmt.Sprintf("the sum of round %d scores is %.2f, expected %.2f", round, actual, expected),
+		})
+	}
+	return violations, nil
+}
+
+// checkGameBoxScore checks every visible game box score is equal to the sum of its actions and score adjustments.
+// The sums are counted in Go from the grouped actions, rather than the query used by RefreshGameBoxScore.
+func (db *scores) checkGameBoxScore(ctx context.Context) ([]*ScoreViolation, error) {
+	var gameBoxes []*GameBox
+	if err := db.WithContext(ctx).Model(&GameBox{}).Where("visible = ?", true).Order("id ASC").Find(&gameBoxes).Error; err != nil {
+		return nil, errors.Wrap(err, "get game boxes")
+	}
+
+	var actionScores []struct {
+		GameBoxID uint
+		Score     float64
+	}
+	if err := db.WithContext(ctx).Model(&Action{}).Select("game_box_id, SUM(score) AS score").
+		Where("type <> ?", ActionTypeFirstBlood).
+		Group("game_box_id").Scan(&actionScores).Error; err != nil {
+		return nil, errors.Wrap(err, "count action scores")
+	}
+
+	// The first blood actions are recorded on the victim's game box,
+	// their score belongs to the attacker's game box of the same challenge.
+	var firstBloodScores []struct {
+		ChallengeID    uint
+		AttackerTeamID uint
+		Score          float64
+	}
+	if err := db.WithContext(ctx).Model(&Action{}).Select("challenge_id, attacker_team_id, SUM(score) AS score").
+		Where("type = ?", ActionTypeFirstBlood).
+		Group("challenge_id, attacker_team_id").Scan(&firstBloodScores).Error; err != nil {
+		return nil, errors.Wrap(err, "count first blood scores")
+	}
+
+	var adjustmentScores []struct {
+		GameBoxID uint
+		Score     float64
+	}
+	if err := db.WithContext(ctx).Model(&ScoreAdjustment{}).Select("game_box_id, SUM(score) AS score").
+		Where("game_box_id <> 0 AND reverted_at IS NULL").
+		Group("game_box_id").Scan(&adjustmentScores).Error; err != nil {
+		return nil, errors.Wrap(err, "count adjustment scores")
+	}
+
+	expected := make(map[uint]float64, len(gameBoxes))
+	for _, score := range actionScores {
+		expected[score.GameBoxID] += score.Score
+	}
+	for _, score := range adjustmentScores {
+		expected[score.GameBoxID] += score.Score
+	}
+	type teamChallenge struct {
+		teamID      uint
+		challengeID uint
+	}
+	firstBloods := make(map[teamChallenge]float64, len(firstBloodScores))
+	for _, score := range firstBloodScores {
+		firstBloods[teamChallenge{score.AttackerTeamID, score.ChallengeID}] += score.Score
+	}
+
+	violations := make([]*ScoreViolation, 0)
+	for _, gameBox := range gameBoxes {
+		expected := expected[gameBox.ID] + firstBloods[teamChallenge{gameBox.TeamID, gameBox.ChallengeID}]
+		if !scoreEqual(gameBox.Score, expected) {
+			violations = append(violations, &ScoreViolation{
+				Kind:      ScoreViolationGameBoxScore,
+				TeamID:    gameBox.TeamID,
+				GameBoxID: gameBox.ID,
+				Expected:  expected,
+				Actual:    gameBox.Score,
+				Message:   fmt.Sprintf("game box %d score is %.2f, expected %.2f", gameBox.ID, gameBox.Score, expected),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// checkTeamScore checks every team score is equal to the sum of its visible game boxes and the team's score adjustments.
+func (db *scores) checkTeamScore(ctx context.Context) ([]*ScoreViolation, error) {
+	var teams []*Team
+	if err := db.WithContext(ctx).Model(&Team{}).Select("id", "score").Order("id ASC").Find(&teams).Error; err != nil {
+		return nil, errors.Wrap(err, "get teams")
+	}
+
+	var gameBoxScores []struct {
+		TeamID uint
+		Score  float64
+	}
+	if err := db.WithContext(ctx).Model(&GameBox{}).Select("team_id, SUM(score) AS score").
+		Where("visible = ?", true).
+		Group("team_id").Scan(&gameBoxScores).Error; err != nil {
+		return nil, errors.Wrap(err, "count game box scores")
+	}
+
+	// The adjustments of the game boxes have been counted in the game box score.
+	var adjustmentScores []struct {
+		TeamID uint
+		Score  float64
+	}
+	if err := db.WithContext(ctx).Model(&ScoreAdjustment{}).Select("team_id, SUM(score) AS score").
+		Where("game_box_id = 0 AND reverted_at IS NULL").
+		Group("team_id").Scan(&adjustmentScores).Error; err != nil {
+		return nil, errors.Wrap(err, "count adjustment scores")
+	}
+
+	expected := make(map[uint]float64, len(teams))
+	for _, score := range gameBoxScores {
+		expected[score.TeamID] += score.Score
+	}
+	for _, score := range adjustmentScores {
+		expected[score.TeamID] += score.Score
+	}
+
+	violations := make([]*ScoreViolation, 0)
+	for _, team := range teams {
+		if !scoreEqual(team.Score, expected[team.ID]) {
+			violations = append(violations, &ScoreViolation{
+				Kind:     ScoreViolationTeamScore,
+				TeamID:   team.ID,
+				Expected: expected[team.ID],
+				Actual:   team.Score,
+				Message:  fmt.Sprintf("team %d score is %.2f, expected %.2f", team.ID, team.Score, expected[team.ID]),
+			})
+		}
+	}
+	return violations, nil
+}
@@ -3,3 +3,447 @@
 // license that can be found in the LICENSE file.
 
 package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/conf"
+)
+
+func TestScores(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	// Set the scores before running in parallel, the other tests should not depend on them.
+	conf.Game.AttackScore = 10
+	conf.Game.CheckDownScore = 10
+
+	t.Parallel()
+
+	db, cleanup := newTestDB(t)
+	scoresStore := NewScoresStore(db)
+
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, db *scores)
+	}{
+		{"Calculate", testScoresCalculate},
+		{"RefreshAttackScore", testScoresRefreshAttackScore},
+		{"RefreshCheckScore", testScoresRefreshCheckScore},
+		{"RefreshScore", testScoresRefreshScore},
+		{"ScoreMultiplier", testScoresScoreMultiplier},
+		{"Check", testScoresCheck},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				err := cleanup("teams", "challenges", "game_boxes", "actions", "score_adjustments", "rounds")
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			ctx := context.Background()
+			// Create two teams.
+			teamsStore := NewTeamsStore(db)
+			_, err := teamsStore.Create(ctx, CreateTeamOptions{Name: "Vidar"})
+			assert.Nil(t, err)
+			_, err = teamsStore.Create(ctx, CreateTeamOptions{Name: "E99p1ant"})
+			assert.Nil(t, err)
+
+			// Create a challenge.
+			challengesStore := NewChallengesStore(db)
+			_, err = challengesStore.Create(ctx, CreateChallengeOptions{Title: "Web1", BaseScore: 1000})
+			assert.Nil(t, err)
+
+			// Create game boxes for each team.
+			gameBoxesStore := NewGameBoxesStore(db)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 1, ChallengeID: 1, IPAddress: "192.168.1.1", Port: 80, Description: "Web1 For Vidar"})
+			assert.Nil(t, err)
+			_, err = gameBoxesStore.Create(ctx, CreateGameBoxOptions{TeamID: 2, ChallengeID: 1, IPAddress: "192.168.1.2", Port: 80, Description: "Web1 For E99p1ant"})
+			assert.Nil(t, err)
+			for i := uint(1); i <= 2; i++ {
+				err := gameBoxesStore.SetVisible(ctx, i, true)
+				assert.Nil(t, err)
+			}
+
+			tc.test(t, ctx, scoresStore.(*scores))
+		})
+	}
+}
+
+func testScoresCalculate(t *testing.T, ctx context.Context, db *scores) {
+	err := db.Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	round, err := NewRoundsStore(db.DB).GetByRound(ctx, 1)
+	assert.Nil(t, err)
+	assert.NotNil(t, round.ScoredAt)
+
+	actionsStore := NewActionsStore(db.DB)
+	serviceOnlineActions, err := actionsStore.Get(ctx, GetActionOptions{Type: ActionTypeServiceOnline, Round: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(serviceOnlineActions))
+
+	// Calculate the scored round again should do nothing.
+	teamsStore := NewTeamsStore(db.DB)
+	err = teamsStore.SetScore(ctx, 1, 1000)
+	assert.Nil(t, err)
+	err = db.Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	team, err := teamsStore.GetByID(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1000), team.Score)
+
+	// Force to calculate the scored round.
+	err = db.Calculate(ctx, 1, true)
+	assert.Nil(t, err)
+
+	team, err = teamsStore.GetByID(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), team.Score)
+
+	serviceOnlineActions, err = actionsStore.Get(ctx, GetActionOptions{Type: ActionTypeServiceOnline, Round: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(serviceOnlineActions))
+}
+
+func testScoresRefreshAttackScore(t *testing.T, ctx context.Context, db *scores) {
+	actionsStore := NewActionsStore(db.DB)
+
+	// Vidar attacked E99p1ant in round 1.
+	beenAttackAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeBeenAttack, GameBoxID: 2, AttackerTeamID: 1, Round: 1})
+	assert.Nil(t, err)
+	attackAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeAttack, GameBoxID: 2, Round: 1})
+	assert.Nil(t, err)
+
+	err = db.RefreshAttackScore(ctx, 1)
+	assert.Nil(t, err)
+
+	got, err := actionsStore.Get(ctx, GetActionOptions{ActionID: beenAttackAction.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-10), got[0].Score)
+	got, err = actionsStore.Get(ctx, GetActionOptions{ActionID: attackAction.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(10), got[0].Score)
+
+	// The scored actions should not be updated without replace.
+	err = actionsStore.SetScore(ctx, SetActionScoreOptions{ActionID: beenAttackAction.ID, Score: -5, Replace: true})
+	assert.Nil(t, err)
+
+	err = db.RefreshAttackScore(ctx, 1)
+	assert.Nil(t, err)
+	got, err = actionsStore.Get(ctx, GetActionOptions{ActionID: beenAttackAction.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-5), got[0].Score)
+
+	err = db.RefreshAttackScore(ctx, 1, true)
+	assert.Nil(t, err)
+	got, err = actionsStore.Get(ctx, GetActionOptions{ActionID: beenAttackAction.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-10), got[0].Score)
+
+	// The game box has been attacked without attack action.
+	_, err = actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeBeenAttack, GameBoxID: 1, AttackerTeamID: 2, Round: 2})
+	assert.Nil(t, err)
+	err = db.RefreshAttackScore(ctx, 2)
+	assert.NotNil(t, err)
+}
+
+func testScoresRefreshCheckScore(t *testing.T, ctx context.Context, db *scores) {
+	actionsStore := NewActionsStore(db.DB)
+
+	// Vidar's game box was checked down in round 1.
+	checkDownAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeCheckDown, GameBoxID: 1, Round: 1})
+	assert.Nil(t, err)
+
+	// Refresh twice, the service online actions should be replaced.
+	for i := 0; i < 2; i++ {
+		err = db.RefreshCheckScore(ctx, 1)
+		assert.Nil(t, err)
+	}
+
+	got, err := actionsStore.Get(ctx, GetActionOptions{ActionID: checkDownAction.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, float64(-10), got[0].Score)
+
+	got, err = actionsStore.Get(ctx, GetActionOptions{Type: ActionTypeServiceOnline, Round: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, uint(2), got[0].GameBoxID)
+	assert.Equal(t, float64(10), got[0].Score)
+
+	// The invisible challenge should be skipped.
+	err = NewGameBoxesStore(db.DB).SetVisible(ctx, 1, false)
+	assert.Nil(t, err)
+	err = NewGameBoxesStore(db.DB).SetVisible(ctx, 2, false)
+	assert.Nil(t, err)
+	err = db.RefreshCheckScore(ctx, 2)
+	assert.Nil(t, err)
+
+	got, err = actionsStore.Get(ctx, GetActionOptions{Type: ActionTypeServiceOnline, Round: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(got))
+}
+
+func testScoresScoreMultiplier(t *testing.T, ctx context.Context, db *scores) {
+	actionsStore := NewActionsStore(db.DB)
+
+	// The score of round 1 is doubled.
+	err := NewRoundsStore(db.DB).Start(ctx, 1, time.Now(), 2)
+	assert.Nil(t, err)
+
+	// Vidar attacked E99p1ant, and Vidar's game box was checked down in round 1.
+	beenAttackAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeBeenAttack, GameBoxID: 2, AttackerTeamID: 1, Round: 1})
+	assert.Nil(t, err)
+	attackAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeAttack, GameBoxID: 2, Round: 1})
+	assert.Nil(t, err)
+	checkDownAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeCheckDown, GameBoxID: 1, Round: 1})
+	assert.Nil(t, err)
+
+	err = db.Calculate(ctx, 1)
+	assert.Nil(t, err)
+
+	for actionID, want := range map[uint]float64{
+		beenAttackAction.ID: -20,
+		attackAction.ID:     20,
+		checkDownAction.ID:  -20,
+	} {
+		got, err := actionsStore.Get(ctx, GetActionOptions{ActionID: actionID})
+		assert.Nil(t, err)
+		assert.Equal(t, want, got[0].Score)
+	}
+
+	got, err := actionsStore.Get(ctx, GetActionOptions{Type: ActionTypeServiceOnline, Round: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, float64(20), got[0].Score)
+
+	// The round without multiplier is scored as usual.
+	_, err = actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeCheckDown, GameBoxID: 2, Round: 2})
+	assert.Nil(t, err)
+	err = db.Calculate(ctx, 2)
+	assert.Nil(t, err)
+
+	got, err = actionsStore.Get(ctx, GetActionOptions{Type: ActionTypeServiceOnline, Round: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, float64(10), got[0].Score)
+}
+
+func testScoresRefreshScore(t *testing.T, ctx context.Context, db *scores) {
+	actionsStore := NewActionsStore(db.DB)
+
+	// Vidar attacked E99p1ant and took the first blood in round 1.
+	_, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeBeenAttack, GameBoxID: 2, AttackerTeamID: 1, Round: 1})
+	assert.Nil(t, err)
+	_, err = actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeAttack, GameBoxID: 2, Round: 1})
+	assert.Nil(t, err)
+	_, err = actionsStore.CreateFirstBlood(ctx, CreateFirstBloodOptions{GameBoxID: 2, AttackerTeamID: 1, Round: 1, Score: 100})
+	assert.Nil(t, err)
+	// E99p1ant's game box was checked down in round 2.
+	_, err = actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeCheckDown, GameBoxID: 2, Round: 2})
+	assert.Nil(t, err)
+
+	for round := uint(1); round <= 2; round++ {
+		err = db.RefreshAttackScore(ctx, round)
+		assert.Nil(t, err)
+		err = db.RefreshCheckScore(ctx, round)
+		assert.Nil(t, err)
+	}
+
+	scoreAdjustmentsStore := NewScoreAdjustmentsStore(db.DB)
+	_, err = scoreAdjustmentsStore.Create(ctx, CreateScoreAdjustmentOptions{TeamID: 1, Round: 2, Score: -30, Reason: "Attack the platform", ManagerID: 1})
+	assert.Nil(t, err)
+	_, err = scoreAdjustmentsStore.Create(ctx, CreateScoreAdjustmentOptions{GameBoxID: 2, Round: 2, Score: 5, Reason: "Infrastructure failure", ManagerID: 1})
+	assert.Nil(t, err)
+
+	err = db.RefreshGameBoxScore(ctx)
+	assert.Nil(t, err)
+	err = db.RefreshTeamScore(ctx)
+	assert.Nil(t, err)
+
+	// The scores should be equal to the ones counted one by one.
+	gameBoxes, err := NewGameBoxesStore(db.DB).Get(ctx, GetGameBoxesOption{})
+	assert.Nil(t, err)
+	for _, gameBox := range gameBoxes {
+		want, err := db.countGameBoxScore(ctx, gameBox)
+		assert.Nil(t, err)
+		assert.Equal(t, want, gameBox.Score)
+	}
+	teams, err := NewTeamsStore(db.DB).Get(ctx, GetTeamsOptions{})
+	assert.Nil(t, err)
+	for _, team := range teams {
+		want, err := db.countTeamScore(ctx, team.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, want, team.Score)
+	}
+
+	// Vidar's game box: first blood 100 + service online 10
+	// E99p1ant's game box: been attacked -10 + attack 10 + check down -10 + adjustment 5
+	assert.Equal(t, float64(110), gameBoxes[0].Score)
+	assert.Equal(t, float64(-5), gameBoxes[1].Score)
+	// Vidar: game box 110 + team adjustment -30
+	assert.Equal(t, float64(80), teams[0].Score)
+	assert.Equal(t, float64(-5), teams[1].Score)
+}
+
+func testScoresCheck(t *testing.T, ctx context.Context, db *scores) {
+	actionsStore := NewActionsStore(db.DB)
+
+	// Vidar attacked E99p1ant in round 1.
+	beenAttackAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeBeenAttack, GameBoxID: 2, AttackerTeamID: 1, Round: 1})
+	assert.Nil(t, err)
+	err = actionsStore.SetScore(ctx, SetActionScoreOptions{ActionID: beenAttackAction.ID, Score: -10})
+	assert.Nil(t, err)
+	attackAction, err := actionsStore.Create(ctx, CreateActionOptions{Type: ActionTypeAttack, GameBoxID: 2, Round: 1})
+	assert.Nil(t, err)
+	err = actionsStore.SetScore(ctx, SetActionScoreOptions{ActionID: attackAction.ID, Score: 10})
+	assert.Nil(t, err)
+
+	// Vidar took the first blood.
+	_, err = actionsStore.CreateFirstBlood(ctx, CreateFirstBloodOptions{GameBoxID: 2, AttackerTeamID: 1, Round: 1, Score: 100})
+	assert.Nil(t, err)
+
+	err = db.RefreshGameBoxScore(ctx)
+	assert.Nil(t, err)
+	err = db.RefreshTeamScore(ctx)
+	assert.Nil(t, err)
+
+	got, err := db.Check(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []*ScoreViolation{}, got)
+
+	// Break the game box and team score.
+	err = NewGameBoxesStore(db.DB).SetScore(ctx, 1, 1000)
+	assert.Nil(t, err)
+	err = NewTeamsStore(db.DB).SetScore(ctx, 2, 1000)
+	assert.Nil(t, err)
+
+	got, err = db.Check(ctx, 1)
+	assert.Nil(t, err)
+	want := []*ScoreViolation{
+		{
+			Kind:      ScoreViolationGameBoxScore,
+			TeamID:    1,
+			GameBoxID: 1,
+			Expected:  100,
+			Actual:    1000,
+			Message:   "game box 1 score is 1000.00, expected 100.00",
+		},
+		{
+			Kind:     ScoreViolationTeamScore,
+			TeamID:   1,
+			Expected: 1000,
+			Actual:   100,
+			Message:  "team 1 score is 100.00, expected 1000.00",
+		},
+		{
+			Kind:     ScoreViolationTeamScore,
+			TeamID:   2,
+			Expected: 0,
+			Actual:   1000,
+			Message:  "team 2 score is 1000.00, expected 0.00",
+		},
+	}
+	assert.Equal(t, want, got)
+
+	// Remove the attack action.
+	err = db.RefreshGameBoxScore(ctx)
+	assert.Nil(t, err)
+	err = actionsStore.Delete(ctx, DeleteActionOptions{ActionID: attackAction.ID})
+	assert.Nil(t, err)
+	err = db.RefreshGameBoxScore(ctx)
+	assert.Nil(t, err)
+	err = db.RefreshTeamScore(ctx)
+	assert.Nil(t, err)
+
+	got, err = db.Check(ctx, 1)
+	assert.Nil(t, err)
+	want = []*ScoreViolation{
+		{
+			Kind:      ScoreViolationAttackMissing,
+			Round:     1,
+			TeamID:    2,
+			GameBoxID: 2,
+			Expected:  10,
+			Message:   "game box 2 has been attacked in round 1 without attack action",
+		},
+		{
+			Kind:     ScoreViolationRoundSum,
+			Round:    1,
+			Expected: 100,
+			Actual:   90,
+			Message:  "the sum of round 1 scores is 90.00, expected 100.00",
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+// countGameBoxScore counts the game box score with its actions and score adjustments one by one.
+// It is used to check the score calculated by RefreshGameBoxScore.
+func (db *scores) countGameBoxScore(ctx context.Context, gameBox *GameBox) (float64, error) {
+	actionsStore := NewActionsStore(db.DB)
+
+	score, err := actionsStore.CountScore(ctx, CountActionScoreOptions{
+		GameBoxID: gameBox.ID,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count actions score")
+	}
+
+	// The first blood actions are recorded on the victim's game box,
+	// move their score to the attacker's game box of the same challenge.
+	victimFirstBloodScore, err := actionsStore.CountScore(ctx, CountActionScoreOptions{
+		Type:      ActionTypeFirstBlood,
+		GameBoxID: gameBox.ID,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count victim first blood score")
+	}
+	attackerFirstBloodScore, err := actionsStore.CountScore(ctx, CountActionScoreOptions{
+		Type:           ActionTypeFirstBlood,
+		ChallengeID:    gameBox.ChallengeID,
+		AttackerTeamID: gameBox.TeamID,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count attacker first blood score")
+	}
+	score += attackerFirstBloodScore - victimFirstBloodScore
+
+	adjustmentScore, err := NewScoreAdjustmentsStore(db.DB).CountScore(ctx, CountScoreAdjustmentOptions{
+		GameBoxID: gameBox.ID,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count adjustment score")
+	}
+	return score + adjustmentScore, nil
+}
+
+// countTeamScore counts the team score with its visible game boxes and score adjustments one by one.
+// It is used to check the score calculated by RefreshTeamScore.
+func (db *scores) countTeamScore(ctx context.Context, teamID uint) (float64, error) {
+	score, err := NewGameBoxesStore(db.DB).CountScore(ctx, GameBoxCountScoreOptions{
+		TeamID:  teamID,
+		Visible: true,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "get game box score")
+	}
+
+	// The adjustments of the game boxes have been counted in the game box score.
+	adjustmentScore, err := NewScoreAdjustmentsStore(db.DB).CountScore(ctx, CountScoreAdjustmentOptions{
+		TeamID:   teamID,
+		TeamOnly: true,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count adjustment score")
+	}
+	return score + adjustmentScore, nil
+}
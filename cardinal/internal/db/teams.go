@@ -9,6 +9,7 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"fmt"
+	"sort"
 
 	"github.com/pkg/errors"
 	"github.com/thanhpk/randstr"
@@ -189,65 +190,174 @@ func (db *teams) Get(ctx context.Context, opts GetTeamsOptions) ([]*Team, error)
 		opts.Order = "ASC"
 	}
 
-	q := db.WithContext(ctx).Model(&Team{}).
-		Select([]string{"*", `RANK() OVER(ORDER BY score DESC) "rank"`})
+	var teams []*Team
+	// The teams are ordered by the ranking when ordering by the score,
+	// so that the teams with the same score are ordered by the tie breakers.
+	if opts.OrderBy == "score" {
+		if err := db.WithContext(ctx).Model(&Team{}).Find(&teams).Error; err != nil {
+			return nil, err
+		}
+		ranking, err := getTeamRanking(ctx, db.DB, teams)
+		if err != nil {
+			return nil, errors.Wrap(err, "get team ranking")
+		}
+
+		position := ranking.Position()
+		sort.SliceStable(teams, func(i, j int) bool {
+			if opts.Order == "DESC" {
+				return position[teams[i].ID] < position[teams[j].ID]
+			}
+			return position[teams[i].ID] > position[teams[j].ID]
+		})
+		if opts.PageSize != 0 {
+			start := (opts.Page - 1) * opts.PageSize
+			if start > len(teams) {
+				start = len(teams)
+			}
+			end := start + opts.PageSize
+			if end > len(teams) {
+				end = len(teams)
+			}
+			teams = teams[start:end]
+		}
 
+		ranking.setRanks(teams...)
+		return teams, nil
+	}
+
+	q := db.WithContext(ctx).Model(&Team{})
 	if opts.PageSize != 0 {
 		q = q.Offset((opts.Page - 1) * opts.PageSize).Limit(opts.PageSize)
 	}
 
-	var teams []*Team
-	return teams, q.Order(opts.OrderBy + " " + opts.Order).Find(&teams).Error
+	if err := q.Order(opts.OrderBy + " " + opts.Order).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.setRanks(ctx, teams); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// setRanks sets the rank of the given teams. Only the teams tied with them are ranked by the tie breakers,
+// the teams with the higher score are just counted, the same as withRank.
+func (db *teams) setRanks(ctx context.Context, teams []*Team) error {
+	var scores []*Team
+	if err := db.WithContext(ctx).Model(&Team{}).Select("id", "score").Find(&scores).Error; err != nil {
+		return errors.Wrap(err, "get team scores")
+	}
+
+	// higher returns the number of the teams in the list with the higher score than the team.
+	higher := func(list []*Team, team *Team) uint {
+		var count uint
+		for _, t := range list {
+			if t.Score > team.Score && !scoreEqual(t.Score, team.Score) {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Collect the teams tied with the given ones, the team without a tie needs no tie breaker.
+	var tied []*Team
+	tiedIDs := make(map[uint]bool)
+	for _, team := range teams {
+		var group []*Team
+		for _, t := range scores {
+			if scoreEqual(t.Score, team.Score) {
+				group = append(group, t)
+			}
+		}
+		if len(group) < 2 {
+			continue
+		}
+		for _, t := range group {
+			if !tiedIDs[t.ID] {
+				tiedIDs[t.ID] = true
+				tied = append(tied, t)
+			}
+		}
+	}
+
+	ranking := &TeamRanking{}
+	if len(tied) != 0 {
+		var err error
+		ranking, err = getTeamRanking(ctx, db.DB, tied)
+		if err != nil {
+			return errors.Wrap(err, "get team ranking")
+		}
+	}
+
+	for _, team := range teams {
+		team.Rank = higher(scores, team) + 1
+		if tiedIDs[team.ID] {
+			// Add the rank among the teams tied with it, the tied teams of the higher scores are excluded.
+			team.Rank += ranking.Ranks[team.ID] - 1 - higher(tied, team)
+		}
+	}
+	return nil
 }
 
 var ErrTeamNotExists = errors.New("team dose not exist")
 
 func (db *teams) GetByID(ctx context.Context, id uint) (*Team, error) {
 	var team Team
-	if err := db.WithContext(ctx).Table(
-		"(?) AS team",
-		db.WithContext(ctx).Model(&Team{}).Select([]string{"*", `RANK() OVER(ORDER BY score DESC) "rank"`}),
-	).
-		Where("id = ?", id).First(&team).Error; err != nil {
+	if err := db.WithContext(ctx).Model(&Team{}).Where("id = ?", id).First(&team).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, ErrTeamNotExists
 		}
 		return nil, err
 	}
 
-	return &team, nil
+	return db.withRank(ctx, &team)
 }
 
 func (db *teams) GetByName(ctx context.Context, name string) (*Team, error) {
 	var team Team
-	if err := db.WithContext(ctx).Table(
-		"(?) AS team",
-		db.WithContext(ctx).Model(&Team{}).Select([]string{"*", `RANK() OVER(ORDER BY score DESC) "rank"`}),
-	).
-		Where("name = ?", name).First(&team).Error; err != nil {
+	if err := db.WithContext(ctx).Model(&Team{}).Where("name = ?", name).First(&team).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, ErrTeamNotExists
 		}
 		return nil, err
 	}
 
-	return &team, nil
+	return db.withRank(ctx, &team)
 }
 
 func (db *teams) GetByToken(ctx context.Context, token string) (*Team, error) {
 	var team Team
-	if err := db.WithContext(ctx).Table(
-		"(?) AS team",
-		db.WithContext(ctx).Model(&Team{}).Select([]string{"*", `RANK() OVER(ORDER BY score DESC) "rank"`}),
-	).
-		Where("token = ?", token).First(&team).Error; err != nil {
+	if err := db.WithContext(ctx).Model(&Team{}).Where("token = ?", token).First(&team).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, ErrTeamNotExists
 		}
 		return nil, err
 	}
 
-	return &team, nil
+	return db.withRank(ctx, &team)
+}
+
+// withRank sets the rank of the team. Only the teams tied with it are ranked by the tie breakers,
+// the teams with the higher score are just counted.
+func (db *teams) withRank(ctx context.Context, team *Team) (*Team, error) {
+	var higher int64
+	if err := db.WithContext(ctx).Model(&Team{}).Where("score > ?", team.Score+scoreEpsilon).Count(&higher).Error; err != nil {
+		return nil, errors.Wrap(err, "count higher teams")
+	}
+
+	var tied []*Team
+	if err := db.WithContext(ctx).Model(&Team{}).Select("id", "score").
+		Where("score BETWEEN ? AND ?", team.Score-scoreEpsilon, team.Score+scoreEpsilon).
+		Find(&tied).Error; err != nil {
+		return nil, errors.Wrap(err, "get tied teams")
+	}
+	ranking, err := getTeamRanking(ctx, db.DB, tied)
+	if err != nil {
+		return nil, errors.Wrap(err, "get team ranking")
+	}
+
+	team.Rank = uint(higher) + ranking.Ranks[team.ID]
+	return team, nil
 }
 
 func (db *teams) ChangePassword(ctx context.Context, id uint, newPassword string) error {
@@ -328,6 +328,18 @@ func testTeamsGet(t *testing.T, ctx context.Context, db *teams) {
 	want[1].EncodePassword()
 	want[2].EncodePassword()
 	assert.Equal(t, want, got)
+
+	// The ranks of the listed teams are counted with all the teams.
+	assert.Nil(t, db.SetScore(ctx, 2, 1000))
+	assert.Nil(t, db.SetScore(ctx, 3, 1000))
+
+	got, err = db.Get(ctx, GetTeamsOptions{Page: 1, PageSize: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), got[0].Rank)
+
+	got, err = db.Get(ctx, GetTeamsOptions{Page: 2, PageSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), got[0].Rank)
 }
 
 func testTeamsGetByID(t *testing.T, ctx context.Context, db *teams) {
@@ -1,6 +1,8 @@
 package dbold
 
 import (
+	"time"
+
 	"github.com/jinzhu/gorm"
 )
 
@@ -69,6 +71,21 @@ type AttackAction struct {
 	Round          int
 }
 
+// FirstBlood is a gorm model for database table `first_bloods`.
+// The score of the first blood belongs to the attacker's gamebox of the challenge.
+// The unique index makes sure the first blood of the challenge, or of each victim, can only be taken once.
+type FirstBlood struct {
+	gorm.Model
+
+	ChallengeID    uint `gorm:"unique_index:first_blood_unique_idx"`
+	VictimKey      uint `gorm:"unique_index:first_blood_unique_idx"` // The victim's team ID if the first blood is taken per victim, otherwise zero.
+	TeamID         uint // Victim's team ID
+	GameBoxID      uint // Victim's gamebox ID
+	AttackerTeamID uint
+	Round          int
+	Score          float64
+}
+
 // Flag is a gorm model for database table `flags`.
 // All the flags will be generated before the competition start and save in this table.
 type Flag struct {
@@ -111,6 +128,74 @@ type Score struct {
 	Score     float64 `gorm:"index"`
 }
 
+// ScoreAdjustment is a gorm model for database table `score_adjustments`.
+// Used to store the score awarded or deducted by the manager manually, the reverted one is not counted.
+type ScoreAdjustment struct {
+	gorm.Model
+
+	TeamID      uint
+	ChallengeID uint
+	GameBoxID   uint // The adjustment is applied to the team directly if the gamebox ID is zero.
+	Round       int
+
+	Score     float64 // Positive for award, negative for deduction.
+	Reason    string
+	ManagerID uint
+
+	RevertedAt        *time.Time
+	RevertedManagerID uint
+}
+
+// RankHistory is a gorm model for database table `rank_histories`.
+// It saves the score and rank of every team at the end of each round.
+type RankHistory struct {
+	gorm.Model
+
+	Round  int  `gorm:"unique_index:rank_history_unique_idx"`
+	TeamID uint `gorm:"unique_index:rank_history_unique_idx"`
+	Rank   int
+	Score  float64
+}
+
+// GameBoxHistory is a gorm model for database table `game_box_histories`.
+// It saves the score of every visible gamebox at the end of each round.
+type GameBoxHistory struct {
+	gorm.Model
+
+	Round       int `gorm:"unique_index:game_box_history_unique_idx"`
+	TeamID      uint
+	ChallengeID uint
+	GameBoxID   uint `gorm:"unique_index:game_box_history_unique_idx"`
+	Score       float64
+}
+
+// ClockAdjustment is a gorm model for database table `clock_adjustments`.
+// Used to store the game time changed by the manager at runtime.
+type ClockAdjustment struct {
+	gorm.Model
+
+	Type    string // `pause` or `end_at`
+	StartAt time.Time
+	EndAt   *time.Time // The game is paused until resumed if the pause's EndAt is nil.
+
+	ManagerID        uint
+	ResumedManagerID uint
+}
+
+// Round is a gorm model for database table `rounds`.
+// It records the round transitions and whether the round has been scored,
+// so the rounds which have ended but not been scored can be calculated after Cardinal restarts.
+type Round struct {
+	gorm.Model
+
+	Round           int     `gorm:"unique_index"`
+	ScoreMultiplier float64 // The score multiplier of the round's phase, zero means it has not been recorded.
+	StartedAt       *time.Time
+	EndedAt         *time.Time
+	ScoredAt        *time.Time
+	ScoringDuration time.Duration
+}
+
 // Bulletin is a gorm model for database table `bulletins`.
 type Bulletin struct {
 	gorm.Model
@@ -136,16 +221,75 @@ type Log struct {
 	Content string
 }
 
+// LiveLog is a gorm model for database table `live_logs`, used to store the lines of the live log streams.
+// The ID of the line is used as the event ID of the Server-Sent Events.
+type LiveLog struct {
+	gorm.Model
+
+	Stream    int64
+	Type      string
+	Message   string `gorm:"type:text"` // The JSON message of the line.
+	Timestamp int64
+}
+
+// AsteroidEvent is a gorm model for database table `asteroid_events`, used to store the messages broadcast by the asteroid.
+// The events are replayed in the order of the ID.
+type AsteroidEvent struct {
+	gorm.Model
+
+	Round int
+	Type  string
+	Data  string `gorm:"type:text"` // The JSON data of the message.
+}
+
 // WebHook is a gorm model for database table `webhook`, used to store the webhook.
 type WebHook struct {
 	gorm.Model
 
 	URL   string
-	Type  string
+	Type  string // The event types separated by commas, or `any` for all the events.
 	Token string
 
 	Retry   int
 	Timeout int
+
+	Format   string // `native`, `slack`, `discord`, `matrix` or `template`, empty is `native`.
+	Template string `gorm:"type:text"` // Go text/template of the delivery body, used by the `template` format.
+
+	// The events related to other teams or challenges are not sent, the IDs are separated by commas, empty means all.
+	TeamIDs      string
+	ChallengeIDs string
+	MinSeverity  int // 0 - Normal, 1 - Warning, 2 - Important, same as the log level.
+}
+
+// WebHookDelivery is a gorm model for database table `web_hook_deliveries`, used as the outbox of the webhooks.
+// The deliveries of a webhook are sent in order, the delivery is dead when all the attempts failed.
+type WebHookDelivery struct {
+	gorm.Model
+
+	WebHookID  uint
+	DeliveryID string `gorm:"unique_index"` // Sent in the header, the receiver can drop the duplicate deliveries by it.
+	Type       string
+	Body       string `gorm:"type:text"`
+
+	Status        string // `pending`, `delivered` or `dead`
+	Attempts      int
+	NextAttemptAt time.Time
+	DeliveredAt   *time.Time
+	// Redeliveries is increased when the delivery is redelivered by the manager,
+	// so that the attempt started before the redelivery does not overwrite it.
+	Redeliveries int
+}
+
+// WebHookAttempt is a gorm model for database table `web_hook_attempts`, used to store each attempt of the delivery.
+type WebHookAttempt struct {
+	gorm.Model
+
+	WebHookDeliveryID uint
+	StatusCode        int    // 0 if no response received.
+	Latency           int64  // Milliseconds
+	Response          string `gorm:"type:text"` // The beginning of the response body.
+	Error             string
 }
 
 // DynamicConfig is the config which is stored in database.
@@ -2,6 +2,7 @@ package dbold
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
@@ -9,6 +10,7 @@ import (
 
 	"Cardinal/internal/conf"
 	"Cardinal/internal/locales"
+	"Cardinal/internal/timeutil"
 )
 
 var MySQL *gorm.DB
@@ -25,6 +27,11 @@ func InitMySQL() {
 		log.Fatal("Failed to connect to mysql database: %v", err)
 	}
 
+	// Use the clock of Cardinal, so the time can be simulated in the rehearsal mode.
+	gorm.NowFunc = func() time.Time {
+		return timeutil.Now()
+	}
+
 	db.DB().SetMaxIdleConns(conf.Database.MaxIdleConns)
 	db.DB().SetMaxOpenConns(conf.Database.MaxOpenConns)
 
@@ -39,14 +46,24 @@ func InitMySQL() {
 
 		&AttackAction{},
 		&DownAction{},
+		&FirstBlood{},
 		&Score{},
+		&ScoreAdjustment{},
+		&RankHistory{},
+		&GameBoxHistory{},
+		&Round{},
 		&Flag{},
 		&GameBox{},
 
 		&Log{},
+		&LiveLog{},
+		&AsteroidEvent{},
 		&WebHook{},
+		&WebHookDelivery{},
+		&WebHookAttempt{},
 
 		&DynamicConfig{},
+		&ClockAdjustment{},
 	)
 
 	MySQL = db
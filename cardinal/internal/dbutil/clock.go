@@ -6,8 +6,10 @@ package dbutil
 
 import (
 	"time"
+
+	"Cardinal/internal/timeutil"
 )
 
 func Now() time.Time {
-	return time.Now().Truncate(time.Microsecond)
+	return timeutil.Now().Truncate(time.Microsecond)
 }
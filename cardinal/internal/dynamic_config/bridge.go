@@ -0,0 +1,4 @@
+package dynamic_config
+
+// OnConfigChanged is called with the keys of the configs after they are set by the manager.
+var OnConfigChanged func(keys []string)
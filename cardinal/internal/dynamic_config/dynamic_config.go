@@ -17,7 +17,13 @@ func Init() {
 	initConfig(utils.FLAG_PREFIX_CONF, conf.Game.FlagPrefix, utils.STRING)
 	initConfig(utils.FLAG_SUFFIX_CONF, conf.Game.FlagSuffix, utils.STRING)
 	initConfig(utils.ANIMATE_ASTEROID, utils.BOOLEAN_FALSE, utils.BOOLEAN)
+	// The asteroid websocket requires the token if it is set.
+	initConfig(utils.ASTEROID_TOKEN, "", utils.STRING)
 	initConfig(utils.SHOW_OTHERS_GAMEBOX, utils.BOOLEAN_FALSE, utils.BOOLEAN)
+	// The attacker is shown in the private live log of the victim team.
+	initConfig(utils.SHOW_ATTACKER, utils.BOOLEAN_FALSE, utils.BOOLEAN)
+	// The attack matrix and the challenge statistics are public.
+	initConfig(utils.PUBLIC_ATTACK_STATS, utils.BOOLEAN_FALSE, utils.BOOLEAN)
 	initConfig(utils.DEFAULT_LANGUAGE, conf.App.Language, utils.SELECT, "en-US|zh-CN")
 }
 
@@ -68,8 +74,14 @@ func SetConfig(c *gin.Context) (int, interface{}) {
 		return utils.MakeErrJSON(400, 40046, locales.I18n.T(c.GetString("lang"), "general.error_payload"))
 	}
 
+	keys := make([]string, 0, len(inputForm))
 	for _, config := range inputForm {
 		Set(config.Key, config.Value)
+		keys = append(keys, config.Key)
+	}
+
+	if OnConfigChanged != nil {
+		go OnConfigChanged(keys)
 	}
 	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "config.update_success"))
 }
@@ -0,0 +1,69 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Type is the type of the event published by the game clock.
+type Type string
+
+const (
+	GameStart  Type = "game_start"
+	RoundStart Type = "round_start"
+	RoundEnd   Type = "round_end"
+	Pause      Type = "pause"
+	Resume     Type = "resume"
+	GameEnd    Type = "game_end"
+)
+
+// Event is published by the game clock when the game status or the round changes.
+type Event struct {
+	Type Type
+	// Round is the round which starts or ends, it is the current round for the other events.
+	Round uint
+	Time  time.Time
+}
+
+// Handler handles the event published by the game clock.
+type Handler func(ctx context.Context, event Event)
+
+// Bus is an in-process event bus.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+}
+
+// NewBus returns a new event bus.
+func NewBus() *Bus {
+	return &Bus{
+		handlers: make(map[Type][]Handler),
+	}
+}
+
+// Subscribe registers the handler for the given event types.
+func (b *Bus) Subscribe(handler Handler, types ...Type) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, typ := range types {
+		b.handlers[typ] = append(b.handlers[typ], handler)
+	}
+}
+
+// Publish calls the handlers of the event synchronously in the order of subscription,
+// so the handler should start a goroutine itself if it takes a long time.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Type]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package form
+
+type NewScoreAdjustment struct {
+	TeamID    uint
+	GameBoxID uint
+	Round     uint
+	Score     float64 `validate:"required"`
+	Reason    string  `validate:"required,lt=1000"`
+}
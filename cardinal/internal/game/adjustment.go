@@ -0,0 +1,146 @@
+package game
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/timer"
+	"Cardinal/internal/timeutil"
+	"Cardinal/internal/utils"
+)
+
+// refreshScore recounts the gamebox and team score, then refreshes the ranking list.
+func refreshScore() {
+	calculateGameBoxScore(dbold.MySQL)
+	calculateTeamScore(dbold.MySQL)
+	SetRankList()
+}
+
+// GetScoreAdjustments returns the score adjustments, or only one team's if the `teamID` query is given.
+func GetScoreAdjustments(c *gin.Context) (int, interface{}) {
+	teamID, err := strconv.Atoi(c.DefaultQuery("teamID", "0"))
+	if err != nil || teamID < 0 {
+		return utils.MakeErrJSON(400, 40073,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+
+	var scoreAdjustments []dbold.ScoreAdjustment
+	dbold.MySQL.Model(&dbold.ScoreAdjustment{}).Where(&dbold.ScoreAdjustment{TeamID: uint(teamID)}).Order("id ASC").Find(&scoreAdjustments)
+	return utils.MakeSuccessJSON(scoreAdjustments)
+}
+
+// NewScoreAdjustment awards or deducts the score of the team or the gamebox.
+// The adjustment will be recorded in the current round if the round is not given.
+func NewScoreAdjustment(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		TeamID    uint
+		GameBoxID uint
+		Round     int
+		Score     float64 `binding:"required"`
+		Reason    string  `binding:"required,lt=1000"`
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40074,
+			locales.I18n.T(c.GetString("lang"), "general.error_payload"),
+		)
+	}
+	if inputForm.TeamID == 0 && inputForm.GameBoxID == 0 {
+		return utils.MakeErrJSON(400, 40075,
+			locales.I18n.T(c.GetString("lang"), "score_adjustment.target_empty"),
+		)
+	}
+
+	round := inputForm.Round
+	if round <= 0 {
+		round = timer.GetSnapshot().NowRound
+	}
+
+	manager := c.MustGet("managerData").(dbold.Manager)
+	scoreAdjustment := dbold.ScoreAdjustment{
+		TeamID:    inputForm.TeamID,
+		Round:     round,
+		Score:     inputForm.Score,
+		Reason:    inputForm.Reason,
+		ManagerID: manager.ID,
+	}
+
+	// The team ID is ignored if the gamebox ID is given.
+	if inputForm.GameBoxID != 0 {
+		var gameBox dbold.GameBox
+		dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: inputForm.GameBoxID}}).Find(&gameBox)
+		if gameBox.ID == 0 {
+			return utils.MakeErrJSON(404, 40409,
+				locales.I18n.T(c.GetString("lang"), "gamebox.not_found"),
+			)
+		}
+		scoreAdjustment.TeamID = gameBox.TeamID
+		scoreAdjustment.ChallengeID = gameBox.ChallengeID
+		scoreAdjustment.GameBoxID = gameBox.ID
+	} else {
+		var count int
+		dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: inputForm.TeamID}}).Count(&count)
+		if count == 0 {
+			return utils.MakeErrJSON(404, 40410,
+				locales.I18n.T(c.GetString("lang"), "team.not_found"),
+			)
+		}
+	}
+
+	if dbold.MySQL.Create(&scoreAdjustment).RowsAffected != 1 {
+		return utils.MakeErrJSON(500, 50038,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	refreshScore()
+
+	if err := livelog.WriteTeam(scoreAdjustment.TeamID, livelog.NewLine("score_adjustment", gin.H{
+		"Round":  scoreAdjustment.Round,
+		"Score":  scoreAdjustment.Score,
+		"Reason": scoreAdjustment.Reason,
+	})); err != nil {
+		log.Printf("Error writing team live log: %v", err)
+	}
+
+	return utils.MakeSuccessJSON(scoreAdjustment)
+}
+
+// RevertScoreAdjustment reverts the score adjustment, the reverted one will not be counted anymore.
+func RevertScoreAdjustment(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		ID uint `binding:"required"`
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40076,
+			locales.I18n.T(c.GetString("lang"), "general.error_payload"),
+		)
+	}
+
+	var scoreAdjustment dbold.ScoreAdjustment
+	dbold.MySQL.Model(&dbold.ScoreAdjustment{}).Where(&dbold.ScoreAdjustment{Model: gorm.Model{ID: inputForm.ID}}).Find(&scoreAdjustment)
+	if scoreAdjustment.ID == 0 {
+		return utils.MakeErrJSON(404, 40411,
+			locales.I18n.T(c.GetString("lang"), "score_adjustment.not_found"),
+		)
+	}
+
+	// Only the adjustment which has not been reverted is updated, in case it is reverted twice at the same time.
+	manager := c.MustGet("managerData").(dbold.Manager)
+	if dbold.MySQL.Model(&dbold.ScoreAdjustment{}).Where("id = ? AND reverted_at IS NULL", scoreAdjustment.ID).Updates(map[string]interface{}{
+		"reverted_at":         timeutil.Now(),
+		"reverted_manager_id": manager.ID,
+	}).RowsAffected == 0 {
+		return utils.MakeErrJSON(400, 40077,
+			locales.I18n.T(c.GetString("lang"), "score_adjustment.reverted"),
+		)
+	}
+	refreshScore()
+
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "general.success"))
+}
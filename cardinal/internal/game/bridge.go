@@ -2,7 +2,6 @@ package game
 
 import (
 	"Cardinal/internal/asteroid"
-	"Cardinal/internal/dbold"
 	"Cardinal/internal/dynamic_config"
 	"Cardinal/internal/timer"
 	"Cardinal/internal/utils"
@@ -10,21 +9,21 @@ import (
 
 func AsteroidGreetData() (result asteroid.Greet) {
 	var asteroidTeam []asteroid.Team
-	var teams []dbold.Team
-	dbold.MySQL.Model(&dbold.Team{}).Order("score DESC").Find(&teams)
-	for rank, team := range teams {
+	// Use the public ranking list, for it may be frozen or delayed.
+	for rank, rankItem := range GetRankList() {
 		asteroidTeam = append(asteroidTeam, asteroid.Team{
-			Id:    int(team.ID),
-			Name:  team.Name,
+			Id:    int(rankItem.TeamID),
+			Name:  rankItem.TeamName,
 			Rank:  rank + 1,
-			Image: team.Logo,
-			Score: int(team.Score),
+			Image: rankItem.TeamLogo,
+			Score: int(rankItem.Score),
 		})
 	}
 
 	result.Title = dynamic_config.Get(utils.TITLE_CONF)
 	result.Team = asteroidTeam
-	result.Time = timer.Get().RoundRemainTime
-	result.Round = timer.Get().NowRound
+	snapshot := timer.GetSnapshot()
+	result.Time = snapshot.RoundRemainTime
+	result.Round = snapshot.NowRound
 	return
 }
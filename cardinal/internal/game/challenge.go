@@ -44,7 +44,7 @@ func SetVisible(c *gin.Context) (int, interface{}) {
 	dbold.MySQL.Model(&dbold.GameBox{}).Where("challenge_id = ?", inputForm.ID).Update(map[string]interface{}{"visible": inputForm.Visible})
 
 	// Calculate all the teams' score. (Only visible challenges)
-	calculateTeamScore()
+	calculateTeamScore(dbold.MySQL)
 	// Refresh the ranking list table's header.
 	SetRankListTitle()
 	// Refresh the ranking list teams' scores.
@@ -223,7 +223,7 @@ func EditChallenge(c *gin.Context) (int, interface{}) {
 	// If the challenge's score is updated, we need to calculate the gameboxes' scores and the teams' scores.
 	if inputForm.BaseScore != checkChallenge.BaseScore {
 		// Calculate all the teams' score. (Only visible challenges)
-		calculateTeamScore()
+		calculateTeamScore(dbold.MySQL)
 		// Refresh the ranking list table's header.
 		SetRankListTitle()
 		// Refresh the ranking list teams' scores.
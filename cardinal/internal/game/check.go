@@ -20,6 +20,7 @@ import (
 	"Cardinal/internal/logger"
 	"Cardinal/internal/misc/webhook"
 	"Cardinal/internal/timer"
+	"Cardinal/internal/timeutil"
 	"Cardinal/internal/utils"
 )
 
@@ -38,8 +39,11 @@ type InputForm struct {
 // Returns:
 //   - error: An error if any of the checks fail or if the service is down, otherwise nil.
 func PerformCheckDown(gameBoxID uint) error {
+	// The check down is recorded in the round when it is started.
+	snapshot := timer.GetSnapshot()
+
 	// Check down is forbidden if the competition hasn't started yet.
-	if timer.Get().Status != "on" {
+	if snapshot.Status != "on" {
 		return errors.New("competition hasn't started yet")
 	}
 
@@ -47,7 +51,7 @@ func PerformCheckDown(gameBoxID uint) error {
 	var repeatCheck dbold.DownAction
 	dbold.MySQL.Model(&dbold.DownAction{}).Where(&dbold.DownAction{
 		GameBoxID: gameBoxID,
-		Round:     timer.Get().NowRound,
+		Round:     snapshot.NowRound,
 	}).Find(&repeatCheck)
 	if repeatCheck.ID != 0 {
 		return errors.New(fmt.Sprintf("repeated check down for gamebox ID: %d", gameBoxID))
@@ -84,7 +88,13 @@ func PerformCheckDown(gameBoxID uint) error {
 	}
 
 	// Check the output of the checker script.
-	status := strings.TrimSpace(string(output))
+	// The first line is the status, and the rest is the public reason which is sent to the team.
+	lines := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)
+	status := strings.TrimSpace(lines[0])
+	reason := ""
+	if len(lines) == 2 {
+		reason = strings.TrimSpace(lines[1])
+	}
 	log.Printf("Checker script output: %s", status)
 
 	if status != "UP" && status != "DOWN" {
@@ -97,7 +107,7 @@ func PerformCheckDown(gameBoxID uint) error {
 	}
 
 	// Save the check down.
-	if err := SaveCheckDown(gameBox, gameBoxID, isDown); err != nil {
+	if err := SaveCheckDown(gameBox, gameBoxID, snapshot.NowRound, isDown, reason); err != nil {
 		return errors.New(fmt.Sprintf("error saving check down: %v", err))
 	}
 
@@ -147,8 +157,9 @@ func CheckDown(c *gin.Context) (int, interface{}) {
 	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "general.service_up"))
 }
 
-// SaveCheckDown saves the check down status of a game box to the database.
-func SaveCheckDown(gameBox dbold.GameBox, gameBoxID uint, isDown bool) error {
+// SaveCheckDown saves the check down status of a game box in the given round to the database.
+// The reason is sent to the team of the game box if it is down.
+func SaveCheckDown(gameBox dbold.GameBox, gameBoxID uint, round int, isDown bool, reason string) error {
 	status := "UP"
 	if isDown {
 		status = "DOWN"
@@ -167,7 +178,7 @@ func SaveCheckDown(gameBox dbold.GameBox, gameBoxID uint, isDown bool) error {
 			TeamID:      gameBox.TeamID,
 			ChallengeID: gameBox.ChallengeID,
 			GameBoxID:   gameBoxID,
-			Round:       timer.Get().NowRound,
+			Round:       round,
 		}).Error; err != nil {
 			log.Printf("Error creating down action: %v", err)
 			tx.Rollback()
@@ -176,7 +187,7 @@ func SaveCheckDown(gameBox dbold.GameBox, gameBoxID uint, isDown bool) error {
 		tx.Commit()
 
 		// Check down hook
-		go webhook.Add(webhook.CHECK_DOWN_HOOK, gin.H{"team": gameBox.TeamID, "gamebox": gameBox.ID})
+		webhook.Add(webhook.CHECK_DOWN_HOOK, gin.H{"team": gameBox.TeamID, "challenge": gameBox.ChallengeID, "gamebox": gameBox.ID})
 
 		// Update the gamebox status in ranking list.
 		SetRankList()
@@ -202,6 +213,10 @@ func SaveCheckDown(gameBox dbold.GameBox, gameBoxID uint, isDown bool) error {
 			log.Printf("Error writing live log: %v", err)
 			return err
 		}
+		if err := livelog.WriteTeam(gameBox.TeamID, livelog.NewLine("check_down",
+			gin.H{"Round": round, "Challenge": challenge.Title, "Reason": reason})); err != nil {
+			log.Printf("Error writing team live log: %v", err)
+		}
 	}
 
 	SetRankList()
@@ -220,7 +235,7 @@ func ScheduleCheckDowns() {
 			// Generate a random interval between 1 and round duration - 10 seconds.
 			randomInterval := time.Duration(rand.Intn((int(conf.Game.RoundDuration)*60)-10)+1) * time.Second
 			log.Printf("Scheduling check down for gamebox ID %d in %v", gameBox.ID, randomInterval)
-			time.Sleep(randomInterval)
+			timeutil.Sleep(randomInterval)
 
 			if err := PerformCheckDown(gameBox.ID); err != nil {
 				log.Printf("Error performing check down for gamebox ID %d: %v", gameBox.ID, err)
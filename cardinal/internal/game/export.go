@@ -0,0 +1,336 @@
+package game
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/rank"
+	"Cardinal/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	standingsFormatCTFtime = "ctftime"
+	standingsFormatJSON    = "json"
+	standingsFormatCSV     = "csv"
+
+	scoreboardFinal  = "final"
+	scoreboardFrozen = "frozen"
+)
+
+// Standings is the exported scoreboard with the per-challenge breakdowns.
+type Standings struct {
+	// Challenges is the titles of the visible challenges ordered by the challenge ID.
+	Challenges []string
+	// FrozenAt is the time of the scoreboard, it is nil for the final scoreboard.
+	FrozenAt  *time.Time
+	Standings []*Standing
+}
+
+// Standing is a row of the exported scoreboard.
+type Standing struct {
+	Pos      int
+	TeamID   uint
+	TeamName string
+	Score    float64
+	// ChallengeScores is the score of the team's game box of each challenge, in the order of the challenges.
+	ChallengeScores []float64
+}
+
+// ctftimeStandings is the scoreboard feed format of CTFtime.
+type ctftimeStandings struct {
+	Tasks     []string          `json:"tasks"`
+	Standings []ctftimeStanding `json:"standings"`
+}
+
+type ctftimeStanding struct {
+	Pos       int                        `json:"pos"`
+	Team      string                     `json:"team"`
+	Score     float64                    `json:"score"`
+	TaskStats map[string]ctftimeTaskStat `json:"taskStats"`
+}
+
+type ctftimeTaskStat struct {
+	Points float64 `json:"points"`
+}
+
+// getStandings calculates the scoreboard from the scores, first bloods and score adjustments before the given time,
+// the same as the game box and team scores. All of them are included if the time is zero.
+// The excluded teams are left out of the scoreboard and the positions.
+func getStandings(before time.Time, exclude map[uint]bool) (*Standings, error) {
+	var visibleGameBoxes []dbold.GameBox
+	if err := dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Visible: true}).Order("challenge_id").Find(&visibleGameBoxes).Error; err != nil {
+		return nil, err
+	}
+	challengeIDs := make([]uint, 0)
+	for _, gameBox := range visibleGameBoxes {
+		if len(challengeIDs) == 0 || challengeIDs[len(challengeIDs)-1] != gameBox.ChallengeID {
+			challengeIDs = append(challengeIDs, gameBox.ChallengeID)
+		}
+	}
+
+	var challenges []dbold.Challenge
+	if len(challengeIDs) != 0 {
+		if err := dbold.MySQL.Model(&dbold.Challenge{}).Where("id IN (?)", challengeIDs).Order("id").Find(&challenges).Error; err != nil {
+			return nil, err
+		}
+	}
+	challengeIndex := make(map[uint]int, len(challenges))
+	standings := &Standings{
+		Challenges: make([]string, 0, len(challenges)),
+		Standings:  make([]*Standing, 0),
+	}
+	for i, challenge := range challenges {
+		challengeIndex[challenge.ID] = i
+		standings.Challenges = append(standings.Challenges, challenge.Title)
+	}
+
+	var gameBoxScores []struct {
+		GameBoxID uint
+		Score     float64
+	}
+	query := dbold.MySQL.Model(&dbold.Score{}).Select("game_box_id, SUM(score) AS score").Group("game_box_id")
+	if !before.IsZero() {
+		query = query.Where("created_at < ?", before)
+	}
+	if err := query.Scan(&gameBoxScores).Error; err != nil {
+		return nil, err
+	}
+	scores := make(map[uint]float64, len(gameBoxScores))
+	for _, score := range gameBoxScores {
+		scores[score.GameBoxID] = score.Score
+	}
+
+	// The first blood bonus belongs to the attacker's game box of the challenge.
+	type teamChallenge struct {
+		teamID      uint
+		challengeID uint
+	}
+	var firstBloods []dbold.FirstBlood
+	firstBloodQuery := dbold.MySQL.Model(&dbold.FirstBlood{})
+	if !before.IsZero() {
+		firstBloodQuery = firstBloodQuery.Where("created_at < ?", before)
+	}
+	if err := firstBloodQuery.Find(&firstBloods).Error; err != nil {
+		return nil, err
+	}
+	firstBloodScores := make(map[teamChallenge]float64, len(firstBloods))
+	for _, firstBlood := range firstBloods {
+		firstBloodScores[teamChallenge{firstBlood.AttackerTeamID, firstBlood.ChallengeID}] += firstBlood.Score
+	}
+
+	// The adjustment which was reverted after the given time was still counted at that time.
+	var scoreAdjustments []dbold.ScoreAdjustment
+	adjustmentQuery := dbold.MySQL.Model(&dbold.ScoreAdjustment{})
+	if before.IsZero() {
+		adjustmentQuery = adjustmentQuery.Where("reverted_at IS NULL")
+	} else {
+		adjustmentQuery = adjustmentQuery.Where("created_at < ? AND (reverted_at IS NULL OR reverted_at >= ?)", before, before)
+	}
+	if err := adjustmentQuery.Find(&scoreAdjustments).Error; err != nil {
+		return nil, err
+	}
+	teamAdjustments := make(map[uint]float64)
+	for _, adjustment := range scoreAdjustments {
+		if adjustment.GameBoxID != 0 {
+			scores[adjustment.GameBoxID] += adjustment.Score
+		} else {
+			teamAdjustments[adjustment.TeamID] += adjustment.Score
+		}
+	}
+
+	var teams []dbold.Team
+	if err := dbold.MySQL.Model(&dbold.Team{}).Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	teamStandings := make(map[uint]*Standing, len(teams))
+	for _, team := range teams {
+		if exclude[team.ID] {
+			continue
+		}
+		standing := &Standing{
+			TeamID:          team.ID,
+			TeamName:        team.Name,
+			Score:           teamAdjustments[team.ID],
+			ChallengeScores: make([]float64, len(challenges)),
+		}
+		teamStandings[team.ID] = standing
+		standings.Standings = append(standings.Standings, standing)
+	}
+
+	// The score of the game box is the challenge's base score plus the sum of its scores, first bloods and adjustments.
+	for _, gameBox := range visibleGameBoxes {
+		standing, ok := teamStandings[gameBox.TeamID]
+		if !ok {
+			continue
+		}
+		index, ok := challengeIndex[gameBox.ChallengeID]
+		if !ok {
+			continue
+		}
+		score := float64(challenges[index].BaseScore) + scores[gameBox.ID] + firstBloodScores[teamChallenge{gameBox.TeamID, gameBox.ChallengeID}]
+		standing.ChallengeScores[index] += score
+		standing.Score += score
+	}
+
+	// The teams are ranked in the same way as the ranking list, so the teams with the same score are ordered by the tie breakers.
+	rankedTeams := make([]dbold.Team, 0, len(standings.Standings))
+	for _, standing := range standings.Standings {
+		rankedTeams = append(rankedTeams, dbold.Team{Model: gorm.Model{ID: standing.TeamID}, Score: standing.Score})
+	}
+	ranking, err := rankTeams(rankedTeams, before)
+	if err != nil {
+		return nil, err
+	}
+	position := ranking.Position()
+	sort.SliceStable(standings.Standings, func(i, j int) bool {
+		return position[standings.Standings[i].TeamID] < position[standings.Standings[j].TeamID]
+	})
+	for _, standing := range standings.Standings {
+		standing.Pos = int(ranking.Ranks[standing.TeamID])
+	}
+	return standings, nil
+}
+
+// ctftime converts the standings to the CTFtime scoreboard feed.
+func (s *Standings) ctftime() *ctftimeStandings {
+	feed := &ctftimeStandings{
+		Tasks:     s.Challenges,
+		Standings: make([]ctftimeStanding, 0, len(s.Standings)),
+	}
+	for _, standing := range s.Standings {
+		taskStats := make(map[string]ctftimeTaskStat, len(s.Challenges))
+		for i, title := range s.Challenges {
+			taskStats[title] = ctftimeTaskStat{Points: standing.ChallengeScores[i]}
+		}
+		feed.Standings = append(feed.Standings, ctftimeStanding{
+			Pos:       standing.Pos,
+			Team:      standing.TeamName,
+			Score:     standing.Score,
+			TaskStats: taskStats,
+		})
+	}
+	return feed
+}
+
+// csv returns the standings in CSV, with a column for each challenge.
+func (s *Standings) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := append([]string{"Pos", "TeamID", "Team", "Score"}, s.Challenges...)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, standing := range s.Standings {
+		record := []string{
+			strconv.Itoa(standing.Pos),
+			strconv.Itoa(int(standing.TeamID)),
+			standing.TeamName,
+			strconv.FormatFloat(standing.Score, 'f', -1, 64),
+		}
+		for _, score := range standing.ChallengeScores {
+			record = append(record, strconv.FormatFloat(score, 'f', -1, 64))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// parseExcludedTeams parses the `exclude` query, which is the comma-separated IDs of the teams
+// to leave out such as the organizer teams.
+func parseExcludedTeams(c *gin.Context) (map[uint]bool, bool) {
+	exclude := make(map[uint]bool)
+	for _, value := range strings.Split(c.Query("exclude"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return nil, false
+		}
+		exclude[uint(id)] = true
+	}
+	return exclude, true
+}
+
+// ExportStandings exports the scoreboard in the CTFtime feed, JSON or CSV format for manager.
+// The `scoreboard` query can be `final` or `frozen`, the frozen one only includes the scores before the freeze.
+func ExportStandings(c *gin.Context) {
+	format := c.DefaultQuery("format", standingsFormatCTFtime)
+	if format != standingsFormatCTFtime && format != standingsFormatJSON && format != standingsFormatCSV {
+		c.JSON(utils.MakeErrJSON(400, 40070,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		))
+		return
+	}
+
+	exclude, ok := parseExcludedTeams(c)
+	if !ok {
+		c.JSON(utils.MakeErrJSON(400, 40070,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		))
+		return
+	}
+
+	var frozenAt time.Time
+	switch c.DefaultQuery("scoreboard", scoreboardFinal) {
+	case scoreboardFinal:
+	case scoreboardFrozen:
+		frozenAt = rank.FreezeAt()
+		if frozenAt.IsZero() {
+			c.JSON(utils.MakeErrJSON(400, 40071,
+				locales.I18n.T(c.GetString("lang"), "general.not_frozen"),
+			))
+			return
+		}
+	default:
+		c.JSON(utils.MakeErrJSON(400, 40070,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		))
+		return
+	}
+
+	standings, err := getStandings(frozenAt, exclude)
+	if err != nil {
+		c.JSON(utils.MakeErrJSON(500, 50036,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		))
+		return
+	}
+	if !frozenAt.IsZero() {
+		standings.FrozenAt = &frozenAt
+	}
+
+	switch format {
+	case standingsFormatCTFtime:
+		c.Header("Content-Disposition", `attachment; filename="standings.json"`)
+		c.JSON(http.StatusOK, standings.ctftime())
+	case standingsFormatJSON:
+		c.Header("Content-Disposition", `attachment; filename="standings.json"`)
+		c.JSON(http.StatusOK, standings)
+	case standingsFormatCSV:
+		data, err := standings.csv()
+		if err != nil {
+			c.JSON(utils.MakeErrJSON(500, 50036,
+				locales.I18n.T(c.GetString("lang"), "general.server_error"),
+			))
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="standings.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	}
+}
@@ -0,0 +1,86 @@
+package game
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+
+	"Cardinal/internal/asteroid"
+	"Cardinal/internal/conf"
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/misc/webhook"
+	"Cardinal/internal/rank"
+	"Cardinal/internal/timeutil"
+)
+
+// takeFirstBlood records the first blood if the team is the first one to capture the challenge (or the victim),
+// then announces it through the live log, webhook and asteroid.
+// It returns false if the first blood has been taken by another team.
+func takeFirstBlood(attacker dbold.Team, flag dbold.Flag, challenge dbold.Challenge) (bool, error) {
+	firstBlood := &dbold.FirstBlood{
+		ChallengeID:    flag.ChallengeID,
+		TeamID:         flag.TeamID,
+		GameBoxID:      flag.GameBoxID,
+		AttackerTeamID: attacker.ID,
+		Round:          flag.Round,
+		Score:          float64(conf.Game.FirstBloodScore),
+	}
+	if conf.Game.FirstBloodPerVictim {
+		firstBlood.VictimKey = flag.TeamID
+	}
+
+	// The unique index rejects the first blood which has been taken, even if the flags are submitted at the same time.
+	if err := dbold.MySQL.Create(firstBlood).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// Add the bonus to the attacker's gamebox and team score.
+	calculateGameBoxScore(dbold.MySQL)
+	calculateTeamScore(dbold.MySQL)
+
+	var victim dbold.Team
+	dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: flag.TeamID}}).Find(&victim)
+
+	webhook.Add(webhook.FIRST_BLOOD_HOOK, gin.H{
+		"from":      attacker.ID,
+		"to":        victim.ID,
+		"challenge": challenge.ID,
+		"gamebox":   flag.GameBoxID,
+		"score":     firstBlood.Score,
+	})
+
+	// The public announcements are hidden while the ranking list is frozen.
+	if !rank.IsFrozen(timeutil.Now()) {
+		_ = livelog.Stream.Write(livelog.GlobalStream, livelog.NewLine("first_blood", gin.H{
+			"Round":     firstBlood.Round,
+			"From":      attacker.Name,
+			"To":        victim.Name,
+			"Challenge": challenge.Title,
+			"Score":     firstBlood.Score,
+		}))
+		asteroid.SendFirstBlood(int(attacker.ID), int(victim.ID), challenge.Title)
+	}
+	return true, nil
+}
+
+// firstBloods returns the challenges whose first blood has been taken by each team.
+func firstBloods() map[uint]map[uint]bool {
+	var records []dbold.FirstBlood
+	dbold.MySQL.Model(&dbold.FirstBlood{}).Find(&records)
+
+	result := make(map[uint]map[uint]bool)
+	for _, record := range records {
+		if result[record.AttackerTeamID] == nil {
+			result[record.AttackerTeamID] = make(map[uint]bool)
+		}
+		result[record.AttackerTeamID][record.ChallengeID] = true
+	}
+	return result
+}
@@ -20,7 +20,9 @@ import (
 	"Cardinal/internal/locales"
 	"Cardinal/internal/logger"
 	"Cardinal/internal/misc/webhook"
+	"Cardinal/internal/rank"
 	"Cardinal/internal/timer"
+	"Cardinal/internal/timeutil"
 	"Cardinal/internal/utils"
 )
 
@@ -28,8 +30,11 @@ import (
 func SubmitFlag(c *gin.Context) (int, interface{}) {
 	log.Println("SubmitFlag called")
 
+	// The flag is validated against the round when it is submitted, even if a new round starts meanwhile.
+	snapshot := timer.GetSnapshot()
+
 	// Submit flag is forbidden if the competition isn't started.
-	if timer.Get().Status != "on" {
+	if snapshot.Status != "on" {
 		log.Println("Competition has not started")
 		return utils.MakeErrJSON(403, 40304,
 			locales.I18n.T(c.GetString("lang"), "general.not_begin"),
@@ -72,7 +77,7 @@ func SubmitFlag(c *gin.Context) (int, interface{}) {
 	log.Printf("Flag submitted: %s\n", inputForm.Flag)
 
 	var flagDataPrevRound dbold.Flag
-	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{Flag: inputForm.Flag, Round: (timer.Get().NowRound - 1)}).Find(&flagDataPrevRound)
+	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{Flag: inputForm.Flag, Round: (snapshot.NowRound - 1)}).Find(&flagDataPrevRound)
 
 	if flagDataPrevRound.Flag == inputForm.Flag { // Please note that you are not allowed to submit the flag of the previous round
 		log.Printf("Flag for previous round detected: TeamID: %d, Flag TeamID: %d\n", teamID, flagDataPrevRound.TeamID)
@@ -82,7 +87,7 @@ func SubmitFlag(c *gin.Context) (int, interface{}) {
 	}
 
 	var flagDataNextRound dbold.Flag
-	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{Flag: inputForm.Flag, Round: (timer.Get().NowRound + 1)}).Find(&flagDataNextRound)
+	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{Flag: inputForm.Flag, Round: (snapshot.NowRound + 1)}).Find(&flagDataNextRound)
 
 	if flagDataNextRound.Flag == inputForm.Flag { // Please note that you are not allowed to submit the flag of the next round
 		log.Printf("Flag for next round detected: TeamID: %d, Flag TeamID: %d\n", teamID, flagDataNextRound.TeamID)
@@ -92,7 +97,7 @@ func SubmitFlag(c *gin.Context) (int, interface{}) {
 	}
 
 	var flagData dbold.Flag
-	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{Flag: inputForm.Flag, Round: timer.Get().NowRound}).Find(&flagData) // Pay attention to whether it is this round
+	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{Flag: inputForm.Flag, Round: snapshot.NowRound}).Find(&flagData) // Pay attention to whether it is this round
 
 	if flagData.ID == 0 { // Please note that you are not allowed to submit your own flag
 		log.Printf("Invalid flag detected: TeamID: %d, Flag TeamID: %d\n", teamID, flagData.TeamID)
@@ -130,7 +135,7 @@ func SubmitFlag(c *gin.Context) (int, interface{}) {
 		log.Printf("Repeat flag submission detected for TeamID: %d\n", teamID)
 		// Animate Asteroid
 		animateAsteroid, _ := strconv.ParseBool(dynamic_config.Get(utils.ANIMATE_ASTEROID))
-		if animateAsteroid {
+		if animateAsteroid && !rank.IsFrozen(timeutil.Now()) {
 			log.Printf("Sending asteroid animation for attack: Attacker %d -> Victim %d\n", teamID, flagData.TeamID)
 			asteroid.SendAttack(int(teamID), int(flagData.TeamID))
 		}
@@ -163,26 +168,47 @@ func SubmitFlag(c *gin.Context) (int, interface{}) {
 	tx.Commit()
 	log.Println("Attack record saved successfully")
 
+	// Get challenge data
+	var challenge dbold.Challenge
+	dbold.MySQL.Model(&dbold.Challenge{}).Where(&dbold.Challenge{Model: gorm.Model{ID: flagData.ChallengeID}}).Find(&challenge)
+
+	// Take the first blood of the challenge, its bonus is shown in the ranking list below.
+	if conf.Game.FirstBloodScore > 0 {
+		if _, err := takeFirstBlood(t, flagData, challenge); err != nil {
+			log.Printf("Error taking first blood: %v", err)
+		}
+	}
+
 	// Update the gamebox status in ranking list.
 	log.Println("Updating rank list")
 	SetRankList()
 	// Webhook
 	log.Println("Sending webhook for flag submission")
-	go webhook.Add(webhook.SUBMIT_FLAG_HOOK, gin.H{"from": teamID, "to": gamebox.TeamID, "gamebox": gamebox.ID})
-	// Send Unity3D attack message.
-	log.Printf("Sending Unity3D attack message: Attacker %d -> Victim %d\n", teamID, flagData.TeamID)
-	asteroid.SendAttack(int(teamID), int(flagData.TeamID))
-
-	// Get attack team data
-	var flagTeam dbold.Team
-	dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: flagData.TeamID}}).Find(&flagTeam)
-	// Get challenge data
-	var challenge dbold.Challenge
-	dbold.MySQL.Model(&dbold.Challenge{}).Where(&dbold.Challenge{Model: gorm.Model{ID: flagData.ChallengeID}}).Find(&challenge)
-	// Live log
-	log.Printf("Writing live log: From %s -> To %s, Challenge: %s\n", t.Name, flagTeam.Name, challenge.Title)
-	_ = livelog.Stream.Write(livelog.GlobalStream, livelog.NewLine("submit_flag",
-		gin.H{"Round": timer.Get().NowRound, "From": t.Name, "To": flagTeam.Name, "Challenge": challenge.Title}))
+	webhook.Add(webhook.SUBMIT_FLAG_HOOK, gin.H{"from": teamID, "to": gamebox.TeamID, "challenge": gamebox.ChallengeID, "gamebox": gamebox.ID})
+
+	// Private live log of the victim team, the attacker is only shown if it is configured.
+	captured := gin.H{"Round": snapshot.NowRound, "Challenge": challenge.Title}
+	if showAttacker, _ := strconv.ParseBool(dynamic_config.Get(utils.SHOW_ATTACKER)); showAttacker {
+		captured["Attacker"] = t.Name
+	}
+	if err := livelog.WriteTeam(flagData.TeamID, livelog.NewLine("flag_captured", captured)); err != nil {
+		log.Printf("Error writing team live log: %v", err)
+	}
+
+	// The public attack messages are hidden while the ranking list is frozen.
+	if !rank.IsFrozen(timeutil.Now()) {
+		// Send Unity3D attack message.
+		log.Printf("Sending Unity3D attack message: Attacker %d -> Victim %d\n", teamID, flagData.TeamID)
+		asteroid.SendAttack(int(teamID), int(flagData.TeamID))
+
+		// Get attack team data
+		var flagTeam dbold.Team
+		dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: flagData.TeamID}}).Find(&flagTeam)
+		// Live log
+		log.Printf("Writing live log: From %s -> To %s, Challenge: %s\n", t.Name, flagTeam.Name, challenge.Title)
+		_ = livelog.Stream.Write(livelog.GlobalStream, livelog.NewLine("submit_flag",
+			gin.H{"Round": snapshot.NowRound, "From": t.Name, "To": flagTeam.Name, "Challenge": challenge.Title}))
+	}
 
 	log.Println("Flag submitted successfully")
 	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "flag.submit_success"))
@@ -280,7 +306,7 @@ func GenerateFlag(c *gin.Context) (int, interface{}) {
 	flagSuffix := dynamic_config.Get(utils.FLAG_SUFFIX_CONF)
 
 	salt := utils.Sha1Encode(conf.App.SecuritySalt)
-	for round := 1; round <= timer.Get().TotalRound; round++ {
+	for round := 1; round <= timer.GetSnapshot().TotalRound; round++ {
 		// Flag = FlagPrefix + hmacSha1(TeamID + | + GameBoxID + | + Round, sha1(salt)) + FlagSuffix
 		for _, gameBox := range gameBoxes {
 			flag := flagPrefix + utils.HmacSha1Encode(fmt.Sprintf("%d|%d|%d", gameBox.TeamID, gameBox.ID, round), salt) + flagSuffix
@@ -318,6 +344,9 @@ func RefreshFlag() {
 
 	log.Printf("INFO: Retrieved %d challenges with AutoRefreshFlag set to true.\n", len(challenges))
 
+	// All the flags are planted for the same round, even if it takes longer than the round.
+	round := timer.GetSnapshot().NowRound
+
 	for _, challenge := range challenges {
 		var gameboxes []dbold.GameBox
 		result = dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{ChallengeID: challenge.ID}).Find(&gameboxes)
@@ -334,7 +363,7 @@ func RefreshFlag() {
 				result := dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{
 					TeamID:    gamebox.TeamID,
 					GameBoxID: gamebox.ID,
-					Round:     timer.Get().NowRound,
+					Round:     round,
 				}).Find(&flag)
 
 				if result.Error != nil {
@@ -348,8 +377,11 @@ func RefreshFlag() {
 
 				_, err := utils.SSHExecute(gamebox.IP, gamebox.SSHPort, gamebox.SSHUser, gamebox.SSHPassword, command)
 				if err != nil {
-					log.Printf("IMPORTANT: Team: %d GameBox: %d Round: %d Failed to plant new flag: %v\n", gamebox.TeamID, gamebox.ID, timer.Get().NowRound, err.Error())
-					logger.New(logger.IMPORTANT, "system", string(fmt.Sprintf("Team: %d GameBox: %d Round: %d Failed to plant new flag: %v\n", gamebox.TeamID, gamebox.ID, timer.Get().NowRound, err.Error())))
+					log.Printf("IMPORTANT: Team: %d GameBox: %d Round: %d Failed to plant new flag: %v\n", gamebox.TeamID, gamebox.ID, round, err.Error())
+					logger.New(logger.IMPORTANT, "system", string(fmt.Sprintf("Team: %d GameBox: %d Round: %d Failed to plant new flag: %v\n", gamebox.TeamID, gamebox.ID, round, err.Error())))
+					webhook.Add(webhook.FLAG_PLANT_FAILED_HOOK, gin.H{"team": gamebox.TeamID, "challenge": gamebox.ChallengeID, "gamebox": gamebox.ID, "round": round})
+					_ = livelog.WriteTeam(gamebox.TeamID, livelog.NewLine("flag_plant_failed",
+						gin.H{"Round": round, "Challenge": challenge.Title}))
 
 				} else {
 					log.Printf("INFO: Successfully executed command for GameBox ID %d.\n", gamebox.ID)
@@ -417,9 +449,3 @@ func TestSSH(c *gin.Context) (int, interface{}) {
 	}
 	return utils.MakeSuccessJSON(output)
 }
-
-func GetLatestScoreRound() int {
-	var latestScore dbold.Score
-	dbold.MySQL.Model(&dbold.Score{}).Order("`round` DESC").Limit(1).Find(&latestScore)
-	return latestScore.Round
-}
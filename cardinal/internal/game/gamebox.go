@@ -17,7 +17,7 @@ import (
 
 // GetSelfGameBoxes returns the gameboxes which belong to the team.
 func GetSelfGameBoxes(c *gin.Context) (int, interface{}) {
-	if timer.Get().Status == "wait" {
+	if timer.GetSnapshot().Status == "wait" {
 		return utils.MakeSuccessJSON([]int{})
 	}
 
@@ -0,0 +1,233 @@
+package game
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/utils"
+)
+
+// TeamHistory is the score timeline of a single team.
+type TeamHistory struct {
+	TeamID   uint
+	TeamName string
+	TeamLogo string
+	Points   []*HistoryPoint // Ordered by round.
+}
+
+// HistoryPoint is the team's standing at the end of a round.
+type HistoryPoint struct {
+	Round     int
+	Rank      int
+	Score     float64
+	GameBoxes []*GameBoxHistoryPoint `json:",omitempty"` // Manager only
+}
+
+// GameBoxHistoryPoint is the gamebox score at the end of a round.
+type GameBoxHistoryPoint struct {
+	ChallengeID uint
+	Score       float64
+}
+
+type rankHistoryOptions struct {
+	TeamID     uint
+	StartRound int
+	EndRound   int // If EndRound is zero, it returns the histories until the latest round.
+
+	ShowGameBoxScore bool
+}
+
+// snapshotRankHistory saves every team's score, rank and visible gameboxes' scores at the end of the given round.
+// The previous snapshot of the same round will be replaced, as the round may be calculated again.
+func snapshotRankHistory(round int) error {
+	var teams []dbold.Team
+	if err := dbold.MySQL.Model(&dbold.Team{}).Order("score DESC, id ASC").Find(&teams).Error; err != nil {
+		return err
+	}
+	ranking, err := rankTeams(teams, time.Time{})
+	if err != nil {
+		return err
+	}
+	var gameBoxes []dbold.GameBox
+	if err := dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Visible: true}).Find(&gameBoxes).Error; err != nil {
+		return err
+	}
+
+	tx := dbold.MySQL.Begin()
+	if err := tx.Unscoped().Where("round = ?", round).Delete(&dbold.RankHistory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("round = ?", round).Delete(&dbold.GameBoxHistory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The teams with the same score and tie breakers share the same rank, and the following ranks are skipped.
+	for _, team := range teams {
+		if err := tx.Create(&dbold.RankHistory{
+			Round:  round,
+			TeamID: team.ID,
+			Rank:   int(ranking.Ranks[team.ID]),
+			Score:  team.Score,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for _, gameBox := range gameBoxes {
+		if err := tx.Create(&dbold.GameBoxHistory{
+			Round:       round,
+			TeamID:      gameBox.TeamID,
+			ChallengeID: gameBox.ChallengeID,
+			GameBoxID:   gameBox.ID,
+			Score:       gameBox.Score,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// rankHistory returns the score timeline of all the teams, or only one team if the team ID is given.
+func rankHistory(opts rankHistoryOptions) ([]*TeamHistory, error) {
+	rankQuery := dbold.MySQL.Model(&dbold.RankHistory{}).Where(&dbold.RankHistory{TeamID: opts.TeamID})
+	gameBoxQuery := dbold.MySQL.Model(&dbold.GameBoxHistory{}).Where(&dbold.GameBoxHistory{TeamID: opts.TeamID})
+	if opts.StartRound != 0 {
+		rankQuery = rankQuery.Where("round >= ?", opts.StartRound)
+		gameBoxQuery = gameBoxQuery.Where("round >= ?", opts.StartRound)
+	}
+	if opts.EndRound != 0 {
+		rankQuery = rankQuery.Where("round <= ?", opts.EndRound)
+		gameBoxQuery = gameBoxQuery.Where("round <= ?", opts.EndRound)
+	}
+
+	var rankHistories []dbold.RankHistory
+	if err := rankQuery.Order("round ASC, `rank` ASC, team_id ASC").Find(&rankHistories).Error; err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		round  int
+		teamID uint
+	}
+	gameBoxPoints := make(map[key][]*GameBoxHistoryPoint)
+	if opts.ShowGameBoxScore {
+		var gameBoxHistories []dbold.GameBoxHistory
+		if err := gameBoxQuery.Order("round ASC, challenge_id ASC").Find(&gameBoxHistories).Error; err != nil {
+			return nil, err
+		}
+		for _, gameBoxHistory := range gameBoxHistories {
+			k := key{round: gameBoxHistory.Round, teamID: gameBoxHistory.TeamID}
+			gameBoxPoints[k] = append(gameBoxPoints[k], &GameBoxHistoryPoint{
+				ChallengeID: gameBoxHistory.ChallengeID,
+				Score:       gameBoxHistory.Score,
+			})
+		}
+	}
+
+	var teams []dbold.Team
+	if err := dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: opts.TeamID}}).Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	teamHistories := make([]*TeamHistory, 0, len(teams))
+	teamHistorySets := make(map[uint]*TeamHistory, len(teams))
+	for _, team := range teams {
+		teamHistory := &TeamHistory{
+			TeamID:   team.ID,
+			TeamName: team.Name,
+			TeamLogo: team.Logo,
+			Points:   []*HistoryPoint{},
+		}
+		teamHistories = append(teamHistories, teamHistory)
+		teamHistorySets[team.ID] = teamHistory
+	}
+
+	for _, history := range rankHistories {
+		teamHistory, ok := teamHistorySets[history.TeamID]
+		if !ok {
+			// The team has been deleted.
+			continue
+		}
+
+		point := &HistoryPoint{
+			Round: history.Round,
+			Rank:  history.Rank,
+			Score: history.Score,
+		}
+		if opts.ShowGameBoxScore {
+			point.GameBoxes = gameBoxPoints[key{round: history.Round, teamID: history.TeamID}]
+			if point.GameBoxes == nil {
+				point.GameBoxes = []*GameBoxHistoryPoint{}
+			}
+		}
+		teamHistory.Points = append(teamHistory.Points, point)
+	}
+	return teamHistories, nil
+}
+
+// parseRankHistoryOptions parses the `teamID`, `startRound` and `endRound` queries.
+func parseRankHistoryOptions(c *gin.Context) (rankHistoryOptions, bool) {
+	var opts rankHistoryOptions
+	teamID, err := strconv.Atoi(c.DefaultQuery("teamID", "0"))
+	if err != nil || teamID < 0 {
+		return opts, false
+	}
+	opts.TeamID = uint(teamID)
+
+	for key, value := range map[string]*int{"startRound": &opts.StartRound, "endRound": &opts.EndRound} {
+		v, err := strconv.Atoi(c.DefaultQuery(key, "0"))
+		if err != nil || v < 0 {
+			return opts, false
+		}
+		*value = v
+	}
+	return opts, true
+}
+
+// GetRankHistory returns the score timeline with the gamebox scores of all the teams for manager,
+// or only one team if the `teamID` query is given.
+func GetRankHistory(c *gin.Context) (int, interface{}) {
+	opts, ok := parseRankHistoryOptions(c)
+	if !ok {
+		return utils.MakeErrJSON(400, 40072,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+	opts.ShowGameBoxScore = true
+
+	history, err := rankHistory(opts)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50037,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	return utils.MakeSuccessJSON(history)
+}
+
+// GetTeamRankHistory returns the score timeline of all the teams for team,
+// or only one team if the `teamID` query is given.
+func GetTeamRankHistory(c *gin.Context) (int, interface{}) {
+	opts, ok := parseRankHistoryOptions(c)
+	if !ok {
+		return utils.MakeErrJSON(400, 40072,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+
+	history, err := rankHistory(opts)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50037,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	return utils.MakeSuccessJSON(history)
+}
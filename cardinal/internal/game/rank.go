@@ -1,12 +1,23 @@
 package game
 
 import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/patrickmn/go-cache"
 
+	"Cardinal/internal/conf"
+	"Cardinal/internal/db"
 	"Cardinal/internal/dbold"
 	"Cardinal/internal/locales"
 	"Cardinal/internal/logger"
+	"Cardinal/internal/rank"
 	"Cardinal/internal/store"
+	"Cardinal/internal/timer"
+	"Cardinal/internal/timeutil"
 )
 
 // RankItem is used to create the ranking list.
@@ -24,12 +35,14 @@ type GameBoxInfo struct {
 	Score      float64
 	IsAttacked bool
 	IsDown     bool
+	FirstBlood bool // The team took the first blood of the challenge.
 }
 
 // GameBoxStatus contains the gamebox info which for team.
 type GameBoxStatus struct {
 	IsAttacked bool
 	IsDown     bool
+	FirstBlood bool // The team took the first blood of the challenge.
 }
 
 // GetRankList returns the ranking list data for team from the cache.
@@ -83,24 +96,47 @@ func SetRankList() {
 	var managerRankList []*RankItem
 
 	var teams []dbold.Team
-	dbold.MySQL.Model(&dbold.Team{}).Order("score DESC").Find(&teams) // Ordered by the team score.
+	dbold.MySQL.Model(&dbold.Team{}).Order("score DESC, id ASC").Find(&teams) // Ordered by the team score.
+
+	// The teams with the same score are ordered by the tie breakers.
+	if ranking, err := rankTeams(teams, time.Time{}); err != nil {
+		log.Printf("Error ranking the teams: %v", err)
+	} else {
+		position := ranking.Position()
+		sort.SliceStable(teams, func(i, j int) bool {
+			return position[teams[i].ID] < position[teams[j].ID]
+		})
+	}
+
+	// Get all the visible gameboxes in one query, then group them by the team.
+	// The gameboxes are ordered by the challenge ID, to make sure the table header can match with the score correctly.
+	var visibleGameBoxes []dbold.GameBox
+	dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Visible: true}).Order("challenge_id").Find(&visibleGameBoxes)
+	teamGameBoxes := make(map[uint][]dbold.GameBox, len(teams))
+	for _, gamebox := range visibleGameBoxes {
+		teamGameBoxes[gamebox.TeamID] = append(teamGameBoxes[gamebox.TeamID], gamebox)
+	}
+
+	teamFirstBloods := firstBloods()
+
 	for _, team := range teams {
-		var gameboxes []dbold.GameBox
-		// Get the challenge data ordered by the challenge ID, to make sure the table header can match with the score correctly.
-		dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{TeamID: team.ID, Visible: true}).Order("challenge_id").Find(&gameboxes)
+		gameboxes := teamGameBoxes[team.ID]
 		var gameBoxInfo []*GameBoxInfo       // Gamebox info for manager.
 		var gameBoxStatuses []*GameBoxStatus // Gamebox info for users and public.
 
 		for _, gamebox := range gameboxes {
+			firstBlood := teamFirstBloods[team.ID][gamebox.ChallengeID]
 			gameBoxStatuses = append(gameBoxStatuses, &GameBoxStatus{
 				IsAttacked: gamebox.IsAttacked,
 				IsDown:     gamebox.IsDown,
+				FirstBlood: firstBlood,
 			})
 
 			gameBoxInfo = append(gameBoxInfo, &GameBoxInfo{
 				Score:      gamebox.Score,
 				IsAttacked: gamebox.IsAttacked,
 				IsDown:     gamebox.IsDown,
+				FirstBlood: firstBlood,
 			})
 		}
 
@@ -121,7 +157,216 @@ func SetRankList() {
 	}
 
 	// Save the ranking list for public into cache.
-	store.Set("rankList", rankList, cache.NoExpiration)
+	store.Set("rankList", publicRankList(rankList), cache.NoExpiration)
 	// Save the ranking list for manager into cache.
 	store.Set("rankManagerList", managerRankList, cache.NoExpiration)
 }
+
+// rankTeams ranks the teams by the score and the tie breakers in the config.
+// Only the rank histories and actions created before the given time are counted if it is not zero.
+func rankTeams(teams []dbold.Team, before time.Time) (*db.TeamRanking, error) {
+	scores := make(map[uint]float64, len(teams))
+	keys := make([]*db.TeamRankKey, 0, len(teams))
+	for _, team := range teams {
+		scores[team.ID] = team.Score
+		keys = append(keys, &db.TeamRankKey{
+			TeamID:      team.ID,
+			Score:       team.Score,
+			TieBreakers: make([]float64, 0, len(conf.Game.RankTieBreakers)),
+		})
+	}
+
+	for _, tieBreaker := range conf.Game.RankTieBreakers {
+		values, err := tieBreakerValues(db.RankTieBreaker(tieBreaker), scores, before)
+		if err != nil {
+			return nil, fmt.Errorf("get %q tie breaker: %v", tieBreaker, err)
+		}
+		for _, key := range keys {
+			key.TieBreakers = append(key.TieBreakers, values[key.TeamID])
+		}
+	}
+	return db.RankTeams(keys), nil
+}
+
+// tieBreakerValues returns the tie breaker value of the teams, the lower one ranks higher.
+func tieBreakerValues(tieBreaker db.RankTieBreaker, scores map[uint]float64, before time.Time) (map[uint]float64, error) {
+	switch tieBreaker {
+	case db.RankTieBreakerScoreReachedAt:
+		query := dbold.MySQL.Model(&dbold.RankHistory{}).Select("team_id, round, score").Order("round DESC")
+		if !before.IsZero() {
+			query = query.Where("created_at < ?", before)
+		}
+		var snapshots []*db.ScoreSnapshot
+		if err := query.Scan(&snapshots).Error; err != nil {
+			return nil, err
+		}
+		return db.ScoreReachedAt(scores, snapshots), nil
+
+	case db.RankTieBreakerFewestDowns, db.RankTieBreakerMostCaptures:
+		query, sign := dbold.MySQL.Model(&dbold.DownAction{}).Select("team_id, COUNT(*) AS count").Group("team_id"), 1.0
+		if tieBreaker == db.RankTieBreakerMostCaptures {
+			query, sign = dbold.MySQL.Model(&dbold.AttackAction{}).Select("attacker_team_id AS team_id, COUNT(*) AS count").Group("attacker_team_id"), -1.0
+		}
+
+		if !before.IsZero() {
+			query = query.Where("created_at < ?", before)
+		}
+
+		var counts []struct {
+			TeamID uint
+			Count  int
+		}
+		if err := query.Scan(&counts).Error; err != nil {
+			return nil, err
+		}
+		values := make(map[uint]float64, len(scores))
+		for _, count := range counts {
+			values[count.TeamID] = sign * float64(count.Count)
+		}
+		return values, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected rank tie breaker %q", tieBreaker)
+	}
+}
+
+var (
+	rankListHistoryLock sync.Mutex
+	// rankListHistory saves the latest public ranking list of each round, it's used to delay the public ranking list.
+	rankListHistory = make(map[int][]*RankItem)
+)
+
+// publicRankList returns the ranking list which can be shown to the public.
+// The previous public ranking list will be kept when the ranking list is frozen,
+// and the ranking list of N rounds before will be returned when the ranking list is delayed.
+func publicRankList(rankList []*RankItem) []*RankItem {
+	now := timeutil.Now()
+
+	rankListHistoryLock.Lock()
+	defer rankListHistoryLock.Unlock()
+
+	if rank.IsFrozen(now) {
+		// Keep the ranking list before frozen.
+		if previousRankList, ok := store.Get("rankList"); ok {
+			return previousRankList.([]*RankItem)
+		}
+		// The ranking list before frozen is lost after Cardinal restarted, rebuild it from the rank history.
+		return frozenRankList(rank.FreezeAt())
+	}
+
+	nowRound := timer.GetSnapshot().NowRound
+	rankListHistory[nowRound] = rankList
+
+	delayRound := int(rank.DelayRound(now))
+	if delayRound == 0 {
+		return rankList
+	}
+
+	// Remove the ranking lists which will never be used.
+	for round := range rankListHistory {
+		if round < nowRound-delayRound {
+			delete(rankListHistory, round)
+		}
+	}
+
+	delayedRankList, ok := rankListHistory[nowRound-delayRound]
+	if !ok {
+		// The ranking lists of the previous rounds are lost after Cardinal restarted, rebuild it from the rank history.
+		delayedRankList = historyRankList(nowRound-delayRound, time.Time{})
+		rankListHistory[nowRound-delayRound] = delayedRankList
+	}
+	return delayedRankList
+}
+
+// frozenRankList rebuilds the public ranking list at the given freeze time from the rank history.
+func frozenRankList(freezeAt time.Time) []*RankItem {
+	// Get the latest round which was calculated before frozen.
+	var latestHistory dbold.RankHistory
+	dbold.MySQL.Model(&dbold.RankHistory{}).Where("created_at < ?", freezeAt).Order("round DESC").Limit(1).Find(&latestHistory)
+	return historyRankList(latestHistory.Round+1, freezeAt)
+}
+
+// historyRankList rebuilds the public ranking list of the given round.
+// The scores come from the rank history of the previous round, for the scores are calculated when the next round begins,
+// and the gamebox statuses come from the actions of the given round.
+// Only the actions created before the given time are counted if it is not zero.
+func historyRankList(round int, before time.Time) []*RankItem {
+	if round <= 1 {
+		return []*RankItem{}
+	}
+
+	var rankHistories []dbold.RankHistory
+	dbold.MySQL.Model(&dbold.RankHistory{}).Where("round = ?", round-1).Order("`rank` ASC, team_id ASC").Find(&rankHistories)
+
+	var teams []dbold.Team
+	dbold.MySQL.Model(&dbold.Team{}).Find(&teams)
+	teamSets := make(map[uint]dbold.Team, len(teams))
+	for _, team := range teams {
+		teamSets[team.ID] = team
+	}
+
+	var visibleGameBoxes []dbold.GameBox
+	dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Visible: true}).Order("challenge_id").Find(&visibleGameBoxes)
+	teamGameBoxes := make(map[uint][]dbold.GameBox, len(teams))
+	for _, gamebox := range visibleGameBoxes {
+		teamGameBoxes[gamebox.TeamID] = append(teamGameBoxes[gamebox.TeamID], gamebox)
+	}
+
+	attackQuery := dbold.MySQL.Model(&dbold.AttackAction{}).Where("round = ?", round)
+	downQuery := dbold.MySQL.Model(&dbold.DownAction{}).Where("round = ?", round)
+	firstBloodQuery := dbold.MySQL.Model(&dbold.FirstBlood{}).Where("round <= ?", round)
+	if !before.IsZero() {
+		attackQuery = attackQuery.Where("created_at < ?", before)
+		downQuery = downQuery.Where("created_at < ?", before)
+		firstBloodQuery = firstBloodQuery.Where("created_at < ?", before)
+	}
+
+	var attackActions []dbold.AttackAction
+	attackQuery.Find(&attackActions)
+	attackedGameBoxes := make(map[uint]bool, len(attackActions))
+	for _, action := range attackActions {
+		attackedGameBoxes[action.GameBoxID] = true
+	}
+	var downActions []dbold.DownAction
+	downQuery.Find(&downActions)
+	downGameBoxes := make(map[uint]bool, len(downActions))
+	for _, action := range downActions {
+		downGameBoxes[action.GameBoxID] = true
+	}
+	var firstBloodRecords []dbold.FirstBlood
+	firstBloodQuery.Find(&firstBloodRecords)
+	teamFirstBloods := make(map[uint]map[uint]bool)
+	for _, record := range firstBloodRecords {
+		if teamFirstBloods[record.AttackerTeamID] == nil {
+			teamFirstBloods[record.AttackerTeamID] = make(map[uint]bool)
+		}
+		teamFirstBloods[record.AttackerTeamID][record.ChallengeID] = true
+	}
+
+	rankList := make([]*RankItem, 0, len(rankHistories))
+	for _, history := range rankHistories {
+		team, ok := teamSets[history.TeamID]
+		if !ok {
+			// The team has been deleted.
+			continue
+		}
+
+		var gameBoxStatuses []*GameBoxStatus
+		for _, gamebox := range teamGameBoxes[team.ID] {
+			gameBoxStatuses = append(gameBoxStatuses, &GameBoxStatus{
+				IsAttacked: attackedGameBoxes[gamebox.ID],
+				IsDown:     downGameBoxes[gamebox.ID],
+				FirstBlood: teamFirstBloods[team.ID][gamebox.ChallengeID],
+			})
+		}
+
+		rankList = append(rankList, &RankItem{
+			TeamID:        team.ID,
+			TeamName:      team.Name,
+			TeamLogo:      team.Logo,
+			Score:         history.Score,
+			GameBoxStatus: gameBoxStatuses,
+		})
+	}
+	return rankList
+}
@@ -1,6 +1,8 @@
 package game
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,153 +13,268 @@ import (
 	"Cardinal/internal/healthy"
 	"Cardinal/internal/locales"
 	"Cardinal/internal/logger"
+	"Cardinal/internal/misc/webhook"
+	"Cardinal/internal/timeutil"
+	"Cardinal/internal/utils"
 )
 
 // CalculateRoundScore will calculate the score of the given round.
-func CalculateRoundScore(round int) {
-	startTime := time.Now().UnixNano()
+// The scores are calculated in one transaction with the round marked as scored,
+// so the round is either scored completely or not at all. The round which has been scored will be skipped.
+func CalculateRoundScore(round int) error {
+	startTime := time.Now()
 
 	ScheduleCheckDowns() // Schedule the check down actions of this round.
 
-	// + Attacked score
-	addAttack(round)
-	// - Been attacked score
-	minusAttack(round)
+	var scored bool
+	if err := dbold.MySQL.Transaction(func(tx *gorm.DB) error {
+		// Lock the round marker, to prevent the round from being scored concurrently.
+		var r dbold.Round
+		if err := tx.Where(dbold.Round{Round: round}).FirstOrCreate(&r).Error; err != nil {
+			return fmt.Errorf("create round: %v", err)
+		}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", r.ID).Find(&r).Error; err != nil {
+			return fmt.Errorf("lock round: %v", err)
+		}
+		if r.ScoredAt != nil {
+			return nil
+		}
+
+		// + Attacked score
+		if err := addAttack(tx, round); err != nil {
+			return fmt.Errorf("add attack score: %v", err)
+		}
+		// - Been attacked score
+		if err := minusAttack(tx, round); err != nil {
+			return fmt.Errorf("minus attack score: %v", err)
+		}
 
-	// - Been check down
-	minusCheckDown(round)
-	// + Service online
-	addCheckDown(round)
+		// - Been check down
+		if err := minusCheckDown(tx, round); err != nil {
+			return fmt.Errorf("minus check down score: %v", err)
+		}
+		// + Service online
+		if err := addCheckDown(tx, round); err != nil {
+			return fmt.Errorf("add check down score: %v", err)
+		}
 
-	// Calculate and update all the gameboxes' score.
-	calculateGameBoxScore()
-	// Calculate and update all the teams' score.
-	calculateTeamScore()
+		// Calculate and update all the gameboxes' score.
+		if err := calculateGameBoxScore(tx); err != nil {
+			return fmt.Errorf("calculate gamebox score: %v", err)
+		}
+		// Calculate and update all the teams' score.
+		if err := calculateTeamScore(tx); err != nil {
+			return fmt.Errorf("calculate team score: %v", err)
+		}
+
+		if err := tx.Model(&dbold.Round{}).Where("id = ?", r.ID).Updates(map[string]interface{}{
+			"scored_at":        timeutil.Now(),
+			"scoring_duration": time.Since(startTime),
+		}).Error; err != nil {
+			return fmt.Errorf("mark round scored: %v", err)
+		}
+		scored = true
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !scored {
+		return nil
+	}
+
+	// Save the teams' rank and score of this round.
+	if err := snapshotRankHistory(round); err != nil {
+		logger.New(logger.IMPORTANT, "system", fmt.Sprintf("Failed to save the rank history of round %d: %v", round, err))
+	}
 
 	// Refresh the ranking list table header.
 	//s.SetRankListTitle()
 	// Refresh the ranking list.
 	SetRankList()
 
-	endTime := time.Now().UnixNano()
 	logger.New(logger.WARNING, "system", string(
 		locales.T("log.score_success",
 			gin.H{
 				"round": round,
-				"time":  float64(endTime-startTime) / float64(time.Second),
+				"time":  time.Since(startTime).Seconds(),
 			}),
 	))
 
+	webhook.Add(webhook.SCORE_RECOMPUTED_HOOK, gin.H{"round": round})
+
 	// Do healthy check to make sure the score is correct.
 	healthy.HealthyCheck()
+	// Check the scores against the actions, the violations will be sent through the webhook.
+	if report, err := healthy.CheckScore(round); err != nil {
+		logger.New(logger.IMPORTANT, "system", fmt.Sprintf("Failed to check the score of round %d: %v", round, err))
+	} else {
+		for _, violation := range report.Violations {
+			logger.New(logger.IMPORTANT, "system", fmt.Sprintf("Score check of round %d: %s", round, violation.Message))
+		}
+	}
+	return nil
 }
 
-// calculateGameBoxScore will calculate all the gameboxes' scores according to the data in scores table.
-func calculateGameBoxScore() {
-	var gameBoxes []dbold.GameBox
-	dbold.MySQL.Model(&dbold.GameBox{}).Find(&gameBoxes)
-	for _, gameBox := range gameBoxes {
-		var sc struct {
-			Score float64 `gorm:"Column:Score"`
-		}
-		dbold.MySQL.Table("scores").Select("SUM(score) AS Score").Where("`game_box_id` = ?", gameBox.ID).Scan(&sc)
+// GetScoreReport returns the latest score consistency report.
+// It checks the given round again if the `round` query is given.
+func GetScoreReport(c *gin.Context) (int, interface{}) {
+	round, err := strconv.Atoi(c.DefaultQuery("round", "0"))
+	if err != nil || round < 0 {
+		return utils.MakeErrJSON(400, 40078,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+	if round == 0 {
+		return utils.MakeSuccessJSON(healthy.LatestScoreReport())
+	}
 
-		var challenge dbold.Challenge
-		dbold.MySQL.Model(&dbold.Challenge{}).Where(&dbold.Challenge{Model: gorm.Model{ID: gameBox.ChallengeID}}).Find(&challenge)                                        // Get the gamebox's base score.
-		dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: gameBox.ID}}).Update(&dbold.Score{Score: float64(challenge.BaseScore) + sc.Score}) // Update the gamebox's score.
+	report, err := healthy.CheckScore(round)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50039,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
 	}
+	return utils.MakeSuccessJSON(report)
 }
 
-// calculateTeamScore will Calculate all the teams' score. (By sum the team's gameboxes' scores)
-func calculateTeamScore() {
-	var teams []dbold.Team
-	dbold.MySQL.Model(&dbold.Team{}).Find(&teams)
-	for _, t := range teams {
-		var sc struct {
-			Score float64 `gorm:"Column:Score"`
-		}
-		dbold.MySQL.Table("game_boxes").Select("SUM(score) AS Score").Where("`team_id` = ? AND `visible` = ?", t.ID, 1).Scan(&sc)
-		dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: t.ID}}).Update(&dbold.Team{Score: sc.Score})
+// calculateGameBoxScore will calculate all the gameboxes' scores according to the data in scores table.
+// The gameboxes' scores are updated in one query, the score is the challenge's base score plus the sum of the gamebox's scores,
+// the first blood bonuses of the challenge taken by the team and the gamebox's score adjustments which are not reverted.
+func calculateGameBoxScore(tx *gorm.DB) error {
+	return tx.Model(&dbold.GameBox{}).Update("score", gorm.Expr(
+		"COALESCE((SELECT challenges.base_score FROM challenges WHERE challenges.id = game_boxes.challenge_id AND challenges.deleted_at IS NULL), 0) + "+
+			"COALESCE((SELECT SUM(scores.score) FROM scores WHERE scores.game_box_id = game_boxes.id), 0) + "+
+			"COALESCE((SELECT SUM(first_bloods.score) FROM first_bloods WHERE first_bloods.attacker_team_id = game_boxes.team_id "+
+			"AND first_bloods.challenge_id = game_boxes.challenge_id AND first_bloods.deleted_at IS NULL), 0) + "+
+			"COALESCE((SELECT SUM(score_adjustments.score) FROM score_adjustments WHERE score_adjustments.game_box_id = game_boxes.id "+
+			"AND score_adjustments.reverted_at IS NULL AND score_adjustments.deleted_at IS NULL), 0)",
+	)).Error
+}
+
+// calculateTeamScore will Calculate all the teams' score. (By sum the team's visible gameboxes' scores)
+// The score adjustments applied to the team directly are added as well.
+func calculateTeamScore(tx *gorm.DB) error {
+	return tx.Model(&dbold.Team{}).Update("score", gorm.Expr(
+		"COALESCE((SELECT SUM(game_boxes.score) FROM game_boxes WHERE game_boxes.team_id = teams.id AND game_boxes.visible = ?), 0) + "+
+			"COALESCE((SELECT SUM(score_adjustments.score) FROM score_adjustments WHERE score_adjustments.team_id = teams.id "+
+			"AND score_adjustments.game_box_id = 0 AND score_adjustments.reverted_at IS NULL AND score_adjustments.deleted_at IS NULL), 0)", true,
+	)).Error
+}
+
+// scoreMultiplier returns the score multiplier of the round's phase recorded by the timer.
+// It returns 1 if the round has not been recorded.
+func scoreMultiplier(tx *gorm.DB, round int) (float64, error) {
+	var rounds []dbold.Round
+	if err := tx.Model(&dbold.Round{}).Where(&dbold.Round{Round: round}).Find(&rounds).Error; err != nil {
+		return 0, err
 	}
+	if len(rounds) == 0 || rounds[0].ScoreMultiplier == 0 {
+		return 1, nil
+	}
+	return rounds[0].ScoreMultiplier, nil
 }
 
 // addAttack will add scores to the attacker.
-func addAttack(round int) {
-	// Traversal all the gameboxes.
-	var gameBoxes []dbold.GameBox
-	dbold.MySQL.Model(&dbold.GameBox{}).Find(&gameBoxes)
-	for _, gameBox := range gameBoxes {
-		// This gamebox has been attacked or not.
-		var attackActions []dbold.AttackAction
-		dbold.MySQL.Model(&dbold.AttackAction{}).Where(&dbold.AttackAction{GameBoxID: gameBox.ID, Round: round}).Find(&attackActions)
-		if len(attackActions) != 0 {
-			score := float64(conf.Game.AttackScore) / float64(len(attackActions)) // Score which every attacker can get from this gamebox.
-			// Add score to the attackers.
-			for _, action := range attackActions {
-				// Get the attacker's gamebox ID of this challenge.
-				var attackerGameBox dbold.GameBox
-				dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{TeamID: action.AttackerTeamID, ChallengeID: gameBox.ChallengeID}).Find(&attackerGameBox)
-
-				dbold.MySQL.Create(&dbold.Score{
-					TeamID:    action.AttackerTeamID,
-					GameBoxID: attackerGameBox.ID,
-					Round:     round,
-					Reason:    "attack",
-					Score:     score,
-				})
-			}
-		}
+// The score of every attacked gamebox is divided equally by its attackers,
+// and added to the attacker's gamebox of the same challenge in one query.
+func addAttack(tx *gorm.DB, round int) error {
+	multiplier, err := scoreMultiplier(tx, round)
+	if err != nil {
+		return err
 	}
+
+	now := timeutil.Now()
+	return tx.Exec("INSERT INTO scores (created_at, updated_at, team_id, game_box_id, round, reason, score) "+
+		"SELECT ?, ?, attack_actions.attacker_team_id, COALESCE(attacker_game_boxes.id, 0), ?, ?, ? / attack_counts.count "+
+		"FROM attack_actions "+
+		"INNER JOIN (SELECT game_box_id, COUNT(*) AS count FROM attack_actions WHERE round = ? AND deleted_at IS NULL GROUP BY game_box_id) AS attack_counts "+
+		"ON attack_counts.game_box_id = attack_actions.game_box_id "+
+		"INNER JOIN game_boxes ON game_boxes.id = attack_actions.game_box_id AND game_boxes.deleted_at IS NULL "+
+		"LEFT JOIN game_boxes AS attacker_game_boxes ON attacker_game_boxes.team_id = attack_actions.attacker_team_id "+
+		"AND attacker_game_boxes.challenge_id = game_boxes.challenge_id AND attacker_game_boxes.deleted_at IS NULL "+
+		"WHERE attack_actions.round = ? AND attack_actions.deleted_at IS NULL",
+		now, now, round, "attack", float64(conf.Game.AttackScore)*multiplier, round, round,
+	).Error
 }
 
 // minusAttack will minus scores from the victim.
-func minusAttack(round int) {
+func minusAttack(tx *gorm.DB, round int) error {
 	var attackActions []struct {
 		GameBoxID uint `gorm:"game_box_id"`
 		TeamID    uint `gorm:"team_id"`
 	}
 
 	// Every gamebox can only be deducted once in one round.
-	dbold.MySQL.Table("attack_actions").Select("DISTINCT(`game_box_id`) AS game_box_id, team_id").Where(&dbold.AttackAction{Round: round}).Scan(&attackActions)
+	if err := tx.Table("attack_actions").Select("DISTINCT(`game_box_id`) AS game_box_id, team_id").Where(&dbold.AttackAction{Round: round}).Scan(&attackActions).Error; err != nil {
+		return err
+	}
 
+	multiplier, err := scoreMultiplier(tx, round)
+	if err != nil {
+		return err
+	}
+	score := float64(conf.Game.AttackScore) * multiplier
 	for _, action := range attackActions {
-		dbold.MySQL.Create(&dbold.Score{
+		if err := tx.Create(&dbold.Score{
 			TeamID:    action.TeamID,
 			GameBoxID: action.GameBoxID,
 			Round:     round,
 			Reason:    "been_attacked",
-			Score:     float64(-conf.Game.AttackScore),
-		})
+			Score:     -score,
+		}).Error; err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // minusCheckDown will minus scores from the service down gameboxes.
-func minusCheckDown(round int) {
+func minusCheckDown(tx *gorm.DB, round int) error {
 	// Get all the DownAction of this round.
 	var downActions []dbold.DownAction
-	dbold.MySQL.Model(&dbold.DownAction{}).Where(&dbold.DownAction{Round: round}).Find(&downActions)
+	if err := tx.Model(&dbold.DownAction{}).Where(&dbold.DownAction{Round: round}).Find(&downActions).Error; err != nil {
+		return err
+	}
 
+	multiplier, err := scoreMultiplier(tx, round)
+	if err != nil {
+		return err
+	}
+	score := float64(conf.Game.CheckDownScore) * multiplier
 	for _, action := range downActions {
-		dbold.MySQL.Create(&dbold.Score{
+		if err := tx.Create(&dbold.Score{
 			TeamID:    action.TeamID,
 			GameBoxID: action.GameBoxID,
 			Round:     round,
 			Reason:    "checkdown",
-			Score:     float64(-conf.Game.CheckDownScore),
-		})
+			Score:     -score,
+		}).Error; err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // addCheckDown will add scores to the service online gameboxes.
-func addCheckDown(round int) {
+func addCheckDown(tx *gorm.DB, round int) error {
+	multiplier, err := scoreMultiplier(tx, round)
+	if err != nil {
+		return err
+	}
+	checkDownScore := float64(conf.Game.CheckDownScore) * multiplier
+
 	// Traversal all the challenges.
 	var challenges []dbold.Challenge
-	dbold.MySQL.Model(&dbold.Challenge{}).Find(&challenges)
+	if err := tx.Model(&dbold.Challenge{}).Find(&challenges).Error; err != nil {
+		return err
+	}
 	for _, challenge := range challenges {
 		// Get the check down teams of this challenge.
 		var downActions []dbold.DownAction
-		dbold.MySQL.Model(&dbold.DownAction{}).Where(&dbold.DownAction{ChallengeID: challenge.ID, Round: round}).Find(&downActions)
-		totalScore := len(downActions) * int(conf.Game.CheckDownScore) // Score which every online team can get from this challenge.
+		if err := tx.Model(&dbold.DownAction{}).Where(&dbold.DownAction{ChallengeID: challenge.ID, Round: round}).Find(&downActions).Error; err != nil {
+			return err
+		}
+		totalScore := float64(len(downActions)) * checkDownScore // Score which every online team can get from this challenge.
 
 		// Get the service online teams' Gamebox ID of this challenge.
 		// For the score will be added separately into their **gameboxes**.
@@ -168,18 +285,23 @@ func addCheckDown(round int) {
 
 		// Then, get the service online Gamebox ID. (Process of elimination)
 		var safeGameBoxes []dbold.GameBox
-		dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{ChallengeID: challenge.ID}).Not("id", downGameBoxID).Find(&safeGameBoxes)
-		score := float64(totalScore) / float64(len(safeGameBoxes))
+		if err := tx.Model(&dbold.GameBox{}).Where(&dbold.GameBox{ChallengeID: challenge.ID}).Not("id", downGameBoxID).Find(&safeGameBoxes).Error; err != nil {
+			return err
+		}
+		score := totalScore / float64(len(safeGameBoxes))
 
 		// Well, add score!
 		for _, gamebox := range safeGameBoxes {
-			dbold.MySQL.Create(&dbold.Score{
+			if err := tx.Create(&dbold.Score{
 				TeamID:    gamebox.TeamID,
 				GameBoxID: gamebox.ID,
 				Round:     round,
 				Reason:    "service_online",
 				Score:     score,
-			})
+			}).Error; err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
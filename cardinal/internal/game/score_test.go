@@ -0,0 +1,286 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/conf"
+	"Cardinal/internal/dbold"
+)
+
+// testRound is the round of the fixture, which should not be used by any other data in the test database.
+const testRound = 10001
+
+// newTestTx connects the test database and begins a transaction, which is rolled back after the test.
+// The fixture and the scores are created in the transaction, so the other data in the database is not changed.
+func newTestTx(t *testing.T) *gorm.DB {
+	if err := conf.TestInit(); err != nil {
+		t.Fatalf("Failed to init test config: %v", err)
+	}
+
+	db, err := gorm.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&loc=Local&charset=utf8mb4,utf8",
+		conf.Database.User,
+		conf.Database.Password,
+		conf.Database.Host,
+		conf.Database.Name,
+	))
+	if err != nil {
+		t.Fatalf("Failed to open connection: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	db.AutoMigrate(&dbold.Team{}, &dbold.Challenge{}, &dbold.GameBox{}, &dbold.AttackAction{},
+		&dbold.FirstBlood{}, &dbold.Score{}, &dbold.ScoreAdjustment{}, &dbold.Round{})
+
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+type scoreFixture struct {
+	teams      []*dbold.Team
+	challenges []*dbold.Challenge
+	// gameBoxes is indexed by the team and the challenge, it is nil if the team has no gamebox of the challenge.
+	gameBoxes [][]*dbold.GameBox
+}
+
+// seedScoreFixture creates three teams and two challenges, the third team has no gamebox of the second challenge,
+// and the second team's gamebox of the second challenge is invisible.
+func seedScoreFixture(t *testing.T, tx *gorm.DB) *scoreFixture {
+	suffix := time.Now().UnixNano()
+	f := &scoreFixture{}
+	for i := 0; i < 3; i++ {
+		team := &dbold.Team{Name: fmt.Sprintf("score_test_%d_%d", suffix, i)}
+		assert.Nil(t, tx.Create(team).Error)
+		f.teams = append(f.teams, team)
+	}
+	for i, baseScore := range []int{1000, 500} {
+		challenge := &dbold.Challenge{Title: fmt.Sprintf("score_test_%d_%d", suffix, i), BaseScore: baseScore}
+		assert.Nil(t, tx.Create(challenge).Error)
+		f.challenges = append(f.challenges, challenge)
+	}
+
+	f.gameBoxes = make([][]*dbold.GameBox, len(f.teams))
+	for i, team := range f.teams {
+		f.gameBoxes[i] = make([]*dbold.GameBox, len(f.challenges))
+		for j, challenge := range f.challenges {
+			if i == 2 && j == 1 {
+				continue
+			}
+			gameBox := &dbold.GameBox{TeamID: team.ID, ChallengeID: challenge.ID, Visible: !(i == 1 && j == 1)}
+			assert.Nil(t, tx.Create(gameBox).Error)
+			f.gameBoxes[i][j] = gameBox
+		}
+	}
+
+	attack := func(attacker, victim, challenge int) {
+		gameBox := f.gameBoxes[victim][challenge]
+		assert.Nil(t, tx.Create(&dbold.AttackAction{
+			TeamID:         gameBox.TeamID,
+			GameBoxID:      gameBox.ID,
+			ChallengeID:    gameBox.ChallengeID,
+			AttackerTeamID: f.teams[attacker].ID,
+			Round:          testRound,
+		}).Error)
+	}
+	attack(0, 1, 0)
+	attack(2, 1, 0)
+	attack(1, 0, 0)
+	// The third team has no gamebox of the second challenge.
+	attack(0, 1, 1)
+	attack(2, 1, 1)
+
+	assert.Nil(t, tx.Create(&dbold.FirstBlood{
+		ChallengeID:    f.challenges[0].ID,
+		TeamID:         f.teams[1].ID,
+		GameBoxID:      f.gameBoxes[1][0].ID,
+		AttackerTeamID: f.teams[0].ID,
+		Round:          testRound,
+		Score:          50,
+	}).Error)
+
+	revertedAt := time.Now()
+	for _, adjustment := range []*dbold.ScoreAdjustment{
+		{TeamID: f.teams[0].ID, GameBoxID: f.gameBoxes[0][1].ID, Round: testRound, Score: 7},
+		{TeamID: f.teams[1].ID, Round: testRound, Score: -30},
+		{TeamID: f.teams[2].ID, Round: testRound, Score: 100, RevertedAt: &revertedAt},
+	} {
+		assert.Nil(t, tx.Create(adjustment).Error)
+	}
+	return f
+}
+
+// legacyAddAttack is the previous implementation of addAttack, which adds the attack score gamebox by gamebox.
+func legacyAddAttack(tx *gorm.DB, round int) {
+	var gameBoxes []dbold.GameBox
+	tx.Model(&dbold.GameBox{}).Find(&gameBoxes)
+	for _, gameBox := range gameBoxes {
+		var attackActions []dbold.AttackAction
+		tx.Model(&dbold.AttackAction{}).Where(&dbold.AttackAction{GameBoxID: gameBox.ID, Round: round}).Find(&attackActions)
+		if len(attackActions) != 0 {
+			score := float64(conf.Game.AttackScore) / float64(len(attackActions))
+			for _, action := range attackActions {
+				var attackerGameBox dbold.GameBox
+				tx.Model(&dbold.GameBox{}).Where(&dbold.GameBox{TeamID: action.AttackerTeamID, ChallengeID: gameBox.ChallengeID}).Find(&attackerGameBox)
+
+				tx.Create(&dbold.Score{
+					TeamID:    action.AttackerTeamID,
+					GameBoxID: attackerGameBox.ID,
+					Round:     round,
+					Reason:    "attack",
+					Score:     score,
+				})
+			}
+		}
+	}
+}
+
+// legacyCalculateGameBoxScore is the previous implementation of calculateGameBoxScore, which updates the gameboxes one by one.
+// The first bloods and score adjustments are counted as the later requests added.
+func legacyCalculateGameBoxScore(tx *gorm.DB) {
+	var gameBoxes []dbold.GameBox
+	tx.Model(&dbold.GameBox{}).Find(&gameBoxes)
+	for _, gameBox := range gameBoxes {
+		var sc struct {
+			Score float64 `gorm:"Column:Score"`
+		}
+		tx.Table("scores").Select("SUM(score) AS Score").Where("`game_box_id` = ? AND `deleted_at` IS NULL", gameBox.ID).Scan(&sc)
+
+		var firstBlood struct {
+			Score float64 `gorm:"Column:Score"`
+		}
+		tx.Table("first_bloods").Select("SUM(score) AS Score").
+			Where("`attacker_team_id` = ? AND `challenge_id` = ? AND `deleted_at` IS NULL", gameBox.TeamID, gameBox.ChallengeID).Scan(&firstBlood)
+
+		var adjustment struct {
+			Score float64 `gorm:"Column:Score"`
+		}
+		tx.Table("score_adjustments").Select("SUM(score) AS Score").
+			Where("`game_box_id` = ? AND `reverted_at` IS NULL AND `deleted_at` IS NULL", gameBox.ID).Scan(&adjustment)
+
+		var challenge dbold.Challenge
+		tx.Model(&dbold.Challenge{}).Where(&dbold.Challenge{Model: gorm.Model{ID: gameBox.ChallengeID}}).Find(&challenge)
+		tx.Model(&dbold.GameBox{}).Where("id = ?", gameBox.ID).Update("score", float64(challenge.BaseScore)+sc.Score+firstBlood.Score+adjustment.Score)
+	}
+}
+
+// legacyCalculateTeamScore is the previous implementation of calculateTeamScore, which updates the teams one by one.
+// The score adjustments of the team are counted as the later requests added.
+func legacyCalculateTeamScore(tx *gorm.DB) {
+	var teams []dbold.Team
+	tx.Model(&dbold.Team{}).Find(&teams)
+	for _, t := range teams {
+		var sc struct {
+			Score float64 `gorm:"Column:Score"`
+		}
+		tx.Table("game_boxes").Select("SUM(score) AS Score").Where("`team_id` = ? AND `visible` = ? AND `deleted_at` IS NULL", t.ID, 1).Scan(&sc)
+
+		var adjustment struct {
+			Score float64 `gorm:"Column:Score"`
+		}
+		tx.Table("score_adjustments").Select("SUM(score) AS Score").
+			Where("`team_id` = ? AND `game_box_id` = 0 AND `reverted_at` IS NULL AND `deleted_at` IS NULL", t.ID).Scan(&adjustment)
+
+		tx.Model(&dbold.Team{}).Where("id = ?", t.ID).Update("score", sc.Score+adjustment.Score)
+	}
+}
+
+type attackScore struct {
+	TeamID    uint
+	GameBoxID uint
+	Score     float64
+}
+
+func getAttackScores(t *testing.T, tx *gorm.DB) []attackScore {
+	var scores []attackScore
+	assert.Nil(t, tx.Model(&dbold.Score{}).Select("team_id, game_box_id, score").
+		Where("round = ? AND reason = ?", testRound, "attack").Scan(&scores).Error)
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].TeamID != scores[j].TeamID {
+			return scores[i].TeamID < scores[j].TeamID
+		}
+		return scores[i].GameBoxID < scores[j].GameBoxID
+	})
+	return scores
+}
+
+func Test_addAttack(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	tx := newTestTx(t)
+	f := seedScoreFixture(t, tx)
+
+	assert.Nil(t, addAttack(tx, testRound))
+	got := getAttackScores(t, tx)
+
+	assert.Nil(t, tx.Unscoped().Where("round = ?", testRound).Delete(&dbold.Score{}).Error)
+	legacyAddAttack(tx, testRound)
+	want := getAttackScores(t, tx)
+	assert.Equal(t, want, got)
+
+	// The attacker without the gamebox of the challenge still gets the attack score, which is not counted in any gamebox.
+	assert.Contains(t, got, attackScore{TeamID: f.teams[2].ID, GameBoxID: 0, Score: 5})
+	assert.Contains(t, got, attackScore{TeamID: f.teams[2].ID, GameBoxID: f.gameBoxes[2][0].ID, Score: 5})
+	assert.Contains(t, got, attackScore{TeamID: f.teams[1].ID, GameBoxID: f.gameBoxes[1][0].ID, Score: 10})
+}
+
+func Test_calculateScore(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	tx := newTestTx(t)
+	f := seedScoreFixture(t, tx)
+	assert.Nil(t, addAttack(tx, testRound))
+	assert.Nil(t, minusAttack(tx, testRound))
+
+	getGameBoxScores := func() map[uint]float64 {
+		var gameBoxes []dbold.GameBox
+		assert.Nil(t, tx.Model(&dbold.GameBox{}).Find(&gameBoxes).Error)
+		scores := make(map[uint]float64, len(gameBoxes))
+		for _, gameBox := range gameBoxes {
+			scores[gameBox.ID] = gameBox.Score
+		}
+		return scores
+	}
+	getTeamScores := func() map[uint]float64 {
+		var teams []dbold.Team
+		assert.Nil(t, tx.Model(&dbold.Team{}).Find(&teams).Error)
+		scores := make(map[uint]float64, len(teams))
+		for _, team := range teams {
+			scores[team.ID] = team.Score
+		}
+		return scores
+	}
+
+	assert.Nil(t, calculateGameBoxScore(tx))
+	assert.Nil(t, calculateTeamScore(tx))
+	gotGameBoxes, gotTeams := getGameBoxScores(), getTeamScores()
+
+	legacyCalculateGameBoxScore(tx)
+	legacyCalculateTeamScore(tx)
+	wantGameBoxes, wantTeams := getGameBoxScores(), getTeamScores()
+
+	assert.Equal(t, len(wantGameBoxes), len(gotGameBoxes))
+	for id, want := range wantGameBoxes {
+		assert.InDelta(t, want, gotGameBoxes[id], 1e-6, "gamebox %d", id)
+	}
+	assert.Equal(t, len(wantTeams), len(gotTeams))
+	for id, want := range wantTeams {
+		assert.InDelta(t, want, gotTeams[id], 1e-6, "team %d", id)
+	}
+
+	// The first team: web 1000 + attack 5 + first blood 50 - attacked 10, pwn 500 + attack 5 + adjustment 7.
+	assert.InDelta(t, 1557.0, gotTeams[f.teams[0].ID], 1e-6)
+	// The second team: web 1000 + attack 10 - attacked 10, the invisible pwn is not counted, team adjustment -30.
+	assert.InDelta(t, 970.0, gotTeams[f.teams[1].ID], 1e-6)
+	// The third team: web 1000 + attack 5, the reverted adjustment is not counted.
+	assert.InDelta(t, 1005.0, gotTeams[f.teams[2].ID], 1e-6)
+}
@@ -0,0 +1,332 @@
+package game
+
+import (
+	"strconv"
+	"time"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/dynamic_config"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/rank"
+	"Cardinal/internal/timer"
+	"Cardinal/internal/timeutil"
+	"Cardinal/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// attackStatsOptions is the filter of the attack actions.
+type attackStatsOptions struct {
+	ChallengeID uint
+	StartRound  int
+	EndRound    int
+	// Before only includes the attack actions created before the time if it is not zero.
+	Before time.Time
+}
+
+// AttackMatrix is the count of the successful captures between the teams.
+type AttackMatrix struct {
+	Teams []AttackMatrixTeam
+	// Matrix[i][j] is the count of the flags of Teams[j] captured by Teams[i].
+	Matrix [][]int
+}
+
+type AttackMatrixTeam struct {
+	ID   uint
+	Name string
+}
+
+// ChallengeStats is the exploitation statistics of the challenge.
+type ChallengeStats struct {
+	ChallengeID uint
+	Title       string
+	// FirstCapture is nil if the challenge has never been captured in the rounds.
+	FirstCapture *FirstCapture
+	Rounds       []ChallengeRoundStats
+}
+
+type FirstCapture struct {
+	TeamID    uint
+	VictimID  uint
+	Round     int
+	CreatedAt time.Time
+}
+
+type ChallengeRoundStats struct {
+	Round int
+	// Attackers is the count of the teams which captured at least one flag of the challenge.
+	Attackers int
+	// Victims is the count of the teams whose flag of the challenge was captured.
+	Victims int
+	// Defenders is the count of the teams whose game box of the challenge was not captured.
+	Defenders int
+}
+
+// parseAttackStatsOptions parses the `challenge`, `startRound` and `endRound` queries.
+// The rounds are from the first round to the current round by default, and never after the current round.
+func parseAttackStatsOptions(c *gin.Context) (attackStatsOptions, bool) {
+	var opts attackStatsOptions
+	for key, value := range map[string]*int{"startRound": &opts.StartRound, "endRound": &opts.EndRound} {
+		v, err := strconv.Atoi(c.DefaultQuery(key, "0"))
+		if err != nil || v < 0 {
+			return opts, false
+		}
+		*value = v
+	}
+	challengeID, err := strconv.Atoi(c.DefaultQuery("challenge", "0"))
+	if err != nil || challengeID < 0 {
+		return opts, false
+	}
+	opts.ChallengeID = uint(challengeID)
+
+	if opts.StartRound == 0 {
+		opts.StartRound = 1
+	}
+	nowRound := timer.GetSnapshot().NowRound
+	if opts.EndRound == 0 || opts.EndRound > nowRound {
+		opts.EndRound = nowRound
+	}
+	return opts, true
+}
+
+func (opts attackStatsOptions) query() *gorm.DB {
+	query := dbold.MySQL.Model(&dbold.AttackAction{}).Where("round BETWEEN ? AND ?", opts.StartRound, opts.EndRound)
+	if opts.ChallengeID != 0 {
+		query = query.Where("challenge_id = ?", opts.ChallengeID)
+	}
+	if !opts.Before.IsZero() {
+		query = query.Where("created_at < ?", opts.Before)
+	}
+	return query
+}
+
+// attackMatrix returns the attack matrix of all the teams ordered by the team ID.
+func attackMatrix(opts attackStatsOptions) (*AttackMatrix, error) {
+	var teams []dbold.Team
+	if err := dbold.MySQL.Model(&dbold.Team{}).Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		AttackerTeamID uint
+		TeamID         uint
+		Count          int
+	}
+	if err := opts.query().Select("attacker_team_id, team_id, COUNT(*) AS count").
+		Group("attacker_team_id, team_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[uint]int, len(teams))
+	matrix := &AttackMatrix{
+		Teams:  make([]AttackMatrixTeam, 0, len(teams)),
+		Matrix: make([][]int, len(teams)),
+	}
+	for i, team := range teams {
+		index[team.ID] = i
+		matrix.Teams = append(matrix.Teams, AttackMatrixTeam{ID: team.ID, Name: team.Name})
+		matrix.Matrix[i] = make([]int, len(teams))
+	}
+	for _, count := range counts {
+		attacker, ok := index[count.AttackerTeamID]
+		if !ok {
+			continue
+		}
+		victim, ok := index[count.TeamID]
+		if !ok {
+			continue
+		}
+		matrix.Matrix[attacker][victim] = count.Count
+	}
+	return matrix, nil
+}
+
+// challengeStats returns the statistics of the challenges in the rounds ordered by the challenge ID.
+func challengeStats(opts attackStatsOptions) ([]*ChallengeStats, error) {
+	var challenges []dbold.Challenge
+	query := dbold.MySQL.Model(&dbold.Challenge{}).Order("id")
+	if opts.ChallengeID != 0 {
+		query = query.Where("id = ?", opts.ChallengeID)
+	}
+	if err := query.Find(&challenges).Error; err != nil {
+		return nil, err
+	}
+
+	// The teams which have the visible game box of the challenge are the defenders.
+	var gameBoxCounts []struct {
+		ChallengeID uint
+		Count       int
+	}
+	if err := dbold.MySQL.Model(&dbold.GameBox{}).Select("challenge_id, COUNT(DISTINCT team_id) AS count").
+		Where("visible = ?", true).Group("challenge_id").Scan(&gameBoxCounts).Error; err != nil {
+		return nil, err
+	}
+	teamCount := make(map[uint]int, len(gameBoxCounts))
+	for _, count := range gameBoxCounts {
+		teamCount[count.ChallengeID] = count.Count
+	}
+
+	var roundCounts []struct {
+		ChallengeID uint
+		Round       int
+		Attackers   int
+		Victims     int
+	}
+	if err := opts.query().Select("challenge_id, round, COUNT(DISTINCT attacker_team_id) AS attackers, COUNT(DISTINCT team_id) AS victims").
+		Group("challenge_id, round").Scan(&roundCounts).Error; err != nil {
+		return nil, err
+	}
+	type roundKey struct {
+		challengeID uint
+		round       int
+	}
+	rounds := make(map[roundKey]ChallengeRoundStats, len(roundCounts))
+	for _, count := range roundCounts {
+		rounds[roundKey{count.ChallengeID, count.Round}] = ChallengeRoundStats{
+			Round:     count.Round,
+			Attackers: count.Attackers,
+			Victims:   count.Victims,
+		}
+	}
+
+	var firstCaptureIDs []uint
+	if err := opts.query().Group("challenge_id").Pluck("MIN(id)", &firstCaptureIDs).Error; err != nil {
+		return nil, err
+	}
+	firstCaptures := make(map[uint]*FirstCapture, len(firstCaptureIDs))
+	if len(firstCaptureIDs) != 0 {
+		var actions []dbold.AttackAction
+		if err := dbold.MySQL.Model(&dbold.AttackAction{}).Where("id IN (?)", firstCaptureIDs).Find(&actions).Error; err != nil {
+			return nil, err
+		}
+		for _, action := range actions {
+			firstCaptures[action.ChallengeID] = &FirstCapture{
+				TeamID:    action.AttackerTeamID,
+				VictimID:  action.TeamID,
+				Round:     action.Round,
+				CreatedAt: action.CreatedAt,
+			}
+		}
+	}
+
+	stats := make([]*ChallengeStats, 0, len(challenges))
+	for _, challenge := range challenges {
+		challengeStats := &ChallengeStats{
+			ChallengeID:  challenge.ID,
+			Title:        challenge.Title,
+			FirstCapture: firstCaptures[challenge.ID],
+			Rounds:       make([]ChallengeRoundStats, 0),
+		}
+		for round := opts.StartRound; round <= opts.EndRound; round++ {
+			roundStats, ok := rounds[roundKey{challenge.ID, round}]
+			if !ok {
+				roundStats = ChallengeRoundStats{Round: round}
+			}
+			roundStats.Defenders = teamCount[challenge.ID] - roundStats.Victims
+			if roundStats.Defenders < 0 {
+				roundStats.Defenders = 0
+			}
+			challengeStats.Rounds = append(challengeStats.Rounds, roundStats)
+		}
+		stats = append(stats, challengeStats)
+	}
+	return stats, nil
+}
+
+// publicAttackStatsOptions parses the options of the public statistics.
+// It returns false if the statistics are not public, the captures after the ranking list is frozen or
+// in the delayed rounds are hidden, the same as the ranking list.
+func publicAttackStatsOptions(c *gin.Context) (attackStatsOptions, int, interface{}, bool) {
+	if public, _ := strconv.ParseBool(dynamic_config.Get(utils.PUBLIC_ATTACK_STATS)); !public {
+		code, data := utils.MakeErrJSON(403, 40314,
+			locales.I18n.T(c.GetString("lang"), "general.not_public"),
+		)
+		return attackStatsOptions{}, code, data, false
+	}
+
+	opts, ok := parseAttackStatsOptions(c)
+	if !ok {
+		code, data := utils.MakeErrJSON(400, 40069,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+		return opts, code, data, false
+	}
+
+	now := timeutil.Now()
+	if rank.IsFrozen(now) {
+		opts.Before = rank.FreezeAt()
+	}
+	if maxRound := timer.GetSnapshot().NowRound - int(rank.DelayRound(now)); opts.EndRound > maxRound {
+		opts.EndRound = maxRound
+	}
+	return opts, 0, nil, true
+}
+
+// GetAttackMatrix returns the matrix of the successful captures between the teams for manager.
+func GetAttackMatrix(c *gin.Context) (int, interface{}) {
+	opts, ok := parseAttackStatsOptions(c)
+	if !ok {
+		return utils.MakeErrJSON(400, 40069,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+
+	matrix, err := attackMatrix(opts)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50035,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	return utils.MakeSuccessJSON(matrix)
+}
+
+// GetChallengeStats returns the exploitation statistics of the challenges for manager.
+func GetChallengeStats(c *gin.Context) (int, interface{}) {
+	opts, ok := parseAttackStatsOptions(c)
+	if !ok {
+		return utils.MakeErrJSON(400, 40069,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+
+	stats, err := challengeStats(opts)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50035,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	return utils.MakeSuccessJSON(stats)
+}
+
+// GetPublicAttackMatrix returns the attack matrix for the public if it is enabled.
+func GetPublicAttackMatrix(c *gin.Context) (int, interface{}) {
+	opts, code, data, ok := publicAttackStatsOptions(c)
+	if !ok {
+		return code, data
+	}
+
+	matrix, err := attackMatrix(opts)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50035,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	return utils.MakeSuccessJSON(matrix)
+}
+
+// GetPublicChallengeStats returns the challenge statistics for the public if it is enabled.
+func GetPublicChallengeStats(c *gin.Context) (int, interface{}) {
+	opts, code, data, ok := publicAttackStatsOptions(c)
+	if !ok {
+		return code, data
+	}
+
+	stats, err := challengeStats(opts)
+	if err != nil {
+		return utils.MakeErrJSON(500, 50035,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	return utils.MakeSuccessJSON(stats)
+}
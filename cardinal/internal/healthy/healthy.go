@@ -40,7 +40,7 @@ func HealthyCheck() {
 func PreviousRoundScore() float64 {
 	var score []float64
 	// Pay attention if there is no action in the previous round, the SUM(`score`) will be NULL.
-	dbold.MySQL.Model(&dbold.Score{}).Where(&dbold.Score{Round: timer.Get().NowRound}).Pluck("IFNULL(SUM(`score`), 0)", &score)
+	dbold.MySQL.Model(&dbold.Score{}).Where(&dbold.Score{Round: timer.GetSnapshot().NowRound}).Pluck("IFNULL(SUM(`score`), 0)", &score)
 	value, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", score[0]), 64)
 	return value
 }
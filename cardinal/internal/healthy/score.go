@@ -0,0 +1,266 @@
+package healthy
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"Cardinal/internal/conf"
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/misc/webhook"
+	"Cardinal/internal/store"
+	"Cardinal/internal/timeutil"
+)
+
+const cacheKeyScoreReport = "scoreReport"
+
+const (
+	// ScoreViolationRoundSum means the sum of the round's scores is not the expected value.
+	ScoreViolationRoundSum = "round_sum"
+	// ScoreViolationGameBoxScore means the gamebox score is not equal to the score counted from the actions.
+	ScoreViolationGameBoxScore = "game_box_score"
+	// ScoreViolationTeamScore means the team score is not equal to the score counted from the actions.
+	ScoreViolationTeamScore = "team_score"
+)
+
+// ScoreViolation represents a single inconsistency found in the scores.
+type ScoreViolation struct {
+	Kind      string
+	Round     int  `json:",omitempty"`
+	TeamID    uint `json:",omitempty"`
+	GameBoxID uint `json:",omitempty"`
+	Expected  float64
+	Actual    float64
+	Message   string
+}
+
+// ScoreReport is the score consistency report of a round.
+type ScoreReport struct {
+	Round      int
+	CheckedAt  time.Time
+	Violations []*ScoreViolation
+}
+
+// scoreEpsilon is the tolerance when comparing the float scores,
+// for the attack and service online scores are divided.
+const scoreEpsilon = 1e-6
+
+func scoreEqual(a, b float64) bool {
+	return math.Abs(a-b) < scoreEpsilon
+}
+
+// CheckScore checks the score consistency after the given round calculated.
+// The expected scores are counted from the attack and check down actions of the rounds until the given one,
+// rather than the score records, so the rounds which are calculated wrongly, twice or never can be found.
+// The report will be saved into the cache, and sent through the webhook if there are any violations.
+func CheckScore(round int) (*ScoreReport, error) {
+	violations, err := checkScore(round)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScoreReport{
+		Round:      round,
+		CheckedAt:  timeutil.Now(),
+		Violations: violations,
+	}
+	store.Set(cacheKeyScoreReport, report)
+
+	if len(violations) != 0 {
+		webhook.Add(webhook.SCORE_CHECK_HOOK, report)
+	}
+	return report, nil
+}
+
+// LatestScoreReport returns the latest score consistency report from the cache.
+// It returns nil if no round has been checked.
+func LatestScoreReport() *ScoreReport {
+	report, ok := store.Get(cacheKeyScoreReport)
+	if !ok {
+		return nil
+	}
+	return report.(*ScoreReport)
+}
+
+func checkScore(round int) ([]*ScoreViolation, error) {
+	var challenges []dbold.Challenge
+	if err := dbold.MySQL.Model(&dbold.Challenge{}).Find(&challenges).Error; err != nil {
+		return nil, err
+	}
+	var gameBoxes []dbold.GameBox
+	if err := dbold.MySQL.Model(&dbold.GameBox{}).Order("id").Find(&gameBoxes).Error; err != nil {
+		return nil, err
+	}
+	var teams []dbold.Team
+	if err := dbold.MySQL.Model(&dbold.Team{}).Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	var attackActions []dbold.AttackAction
+	if err := dbold.MySQL.Model(&dbold.AttackAction{}).Where("round <= ?", round).Find(&attackActions).Error; err != nil {
+		return nil, err
+	}
+	var downActions []dbold.DownAction
+	if err := dbold.MySQL.Model(&dbold.DownAction{}).Where("round <= ?", round).Find(&downActions).Error; err != nil {
+		return nil, err
+	}
+	var firstBloods []dbold.FirstBlood
+	if err := dbold.MySQL.Model(&dbold.FirstBlood{}).Find(&firstBloods).Error; err != nil {
+		return nil, err
+	}
+	var scoreAdjustments []dbold.ScoreAdjustment
+	if err := dbold.MySQL.Model(&dbold.ScoreAdjustment{}).Where("reverted_at IS NULL").Find(&scoreAdjustments).Error; err != nil {
+		return nil, err
+	}
+	var rounds []dbold.Round
+	if err := dbold.MySQL.Model(&dbold.Round{}).Where("round <= ?", round).Find(&rounds).Error; err != nil {
+		return nil, err
+	}
+
+	// The attack and check down score of the round are multiplied by the score multiplier of the round's phase.
+	scoreMultipliers := make(map[int]float64, len(rounds))
+	for _, r := range rounds {
+		if r.ScoreMultiplier != 0 {
+			scoreMultipliers[r.Round] = r.ScoreMultiplier
+		}
+	}
+	scoreMultiplier := func(round int) float64 {
+		if multiplier, ok := scoreMultipliers[round]; ok {
+			return multiplier
+		}
+		return 1
+	}
+
+	type teamChallenge struct {
+		teamID      uint
+		challengeID uint
+	}
+	challengeGameBoxes := make(map[uint][]uint)
+	teamChallengeGameBoxes := make(map[teamChallenge]uint)
+	for _, gameBox := range gameBoxes {
+		challengeGameBoxes[gameBox.ChallengeID] = append(challengeGameBoxes[gameBox.ChallengeID], gameBox.ID)
+		teamChallengeGameBoxes[teamChallenge{gameBox.TeamID, gameBox.ChallengeID}] = gameBox.ID
+	}
+
+	// The score of the gamebox starts from the challenge's base score.
+	expected := make(map[uint]float64, len(gameBoxes))
+	baseScores := make(map[uint]float64, len(challenges))
+	for _, challenge := range challenges {
+		baseScores[challenge.ID] = float64(challenge.BaseScore)
+	}
+	for _, gameBox := range gameBoxes {
+		expected[gameBox.ID] = baseScores[gameBox.ChallengeID]
+	}
+
+	// The attacked gamebox loses the attack score once in a round, which is shared by its attackers equally.
+	type roundGameBox struct {
+		round     int
+		gameBoxID uint
+	}
+	attackers := make(map[roundGameBox][]dbold.AttackAction)
+	for _, action := range attackActions {
+		key := roundGameBox{action.Round, action.GameBoxID}
+		attackers[key] = append(attackers[key], action)
+	}
+	for key, actions := range attackers {
+		attackScore := float64(conf.Game.AttackScore) * scoreMultiplier(key.round)
+		expected[key.gameBoxID] -= attackScore
+		for _, action := range actions {
+			if gameBoxID, ok := teamChallengeGameBoxes[teamChallenge{action.AttackerTeamID, action.ChallengeID}]; ok {
+				expected[gameBoxID] += attackScore / float64(len(actions))
+			}
+		}
+	}
+
+	// The down gamebox loses the check down score, which is shared by the other gameboxes of the challenge equally.
+	type roundChallenge struct {
+		round       int
+		challengeID uint
+	}
+	downGameBoxes := make(map[roundChallenge]map[uint]bool)
+	for _, action := range downActions {
+		key := roundChallenge{action.Round, action.ChallengeID}
+		if downGameBoxes[key] == nil {
+			downGameBoxes[key] = make(map[uint]bool)
+		}
+		downGameBoxes[key][action.GameBoxID] = true
+	}
+	// The check down score is lost if all the gameboxes of the challenge are down, so the round's scores are not zero-sum.
+	roundLostScores := make(map[int]float64)
+	for key, downs := range downGameBoxes {
+		checkDownScore := float64(conf.Game.CheckDownScore) * scoreMultiplier(key.round)
+		for gameBoxID := range downs {
+			expected[gameBoxID] -= checkDownScore
+		}
+		safeCount := len(challengeGameBoxes[key.challengeID]) - len(downs)
+		if safeCount <= 0 {
+			roundLostScores[key.round] -= checkDownScore * float64(len(downs))
+			continue
+		}
+		for _, gameBoxID := range challengeGameBoxes[key.challengeID] {
+			if !downs[gameBoxID] {
+				expected[gameBoxID] += checkDownScore * float64(len(downs)) / float64(safeCount)
+			}
+		}
+	}
+
+	// The first blood bonus belongs to the attacker's gamebox of the challenge.
+	for _, firstBlood := range firstBloods {
+		if gameBoxID, ok := teamChallengeGameBoxes[teamChallenge{firstBlood.AttackerTeamID, firstBlood.ChallengeID}]; ok {
+			expected[gameBoxID] += firstBlood.Score
+		}
+	}
+
+	teamExpected := make(map[uint]float64, len(teams))
+	for _, adjustment := range scoreAdjustments {
+		if adjustment.GameBoxID != 0 {
+			expected[adjustment.GameBoxID] += adjustment.Score
+		} else {
+			teamExpected[adjustment.TeamID] += adjustment.Score
+		}
+	}
+
+	violations := make([]*ScoreViolation, 0)
+
+	var roundScore []float64
+	if err := dbold.MySQL.Model(&dbold.Score{}).Where(&dbold.Score{Round: round}).Pluck("IFNULL(SUM(`score`), 0)", &roundScore).Error; err != nil {
+		return nil, err
+	}
+	if len(roundScore) != 0 && !scoreEqual(roundScore[0], roundLostScores[round]) {
+		violations = append(violations, &ScoreViolation{
+			Kind:     ScoreViolationRoundSum,
+			Round:    round,
+			Expected: roundLostScores[round],
+			Actual:   roundScore[0],
+			Message:  fmt.Sprintf("the sum of round %d scores is %.2f, expected %.2f", round, roundScore[0], roundLostScores[round]),
+		})
+	}
+
+	for _, gameBox := range gameBoxes {
+		if gameBox.Visible {
+			teamExpected[gameBox.TeamID] += expected[gameBox.ID]
+		}
+		if !scoreEqual(gameBox.Score, expected[gameBox.ID]) {
+			violations = append(violations, &ScoreViolation{
+				Kind:      ScoreViolationGameBoxScore,
+				TeamID:    gameBox.TeamID,
+				GameBoxID: gameBox.ID,
+				Expected:  expected[gameBox.ID],
+				Actual:    gameBox.Score,
+				Message:   fmt.Sprintf("gamebox %d score is %.2f, expected %.2f", gameBox.ID, gameBox.Score, expected[gameBox.ID]),
+			})
+		}
+	}
+
+	for _, team := range teams {
+		if !scoreEqual(team.Score, teamExpected[team.ID]) {
+			violations = append(violations, &ScoreViolation{
+				Kind:     ScoreViolationTeamScore,
+				TeamID:   team.ID,
+				Expected: teamExpected[team.ID],
+				Actual:   team.Score,
+				Message:  fmt.Sprintf("team %d score is %.2f, expected %.2f", team.ID, team.Score, teamExpected[team.ID]),
+			})
+		}
+	}
+	return violations, nil
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +24,41 @@ func Init() {
 	_ = Stream.Create(GlobalStream)
 }
 
+// lastEventID returns the ID of the last line received by the client when it reconnects.
+// The browsers send it in the `Last-Event-ID` header, the `lastEventId` query is used by the clients which can not set the header.
+func lastEventID(c *gin.Context) uint {
+	id := c.GetHeader("Last-Event-ID")
+	if id == "" {
+		id = c.Query("lastEventId")
+	}
+	lastID, _ := strconv.ParseUint(id, 10, 64)
+	return uint(lastID)
+}
+
+// typeFilter returns the line types given in the `type` query separated by commas, it returns nil if there is no filter.
+func typeFilter(c *gin.Context) map[string]bool {
+	if c.Query("type") == "" {
+		return nil
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		types[strings.TrimSpace(t)] = true
+	}
+	return types
+}
+
+// GlobalStreamHandler sends the lines of the global stream as the Server-Sent Events.
 func GlobalStreamHandler(c *gin.Context) {
+	serveStream(c, GlobalStream)
+}
+
+// serveStream sends the lines of the stream as the Server-Sent Events, the line ID is the event ID.
+// The client resumes from the last received line when it reconnects.
+func serveStream(c *gin.Context, streamID int64) {
+	lastID := lastEventID(c)
+	types := typeFilter(c)
+
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -38,7 +74,7 @@ func GlobalStreamHandler(c *gin.Context) {
 
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
-	events, errC := Stream.Tail(ctx, GlobalStream)
+	events, errC := Stream.Tail(ctx, streamID, lastID)
 	_, _ = io.WriteString(c.Writer, "events: stream opened\n\n")
 	f.Flush()
 
@@ -61,6 +97,13 @@ L:
 			_, _ = io.WriteString(c.Writer, ": ping\n\n")
 			f.Flush()
 		case event := <-events:
+			if types != nil && !types[event.Type] {
+				continue
+			}
+
+			if event.ID != 0 {
+				_, _ = io.WriteString(c.Writer, "id: "+strconv.FormatUint(uint64(event.ID), 10)+"\n")
+			}
 			_, _ = io.WriteString(c.Writer, "data: ")
 			evt, _ := json.Marshal(event)
 			_, _ = c.Writer.Write(evt)
@@ -24,11 +24,20 @@ func newStreamer() *Streamer {
 // Create adds a new log stream.
 func (s *Streamer) Create(id int64) error {
 	s.Lock()
-	s.streams[id] = newStream()
+	s.streams[id] = newStream(id)
 	s.Unlock()
 	return nil
 }
 
+// CreateIfNotExists adds a new log stream if the stream does not exist.
+func (s *Streamer) CreateIfNotExists(id int64) {
+	s.Lock()
+	if _, ok := s.streams[id]; !ok {
+		s.streams[id] = newStream(id)
+	}
+	s.Unlock()
+}
+
 // Delete removes a log by id.
 func (s *Streamer) Delete(id int64) error {
 	s.Lock()
@@ -54,15 +63,15 @@ func (s *Streamer) Write(id int64, line *Line) error {
 	return stream.write(line)
 }
 
-// Tail returns the end signal.
-func (s *Streamer) Tail(ctx context.Context, id int64) (<-chan *Line, <-chan error) {
+// Tail returns the lines after the line with the given ID and the end signal.
+func (s *Streamer) Tail(ctx context.Context, id int64, lastID uint) (<-chan *Line, <-chan error) {
 	s.Lock()
 	stream, ok := s.streams[id]
 	s.Unlock()
 	if !ok {
 		return nil, nil
 	}
-	return stream.subscribe(ctx)
+	return stream.subscribe(ctx, lastID)
 }
 
 // Info returns the count of subscribers in each stream.
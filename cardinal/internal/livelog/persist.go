@@ -0,0 +1,54 @@
+package livelog
+
+import (
+	"encoding/json"
+
+	"Cardinal/internal/dbold"
+
+	"github.com/pkg/errors"
+)
+
+// save stores the line of the stream, and sets the ID of the line.
+func save(streamID int64, line *Line) error {
+	message, err := json.Marshal(line.Message)
+	if err != nil {
+		return errors.Wrap(err, "marshal message")
+	}
+
+	record := dbold.LiveLog{
+		Stream:    streamID,
+		Type:      line.Type,
+		Message:   string(message),
+		Timestamp: line.Timestamp,
+	}
+	if err := dbold.MySQL.Create(&record).Error; err != nil {
+		return errors.Wrap(err, "create live log")
+	}
+	line.ID = record.ID
+	return nil
+}
+
+// load returns at most `limit` latest lines of the stream whose ID is between `afterID` and `beforeID`,
+// the lines are in the order of the ID. `beforeID` is ignored if it is zero.
+func load(streamID int64, afterID, beforeID uint, limit int) ([]*Line, error) {
+	query := dbold.MySQL.Model(&dbold.LiveLog{}).Where("stream = ? AND id > ?", streamID, afterID)
+	if beforeID != 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var records []dbold.LiveLog
+	if err := query.Order("id DESC").Limit(limit).Find(&records).Error; err != nil {
+		return nil, errors.Wrap(err, "find live logs")
+	}
+
+	lines := make([]*Line, len(records))
+	for i, record := range records {
+		lines[len(records)-1-i] = &Line{
+			ID:        record.ID,
+			Type:      record.Type,
+			Message:   json.RawMessage(record.Message),
+			Timestamp: record.Timestamp,
+		}
+	}
+	return lines, nil
+}
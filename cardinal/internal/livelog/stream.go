@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	log "unknwon.dev/clog/v2"
 )
 
 // The max size that the content can be.
@@ -11,6 +13,8 @@ const bufferSize = 5000
 
 // Line is a single line of the log.
 type Line struct {
+	// ID increases monotonically in all the streams, it is zero if the line failed to be saved.
+	ID        uint        `json:"ID"`
 	Type      string      `json:"Type"`
 	Message   interface{} `json:"Message"`
 	Timestamp int64       `json:"Time"`
@@ -28,30 +32,47 @@ func NewLine(messageType string, message interface{}) *Line {
 type stream struct {
 	sync.Mutex
 
+	id      int64
 	content []*Line
 	sub     map[*subscriber]struct{}
 }
 
-func newStream() *stream {
+// newStream returns the stream with the latest lines saved in the database, so the history is kept after restarting.
+func newStream(id int64) *stream {
+	content, err := load(id, 0, 0, bufferSize)
+	if err != nil {
+		log.Error("Failed to load the history of live log stream %d: %v", id, err)
+	}
+
 	return &stream{
-		sub: map[*subscriber]struct{}{},
+		id:      id,
+		content: content,
+		sub:     map[*subscriber]struct{}{},
 	}
 }
 
 func (s *stream) write(line *Line) error {
 	s.Lock()
 	defer s.Unlock()
-	for su := range s.sub {
-		su.send(line)
-	}
 
+	// The line is saved in the lock, so the lines are sent in the order of the ID.
+	err := save(s.id, line)
+
+	s.content = append(s.content, line)
 	if size := len(s.content); size >= bufferSize {
 		s.content = s.content[size-bufferSize:]
 	}
-	return nil
+
+	for su := range s.sub {
+		su.send(line)
+	}
+	return err
 }
 
-func (s *stream) subscribe(ctx context.Context) (<-chan *Line, <-chan error) {
+// subscribe returns the lines after the line with the given ID and the new lines.
+// All the lines in the buffer are sent if the ID is zero. The history lines which are not in the buffer
+// are loaded from the database, at most `bufferSize` lines are sent.
+func (s *stream) subscribe(ctx context.Context, lastID uint) (<-chan *Line, <-chan error) {
 	sub := &subscriber{
 		handler:      make(chan *Line, bufferSize),
 		closeChannel: make(chan struct{}),
@@ -60,7 +81,30 @@ func (s *stream) subscribe(ctx context.Context) (<-chan *Line, <-chan error) {
 
 	s.Lock()
 	// Send history data.
-	for _, line := range s.content {
+	history := s.content
+	if lastID != 0 {
+		start := len(s.content)
+		for i, line := range s.content {
+			if line.ID > lastID {
+				start = i
+				break
+			}
+		}
+		history = s.content[start:]
+
+		if start == 0 && len(history) < bufferSize {
+			var firstID uint
+			if len(history) != 0 {
+				firstID = history[0].ID
+			}
+			missing, loadErr := load(s.id, lastID, firstID, bufferSize-len(history))
+			if loadErr != nil {
+				log.Error("Failed to load the history of live log stream %d: %v", s.id, loadErr)
+			}
+			history = append(missing, history...)
+		}
+	}
+	for _, line := range history {
 		sub.send(line)
 	}
 	s.sub[sub] = struct{}{}
@@ -73,6 +117,10 @@ func (s *stream) subscribe(ctx context.Context) (<-chan *Line, <-chan error) {
 		case <-ctx.Done():
 			sub.close()
 		}
+
+		s.Lock()
+		delete(s.sub, sub)
+		s.Unlock()
 	}()
 	return sub.handler, err
 }
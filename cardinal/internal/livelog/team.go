@@ -0,0 +1,49 @@
+package livelog
+
+import (
+	"Cardinal/internal/dbold"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TeamStream returns the ID of the private stream of the team, it never conflicts with the global stream.
+func TeamStream(teamID uint) int64 {
+	return int64(teamID)
+}
+
+// WriteTeam adds a new line into the private stream of the team, the stream is created if it does not exist.
+func WriteTeam(teamID uint, line *Line) error {
+	if Stream == nil {
+		return nil
+	}
+
+	Stream.CreateIfNotExists(TeamStream(teamID))
+	return Stream.Write(TeamStream(teamID), line)
+}
+
+// WriteAllTeams adds a copy of the line into the private streams of all the teams.
+func WriteAllTeams(line *Line) error {
+	if Stream == nil {
+		return nil
+	}
+
+	var teams []dbold.Team
+	if err := dbold.MySQL.Model(&dbold.Team{}).Find(&teams).Error; err != nil {
+		return err
+	}
+
+	for _, team := range teams {
+		teamLine := *line
+		if err := WriteTeam(team.ID, &teamLine); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TeamStreamHandler sends the lines of the private stream of the team as the Server-Sent Events.
+func TeamStreamHandler(c *gin.Context) {
+	teamID := c.GetUint("teamID")
+	Stream.CreateIfNotExists(TeamStream(teamID))
+	serveStream(c, TeamStream(teamID))
+}
@@ -0,0 +1,243 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/dynamic_config"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	FORMAT_NATIVE   string = "native"
+	FORMAT_SLACK    string = "slack"
+	FORMAT_DISCORD  string = "discord"
+	FORMAT_MATRIX   string = "matrix"
+	FORMAT_TEMPLATE string = "template"
+)
+
+// checkFormat checks the format and the template of the webhook.
+func checkFormat(format, tmpl string) (ok bool, templateErr error) {
+	switch format {
+	case "", FORMAT_NATIVE, FORMAT_SLACK, FORMAT_DISCORD, FORMAT_MATRIX:
+		return true, nil
+	case FORMAT_TEMPLATE:
+		_, err := template.New("webhook").Parse(tmpl)
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+// event is a game event which is sent to the webhooks, the readable message is only built when it is needed.
+type event struct {
+	Type string
+	Data interface{}
+	Test bool
+
+	message     *string
+	genericOnce sync.Once
+	generic     interface{}
+}
+
+// TemplateData is the data used to execute the template of the webhook with the `template` format.
+type TemplateData struct {
+	// Title is the game title.
+	Title string
+	Type  string
+	// Data is the event data in the native format, the numbers in it are float64.
+	Data interface{}
+	// Message is the readable message of the event with the team and challenge names.
+	Message string
+	// Test is true if the event is sent by the manager to test the webhook.
+	Test bool
+	// JSON is the body of the native format.
+	JSON string
+}
+
+// render returns the delivery body of the event in the format of the webhook.
+func (e *event) render(webHook dbold.WebHook) ([]byte, error) {
+	native, err := json.Marshal(payload{
+		Type: e.Type,
+		Data: e.Data,
+		Test: e.Test,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal native payload")
+	}
+
+	switch webHook.Format {
+	case FORMAT_SLACK:
+		return json.Marshal(gin.H{"text": e.Message()})
+	case FORMAT_DISCORD:
+		return json.Marshal(gin.H{"content": e.Message()})
+	case FORMAT_MATRIX:
+		return json.Marshal(gin.H{"msgtype": "m.text", "body": e.Message()})
+	case FORMAT_TEMPLATE:
+		tmpl, err := template.New("webhook").Parse(webHook.Template)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse template")
+		}
+
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, TemplateData{
+			Title:   dynamic_config.Get(utils.TITLE_CONF),
+			Type:    e.Type,
+			Data:    e.genericData(),
+			Message: e.Message(),
+			Test:    e.Test,
+			JSON:    string(native),
+		}); err != nil {
+			return nil, errors.Wrap(err, "execute template")
+		}
+		return body.Bytes(), nil
+	default:
+		return native, nil
+	}
+}
+
+// Message returns the readable message of the event, the IDs in the data are replaced with the names.
+func (e *event) Message() string {
+	if e.message != nil {
+		return *e.message
+	}
+
+	data := e.genericData()
+	fields, _ := data.(map[string]interface{})
+
+	var message string
+	switch e.Type {
+	case BEGIN_HOOK:
+		message = locales.T("webhook.message.game_begin")
+	case NEW_ROUND_HOOK:
+		message = locales.T("webhook.message.new_round", gin.H{"round": data})
+	case PAUSE_HOOK:
+		message = locales.T("webhook.message.game_pause")
+	case END_HOOK:
+		message = locales.T("webhook.message.game_end")
+	case SUBMIT_FLAG_HOOK:
+		message = locales.T("webhook.message.submit_flag", gin.H{
+			"from":      teamName(fields["from"]),
+			"to":        teamName(fields["to"]),
+			"challenge": gameBoxTitle(fields["gamebox"]),
+		})
+	case CHECK_DOWN_HOOK:
+		message = locales.T("webhook.message.check_down", gin.H{
+			"team":      teamName(fields["team"]),
+			"challenge": gameBoxTitle(fields["gamebox"]),
+		})
+	case FIRST_BLOOD_HOOK:
+		message = locales.T("webhook.message.first_blood", gin.H{
+			"from":      teamName(fields["from"]),
+			"to":        teamName(fields["to"]),
+			"challenge": challengeTitle(fields["challenge"]),
+			"score":     fields["score"],
+		})
+	case SCORE_CHECK_HOOK:
+		violations, _ := fields["Violations"].([]interface{})
+		message = locales.T("webhook.message.score_check", gin.H{
+			"round": fields["Round"],
+			"count": len(violations),
+		})
+	case TEAM_LOGIN_HOOK:
+		message = locales.T("webhook.message.team_login", gin.H{
+			"team": teamName(fields["team"]),
+		})
+	case BULLETIN_HOOK:
+		message = locales.T("webhook.message.bulletin_published", gin.H{
+			"title": fields["title"],
+		})
+	case FLAG_PLANT_FAILED_HOOK:
+		message = locales.T("webhook.message.flag_plant_failed", gin.H{
+			"team":      teamName(fields["team"]),
+			"challenge": gameBoxTitle(fields["gamebox"]),
+			"round":     fields["round"],
+		})
+	case SCORE_RECOMPUTED_HOOK:
+		message = locales.T("webhook.message.score_recomputed", gin.H{
+			"round": fields["round"],
+		})
+	case CONFIG_CHANGED_HOOK:
+		keys, _ := fields["keys"].([]interface{})
+		keyStrs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			keyStrs = append(keyStrs, fmt.Sprint(key))
+		}
+		message = locales.T("webhook.message.config_changed", gin.H{
+			"keys": strings.Join(keyStrs, ", "),
+		})
+	default:
+		message = e.Type
+	}
+
+	if title := dynamic_config.Get(utils.TITLE_CONF); title != "" {
+		message = "[" + title + "] " + message
+	}
+	if e.Test {
+		message = locales.T("webhook.message.test") + " " + message
+	}
+	e.message = &message
+	return message
+}
+
+// genericData returns the event data in the same structure as the native format.
+func (e *event) genericData() interface{} {
+	e.genericOnce.Do(func() {
+		if raw, err := json.Marshal(e.Data); err == nil {
+			_ = json.Unmarshal(raw, &e.generic)
+		}
+	})
+	return e.generic
+}
+
+// dataID returns the ID in the event data, the numbers are float64 after JSON decoding.
+func dataID(v interface{}) uint {
+	if f, ok := v.(float64); ok && f > 0 {
+		return uint(f)
+	}
+	return 0
+}
+
+func teamName(v interface{}) string {
+	var team dbold.Team
+	if teamID := dataID(v); teamID != 0 {
+		dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: teamID}}).Find(&team)
+	}
+	if team.ID == 0 {
+		return fmt.Sprintf("#%d", dataID(v))
+	}
+	return team.Name
+}
+
+func challengeTitle(v interface{}) string {
+	var challenge dbold.Challenge
+	if challengeID := dataID(v); challengeID != 0 {
+		dbold.MySQL.Model(&dbold.Challenge{}).Where(&dbold.Challenge{Model: gorm.Model{ID: challengeID}}).Find(&challenge)
+	}
+	if challenge.ID == 0 {
+		return fmt.Sprintf("#%d", dataID(v))
+	}
+	return challenge.Title
+}
+
+// gameBoxTitle returns the challenge title of the game box.
+func gameBoxTitle(v interface{}) string {
+	var gameBox dbold.GameBox
+	if gameBoxID := dataID(v); gameBoxID != 0 {
+		dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: gameBoxID}}).Find(&gameBox)
+	}
+	if gameBox.ID == 0 {
+		return fmt.Sprintf("#%d", dataID(v))
+	}
+	return challengeTitle(float64(gameBox.ChallengeID))
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/pkg/webhooksign"
+)
+
+func Test_render(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	newTestOutbox(t)
+
+	e := &event{Type: NEW_ROUND_HOOK, Data: 2}
+	message := e.Message()
+	assert.Contains(t, message, "2")
+
+	toJSON := func(v interface{}) string {
+		body, _ := json.Marshal(v)
+		return string(body)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		webHook  dbold.WebHook
+		wantBody string
+	}{
+		{
+			name:     "native",
+			webHook:  dbold.WebHook{},
+			wantBody: `{"type":"new_round","data":2}`,
+		},
+		{
+			name:     "slack",
+			webHook:  dbold.WebHook{Format: FORMAT_SLACK},
+			wantBody: toJSON(gin.H{"text": message}),
+		},
+		{
+			name:     "discord",
+			webHook:  dbold.WebHook{Format: FORMAT_DISCORD},
+			wantBody: toJSON(gin.H{"content": message}),
+		},
+		{
+			name:     "matrix",
+			webHook:  dbold.WebHook{Format: FORMAT_MATRIX},
+			wantBody: toJSON(gin.H{"msgtype": "m.text", "body": message}),
+		},
+		{
+			name:     "template",
+			webHook:  dbold.WebHook{Format: FORMAT_TEMPLATE, Template: `{{.Type}} {{.Data}}: {{.Message}}`},
+			wantBody: "new_round 2: " + message,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := e.render(tc.webHook)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantBody, string(body))
+		})
+	}
+}
+
+func Test_newDeliveryRequest(t *testing.T) {
+	body := []byte(`{"msgtype":"m.text","body":"Round 2 has started"}`)
+
+	// The Matrix message is sent with the delivery ID as the transaction ID.
+	req, err := newDeliveryRequest(dbold.WebHook{
+		URL:    "https://matrix.example.com/_matrix/client/v3/rooms/!room:example.com/send/m.room.message?access_token=token",
+		Token:  "token",
+		Format: FORMAT_MATRIX,
+	}, "f1d2d2f924e986ac86fdf7b36c94bcdf", body)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPut, req.Method)
+	assert.Equal(t, "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/f1d2d2f924e986ac86fdf7b36c94bcdf", req.URL.Path)
+	assert.Equal(t, "token", req.URL.Query().Get("access_token"))
+
+	// The other formats are posted to the URL.
+	req, err = newDeliveryRequest(dbold.WebHook{
+		URL:    "https://discord.com/api/webhooks/1/token",
+		Token:  "token",
+		Format: FORMAT_DISCORD,
+	}, "f1d2d2f924e986ac86fdf7b36c94bcdf", body)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "https://discord.com/api/webhooks/1/token", req.URL.String())
+
+	// The delivery is signed with the webhook token.
+	sentBody, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, body, sentBody)
+	assert.Equal(t, "f1d2d2f924e986ac86fdf7b36c94bcdf", req.Header.Get(webhooksign.HeaderDelivery))
+	assert.Nil(t, webhooksign.NewVerifier("token", 0).Verify(req.Header, sentBody))
+}
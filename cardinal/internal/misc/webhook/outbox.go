@@ -0,0 +1,336 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/logger"
+	"Cardinal/internal/store"
+	"Cardinal/internal/utils"
+	"Cardinal/pkg/webhooksign"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+const (
+	DeliveryPending   string = "pending"
+	DeliveryDelivered string = "delivered"
+	DeliveryDead      string = "dead"
+)
+
+const (
+	// defaultBackoff is the interval before the first retry if the webhook timeout is not set.
+	defaultBackoff = 5 * time.Second
+	// maxBackoff is the maximum interval between two attempts.
+	maxBackoff = time.Hour
+	// responseSnippetSize is the maximum size of the response body stored in the attempt.
+	responseSnippetSize = 1024
+)
+
+// deliveryClient is the HTTP client used to send the webhook deliveries.
+var deliveryClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	// wakeUp wakes up the dispatcher when a delivery is added or finished.
+	wakeUp = make(chan struct{}, 1)
+
+	// sending is the webhooks whose delivery is being sent, only one delivery of a webhook is sent at a time.
+	sending   = make(map[uint]bool)
+	sendingMu sync.Mutex
+)
+
+// StartDispatcher starts the dispatcher of the webhook outbox.
+// The pending deliveries which were left by the last run are sent as well.
+func StartDispatcher() {
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			dispatch()
+
+			select {
+			case <-ticker.C:
+			case <-wakeUp:
+			}
+		}
+	}()
+}
+
+func wakeUpDispatcher() {
+	select {
+	case wakeUp <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch sends the earliest pending delivery of each webhook if it is due.
+// The later deliveries of the webhook wait until the earlier one is delivered or dead, so that they are in order.
+func dispatch() {
+	var heads []dbold.WebHookDelivery
+	if err := dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where("id IN (?)",
+		dbold.MySQL.Model(&dbold.WebHookDelivery{}).Select("MIN(id)").Where("status = ?", DeliveryPending).Group("web_hook_id").QueryExpr(),
+	).Find(&heads).Error; err != nil {
+		logger.New(logger.IMPORTANT, "webhook", "WebHook 投递记录获取失败: "+err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, delivery := range heads {
+		if delivery.NextAttemptAt.After(now) {
+			continue
+		}
+
+		sendingMu.Lock()
+		if sending[delivery.WebHookID] {
+			sendingMu.Unlock()
+			continue
+		}
+		sending[delivery.WebHookID] = true
+		sendingMu.Unlock()
+
+		go func(delivery dbold.WebHookDelivery) {
+			attempt(delivery)
+
+			sendingMu.Lock()
+			delete(sending, delivery.WebHookID)
+			sendingMu.Unlock()
+			wakeUpDispatcher()
+		}(delivery)
+	}
+}
+
+// attempt sends the delivery once and records the attempt. The delivery is retried with exponential backoff,
+// and it is dead after `Retry` retries failed.
+func attempt(delivery dbold.WebHookDelivery) {
+	var webHook *dbold.WebHook
+	if webHookStore, ok := store.Get("webHook"); ok {
+		webHooks, _ := webHookStore.([]dbold.WebHook)
+		for i := range webHooks {
+			if webHooks[i].ID == delivery.WebHookID {
+				webHook = &webHooks[i]
+				break
+			}
+		}
+	}
+
+	record := dbold.WebHookAttempt{WebHookDeliveryID: delivery.ID}
+	if webHook == nil {
+		record.Error = "webhook has been deleted"
+	} else {
+		startAt := time.Now()
+		statusCode, response, err := post(*webHook, delivery.DeliveryID, []byte(delivery.Body))
+		record.Latency = time.Since(startAt).Milliseconds()
+		record.StatusCode = statusCode
+		record.Response = response
+		if err != nil {
+			record.Error = err.Error()
+		}
+	}
+	if err := dbold.MySQL.Create(&record).Error; err != nil {
+		logger.New(logger.IMPORTANT, "webhook", "WebHook 投递尝试记录失败: "+err.Error())
+	}
+
+	delivery.Attempts++
+	update := map[string]interface{}{"attempts": delivery.Attempts}
+	switch {
+	case record.Error == "":
+		now := time.Now()
+		update["status"] = DeliveryDelivered
+		update["delivered_at"] = &now
+
+	case webHook == nil || delivery.Attempts > webHook.Retry:
+		update["status"] = DeliveryDead
+		url := ""
+		if webHook != nil {
+			url = webHook.URL
+		}
+		logger.New(logger.IMPORTANT, "webhook",
+			fmt.Sprintf("WebHook 投递失败: %s [%s] 已尝试 %d 次: %s", url, delivery.DeliveryID, delivery.Attempts, record.Error))
+
+	default:
+		update["next_attempt_at"] = time.Now().Add(backoff(webHook.Timeout, delivery.Attempts))
+	}
+
+	// The delivery may be redelivered by the manager in the meantime, do not overwrite it.
+	if err := dbold.MySQL.Model(&dbold.WebHookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ? AND redeliveries = ?", delivery.ID, DeliveryPending, delivery.Attempts-1, delivery.Redeliveries).
+		Updates(update).Error; err != nil {
+		logger.New(logger.IMPORTANT, "webhook", "WebHook 投递记录更新失败: "+err.Error())
+	}
+}
+
+// backoff returns the interval before the next attempt, it doubles after each failed attempt.
+func backoff(timeout int, attempts int) time.Duration {
+	interval := time.Duration(timeout) * time.Second
+	if interval <= 0 {
+		interval = defaultBackoff
+	}
+	for i := 1; i < attempts && interval < maxBackoff; i++ {
+		interval *= 2
+	}
+	if interval > maxBackoff {
+		interval = maxBackoff
+	}
+	return interval
+}
+
+// post sends the delivery to the webhook.
+// It returns the status code and the beginning of the response body.
+func post(webHook dbold.WebHook, deliveryID string, body []byte) (int, string, error) {
+	req, err := newDeliveryRequest(webHook, deliveryID, body)
+	if err != nil {
+		return 0, "", err
+	}
+	return send(req)
+}
+
+// newDeliveryRequest returns the request of the delivery, it is signed with the webhook token when it is created.
+func newDeliveryRequest(webHook dbold.WebHook, deliveryID string, body []byte) (*http.Request, error) {
+	method, url := http.MethodPost, webHook.URL
+	if webHook.Format == FORMAT_MATRIX {
+		// Matrix sends the message with a transaction ID, the retries of the delivery are not sent twice.
+		// https://spec.matrix.org/v1.1/client-server-api/#put_matrixclientv3roomsroomidsendeventtypetxnid
+		u, err := neturl.Parse(webHook.URL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse URL")
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + neturl.PathEscape(deliveryID)
+		method, url = http.MethodPut, u.String()
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The receivers check the timestamp with their wall clock, so the real time is used even in rehearsal.
+	webhooksign.SetHeaders(req.Header, webHook.Token, time.Now(), deliveryID, body)
+	return req, nil
+}
+
+// send sends the request, and returns the status code and the beginning of the response body.
+func send(req *http.Request) (int, string, error) {
+	resp, err := deliveryClient.Do(req)
+	if err != nil {
+		return 0, "", errors.Wrap(err, "send request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, responseSnippetSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(snippet), errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(snippet), nil
+}
+
+// GetWebHookDeliveries returns the deliveries in the outbox, the latest first.
+func GetWebHookDeliveries(c *gin.Context) (int, interface{}) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		return utils.MakeErrJSON(400, 40059,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+	per, err := strconv.Atoi(c.DefaultQuery("per", "15"))
+	if err != nil || per <= 0 || per >= 100 { // Limit to 100 items per page
+		return utils.MakeErrJSON(400, 40059,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+	webHookID, err := strconv.Atoi(c.DefaultQuery("webhook", "0"))
+	if err != nil || webHookID < 0 {
+		return utils.MakeErrJSON(400, 40059,
+			locales.I18n.T(c.GetString("lang"), "general.error_query"),
+		)
+	}
+
+	query := dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where(&dbold.WebHookDelivery{
+		WebHookID: uint(webHookID),
+		Status:    c.Query("status"),
+	})
+
+	var total int
+	query.Count(&total)
+
+	var deliveries []dbold.WebHookDelivery
+	query.Order("id DESC").Offset((page - 1) * per).Limit(per).Find(&deliveries)
+
+	return utils.MakeSuccessJSON(gin.H{
+		"array": deliveries,
+		"total": total,
+	})
+}
+
+// GetWebHookDelivery returns the delivery with all its attempts.
+func GetWebHookDelivery(c *gin.Context) (int, interface{}) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		return utils.MakeErrJSON(400, 40059,
+			locales.I18n.T(c.GetString("lang"), "general.must_be_number", gin.H{"key": "id"}),
+		)
+	}
+
+	var delivery dbold.WebHookDelivery
+	dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where(&dbold.WebHookDelivery{Model: gorm.Model{ID: uint(id)}}).Find(&delivery)
+	if delivery.ID == 0 {
+		return utils.MakeErrJSON(404, 40407,
+			locales.I18n.T(c.GetString("lang"), "webhook.delivery_not_found"),
+		)
+	}
+
+	var attempts []dbold.WebHookAttempt
+	dbold.MySQL.Model(&dbold.WebHookAttempt{}).Where(&dbold.WebHookAttempt{WebHookDeliveryID: delivery.ID}).Order("id").Find(&attempts)
+
+	return utils.MakeSuccessJSON(gin.H{
+		"Delivery": delivery,
+		"Attempts": attempts,
+	})
+}
+
+// RedeliverWebHook puts the delivery back to the outbox with all the retries, it keeps the delivery ID.
+func RedeliverWebHook(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		ID uint `binding:"required"`
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40060,
+			locales.I18n.T(c.GetString("lang"), "general.error_payload"),
+		)
+	}
+
+	var delivery dbold.WebHookDelivery
+	dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where(&dbold.WebHookDelivery{Model: gorm.Model{ID: inputForm.ID}}).Find(&delivery)
+	if delivery.ID == 0 {
+		return utils.MakeErrJSON(404, 40407,
+			locales.I18n.T(c.GetString("lang"), "webhook.delivery_not_found"),
+		)
+	}
+
+	if err := dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"status":          DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"delivered_at":    nil,
+		"redeliveries":    gorm.Expr("redeliveries + 1"),
+	}).Error; err != nil {
+		return utils.MakeErrJSON(500, 50034,
+			locales.I18n.T(c.GetString("lang"), "webhook.redeliver_error"),
+		)
+	}
+	wakeUpDispatcher()
+
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "webhook.redelivered"))
+}
@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/conf"
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/store"
+	"Cardinal/pkg/webhooksign"
+)
+
+func Test_backoff(t *testing.T) {
+	// The default interval is used if the timeout is not set.
+	assert.Equal(t, 5*time.Second, backoff(0, 1))
+	assert.Equal(t, 10*time.Second, backoff(0, 2))
+	assert.Equal(t, 20*time.Second, backoff(0, 3))
+
+	// It doubles from the timeout after each failed attempt.
+	assert.Equal(t, 3*time.Second, backoff(3, 1))
+	assert.Equal(t, 6*time.Second, backoff(3, 2))
+	assert.Equal(t, 12*time.Second, backoff(3, 3))
+
+	// It is capped at the maximum interval.
+	assert.Equal(t, maxBackoff, backoff(0, 11))
+	assert.Equal(t, maxBackoff, backoff(0, 100))
+	assert.Equal(t, maxBackoff, backoff(7200, 1))
+}
+
+// newTestOutbox connects the test database and empties the outbox,
+// the given webhooks are put into the cache as if they are loaded from the database.
+func newTestOutbox(t *testing.T, webHooks ...dbold.WebHook) {
+	if err := conf.TestInit(); err != nil {
+		t.Fatalf("Failed to init test config: %v", err)
+	}
+
+	db, err := gorm.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&loc=Local&charset=utf8mb4,utf8",
+		conf.Database.User,
+		conf.Database.Password,
+		conf.Database.Host,
+		conf.Database.Name,
+	))
+	if err != nil {
+		t.Fatalf("Failed to open connection: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	db.AutoMigrate(&dbold.Log{}, &dbold.DynamicConfig{}, &dbold.WebHookDelivery{}, &dbold.WebHookAttempt{})
+	db.Unscoped().Delete(&dbold.WebHookAttempt{})
+	db.Unscoped().Delete(&dbold.WebHookDelivery{})
+	dbold.MySQL = db
+
+	store.Init()
+	store.Set("webHook", webHooks)
+}
+
+// dispatchAndWait dispatches the due deliveries, and waits until they are sent.
+func dispatchAndWait(t *testing.T) {
+	dispatch()
+	assert.Eventually(t, func() bool {
+		sendingMu.Lock()
+		defer sendingMu.Unlock()
+		return len(sending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func getDeliveries(webHookID uint) []dbold.WebHookDelivery {
+	var deliveries []dbold.WebHookDelivery
+	dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where(&dbold.WebHookDelivery{WebHookID: webHookID}).Order("id").Find(&deliveries)
+	return deliveries
+}
+
+// testReceiver is a webhook receiver which records the delivery IDs,
+// the first `failures` requests are answered with 500 Internal Server Error.
+type testReceiver struct {
+	mu         sync.Mutex
+	failures   int
+	deliveries []string
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries = append(r.deliveries, req.Header.Get(webhooksign.HeaderDelivery))
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("something went wrong"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *testReceiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.deliveries...)
+}
+
+func Test_attempt(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	receiver := &testReceiver{failures: 100}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webHook := dbold.WebHook{Model: gorm.Model{ID: 1001}, URL: server.URL, Type: ANY_HOOK, Retry: 2, Timeout: 3}
+	newTestOutbox(t, webHook)
+
+	Add(BEGIN_HOOK, nil)
+	deliveries := getDeliveries(webHook.ID)
+	assert.Equal(t, 1, len(deliveries))
+
+	// The interval before the next attempt doubles after each failed attempt.
+	for attempts, wantBackoff := range []time.Duration{3 * time.Second, 6 * time.Second} {
+		attempt(getDeliveries(webHook.ID)[0])
+
+		delivery := getDeliveries(webHook.ID)[0]
+		assert.Equal(t, DeliveryPending, delivery.Status)
+		assert.Equal(t, attempts+1, delivery.Attempts)
+		assert.WithinDuration(t, time.Now().Add(wantBackoff), delivery.NextAttemptAt, 2*time.Second)
+	}
+
+	// The delivery is dead after all the retries failed.
+	attempt(getDeliveries(webHook.ID)[0])
+	delivery := getDeliveries(webHook.ID)[0]
+	assert.Equal(t, DeliveryDead, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, []string{delivery.DeliveryID, delivery.DeliveryID, delivery.DeliveryID}, receiver.received())
+
+	// Every attempt is recorded with the response.
+	var attempts []dbold.WebHookAttempt
+	dbold.MySQL.Model(&dbold.WebHookAttempt{}).Where(&dbold.WebHookAttempt{WebHookDeliveryID: delivery.ID}).Find(&attempts)
+	assert.Equal(t, 3, len(attempts))
+	for _, a := range attempts {
+		assert.Equal(t, http.StatusInternalServerError, a.StatusCode)
+		assert.Equal(t, "something went wrong", a.Response)
+		assert.Equal(t, "unexpected status code 500", a.Error)
+	}
+
+	// The dead delivery is not sent anymore.
+	dispatchAndWait(t)
+	assert.Equal(t, 3, len(receiver.received()))
+}
+
+func Test_dispatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	receiver := &testReceiver{failures: 1}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	otherReceiver := &testReceiver{}
+	otherServer := httptest.NewServer(otherReceiver)
+	defer otherServer.Close()
+
+	webHook := dbold.WebHook{Model: gorm.Model{ID: 1001}, URL: server.URL, Type: ANY_HOOK, Retry: 3}
+	otherWebHook := dbold.WebHook{Model: gorm.Model{ID: 1002}, URL: otherServer.URL, Type: ANY_HOOK, Retry: 3}
+	newTestOutbox(t, webHook, otherWebHook)
+
+	Add(BEGIN_HOOK, nil)
+	Add(NEW_ROUND_HOOK, 1)
+	first, second := getDeliveries(webHook.ID)[0], getDeliveries(webHook.ID)[1]
+
+	// Only the earliest delivery of the webhook is sent, the other webhook is not blocked by it.
+	dispatchAndWait(t)
+	assert.Equal(t, []string{first.DeliveryID}, receiver.received())
+	assert.Equal(t, 1, len(otherReceiver.received()))
+
+	// The later delivery waits until the failed one is retried.
+	dispatchAndWait(t)
+	assert.Equal(t, []string{first.DeliveryID}, receiver.received())
+	assert.Equal(t, 2, len(otherReceiver.received()))
+
+	dbold.MySQL.Model(&dbold.WebHookDelivery{}).Where("id = ?", first.ID).Update("next_attempt_at", time.Now())
+	dispatchAndWait(t)
+	dispatchAndWait(t)
+	assert.Equal(t, []string{first.DeliveryID, first.DeliveryID, second.DeliveryID}, receiver.received())
+	for _, delivery := range getDeliveries(webHook.ID) {
+		assert.Equal(t, DeliveryDelivered, delivery.Status)
+	}
+}
+
+func Test_RedeliverWebHook(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	receiver := &testReceiver{failures: 1}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webHook := dbold.WebHook{Model: gorm.Model{ID: 1001}, URL: server.URL, Type: ANY_HOOK, Retry: 0}
+	newTestOutbox(t, webHook)
+
+	Add(END_HOOK, nil)
+	dispatchAndWait(t)
+	delivery := getDeliveries(webHook.ID)[0]
+	assert.Equal(t, DeliveryDead, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+
+	redeliver := func() {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(fmt.Sprintf(`{"ID": %d}`, delivery.ID)))
+		code, _ := RedeliverWebHook(c)
+		assert.Equal(t, 200, code)
+	}
+	redeliver()
+
+	// The attempts are reset, so the delivery has all the retries again.
+	delivery = getDeliveries(webHook.ID)[0]
+	assert.Equal(t, DeliveryPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+
+	// The attempt started before the redelivery does not overwrite it.
+	inFlight := delivery
+	redeliver()
+	attempt(inFlight)
+	delivery = getDeliveries(webHook.ID)[0]
+	assert.Equal(t, DeliveryPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.Equal(t, 2, delivery.Redeliveries)
+
+	dispatchAndWait(t)
+	delivery = getDeliveries(webHook.ID)[0]
+	assert.Equal(t, DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	// The delivery ID is kept, so the receiver can drop it if it has been received.
+	assert.Equal(t, []string{delivery.DeliveryID, delivery.DeliveryID, delivery.DeliveryID}, receiver.received())
+}
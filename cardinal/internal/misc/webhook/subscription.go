@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"strconv"
+	"strings"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/logger"
+
+	"github.com/jinzhu/gorm"
+)
+
+// eventSeverities is the catalog of the webhook events with their severity, the severity is the same as the log level.
+var eventSeverities = map[string]int{
+	BEGIN_HOOK:             logger.WARNING,
+	NEW_ROUND_HOOK:         logger.NORMAL,
+	PAUSE_HOOK:             logger.WARNING,
+	END_HOOK:               logger.WARNING,
+	SUBMIT_FLAG_HOOK:       logger.NORMAL,
+	CHECK_DOWN_HOOK:        logger.WARNING,
+	FIRST_BLOOD_HOOK:       logger.WARNING,
+	SCORE_CHECK_HOOK:       logger.IMPORTANT,
+	TEAM_LOGIN_HOOK:        logger.NORMAL,
+	BULLETIN_HOOK:          logger.WARNING,
+	FLAG_PLANT_FAILED_HOOK: logger.IMPORTANT,
+	SCORE_RECOMPUTED_HOOK:  logger.NORMAL,
+	CONFIG_CHANGED_HOOK:    logger.WARNING,
+}
+
+// parseTypes returns the event types which the webhook subscribes to, the legacy single type is supported.
+// It returns false if any of the types is not in the catalog.
+func parseTypes(types []string, legacyType string) (string, bool) {
+	if legacyType != "" {
+		types = append(types, strings.Split(legacyType, ",")...)
+	}
+
+	subscribed := make([]string, 0, len(types))
+	seen := make(map[string]bool, len(types))
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == ANY_HOOK {
+			return ANY_HOOK, true
+		}
+		if _, ok := eventSeverities[t]; !ok {
+			return "", false
+		}
+		if !seen[t] {
+			seen[t] = true
+			subscribed = append(subscribed, t)
+		}
+	}
+	if len(subscribed) == 0 {
+		return "", false
+	}
+	return strings.Join(subscribed, ","), true
+}
+
+// joinIDs returns the IDs separated by commas.
+func joinIDs(ids []uint) string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(strs, ",")
+}
+
+// splitIDs returns the IDs separated by commas, the invalid ones are ignored.
+func splitIDs(str string) map[uint]bool {
+	ids := make(map[uint]bool)
+	for _, s := range strings.Split(str, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64); err == nil {
+			ids[uint(id)] = true
+		}
+	}
+	return ids
+}
+
+// subscribed returns whether the event should be sent to the webhook.
+// The events which are not related to any team or challenge are not filtered by the team or challenge filter.
+func subscribed(webHook dbold.WebHook, e *event) bool {
+	if webHook.Type != ANY_HOOK {
+		if _, ok := splitTypes(webHook.Type)[e.Type]; !ok {
+			return false
+		}
+	}
+
+	if eventSeverities[e.Type] < webHook.MinSeverity {
+		return false
+	}
+
+	if webHook.TeamIDs != "" {
+		if teams := e.teams(); len(teams) != 0 && !intersect(splitIDs(webHook.TeamIDs), teams) {
+			return false
+		}
+	}
+	if webHook.ChallengeIDs != "" {
+		if challenges := e.challenges(); len(challenges) != 0 && !intersect(splitIDs(webHook.ChallengeIDs), challenges) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitTypes(str string) map[string]struct{} {
+	types := make(map[string]struct{})
+	for _, t := range strings.Split(str, ",") {
+		types[strings.TrimSpace(t)] = struct{}{}
+	}
+	return types
+}
+
+func intersect(ids map[uint]bool, eventIDs []uint) bool {
+	for _, id := range eventIDs {
+		if ids[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// teams returns the IDs of the teams related to the event.
+func (e *event) teams() []uint {
+	fields, _ := e.genericData().(map[string]interface{})
+
+	var teams []uint
+	for _, key := range []string{"team", "from", "to"} {
+		if id := dataID(fields[key]); id != 0 {
+			teams = append(teams, id)
+		}
+	}
+	return teams
+}
+
+// challenges returns the IDs of the challenges related to the event.
+func (e *event) challenges() []uint {
+	fields, _ := e.genericData().(map[string]interface{})
+
+	if id := dataID(fields["challenge"]); id != 0 {
+		return []uint{id}
+	}
+	if gameBoxID := dataID(fields["gamebox"]); gameBoxID != 0 {
+		var gameBox dbold.GameBox
+		dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: gameBoxID}}).Find(&gameBox)
+		if gameBox.ID != 0 {
+			return []uint{gameBox.ChallengeID}
+		}
+	}
+	return nil
+}
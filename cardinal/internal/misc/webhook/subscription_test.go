@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/logger"
+)
+
+func Test_parseTypes(t *testing.T) {
+	types, ok := parseTypes([]string{CHECK_DOWN_HOOK, FIRST_BLOOD_HOOK, CHECK_DOWN_HOOK}, "")
+	assert.True(t, ok)
+	assert.Equal(t, "check_down,first_blood", types)
+
+	// The legacy single type.
+	types, ok = parseTypes(nil, NEW_ROUND_HOOK)
+	assert.True(t, ok)
+	assert.Equal(t, "new_round", types)
+
+	types, ok = parseTypes([]string{CHECK_DOWN_HOOK, ANY_HOOK}, "")
+	assert.True(t, ok)
+	assert.Equal(t, ANY_HOOK, types)
+
+	_, ok = parseTypes([]string{CHECK_DOWN_HOOK, "unknown"}, "")
+	assert.False(t, ok)
+
+	_, ok = parseTypes(nil, "")
+	assert.False(t, ok)
+}
+
+func Test_subscribed(t *testing.T) {
+	checkDown := func() *event {
+		return &event{Type: CHECK_DOWN_HOOK, Data: map[string]interface{}{"team": 2, "challenge": 3, "gamebox": 5}}
+	}
+	gameBegin := func() *event {
+		return &event{Type: BEGIN_HOOK}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		webHook dbold.WebHook
+		event   *event
+		want    bool
+	}{
+		{
+			name:    "any",
+			webHook: dbold.WebHook{Type: ANY_HOOK},
+			event:   checkDown(),
+			want:    true,
+		},
+		{
+			name:    "subscribed type",
+			webHook: dbold.WebHook{Type: "first_blood,check_down"},
+			event:   checkDown(),
+			want:    true,
+		},
+		{
+			name:    "unsubscribed type",
+			webHook: dbold.WebHook{Type: "first_blood,submit_flag"},
+			event:   checkDown(),
+			want:    false,
+		},
+		{
+			name:    "severity",
+			webHook: dbold.WebHook{Type: ANY_HOOK, MinSeverity: logger.IMPORTANT},
+			event:   checkDown(),
+			want:    false,
+		},
+		{
+			name:    "team",
+			webHook: dbold.WebHook{Type: ANY_HOOK, TeamIDs: "1,2"},
+			event:   checkDown(),
+			want:    true,
+		},
+		{
+			name:    "other team",
+			webHook: dbold.WebHook{Type: ANY_HOOK, TeamIDs: "1,4"},
+			event:   checkDown(),
+			want:    false,
+		},
+		{
+			name:    "other challenge",
+			webHook: dbold.WebHook{Type: ANY_HOOK, TeamIDs: "2", ChallengeIDs: "1"},
+			event:   checkDown(),
+			want:    false,
+		},
+		{
+			name:    "event without team",
+			webHook: dbold.WebHook{Type: ANY_HOOK, TeamIDs: "1", ChallengeIDs: "1"},
+			event:   gameBegin(),
+			want:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, subscribed(tc.webHook, tc.event))
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"strings"
+	"time"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/utils"
+	"Cardinal/pkg/webhooksign"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/thanhpk/randstr"
+)
+
+// testDeliveryPrefix is the prefix of the test delivery ID, so that the receivers can tell them from the real ones.
+const testDeliveryPrefix = "test-"
+
+// sampleData returns the data of a synthetic event of the given webhook type,
+// it has the same structure as the real one.
+func sampleData(webHookType string) interface{} {
+	switch webHookType {
+	case NEW_ROUND_HOOK:
+		return 1
+	case SUBMIT_FLAG_HOOK:
+		return gin.H{"from": 1, "to": 2, "challenge": 1, "gamebox": 1}
+	case CHECK_DOWN_HOOK:
+		return gin.H{"team": 1, "challenge": 1, "gamebox": 1}
+	case FIRST_BLOOD_HOOK:
+		return gin.H{"from": 1, "to": 2, "challenge": 1, "gamebox": 1, "score": 50}
+	case SCORE_CHECK_HOOK:
+		return gin.H{"Round": 1, "CheckedAt": time.Now(), "Violations": []interface{}{}}
+	case TEAM_LOGIN_HOOK:
+		return gin.H{"team": 1}
+	case BULLETIN_HOOK:
+		return gin.H{"bulletin": 1, "title": "Test bulletin"}
+	case FLAG_PLANT_FAILED_HOOK:
+		return gin.H{"team": 1, "challenge": 1, "gamebox": 1, "round": 1}
+	case SCORE_RECOMPUTED_HOOK:
+		return gin.H{"round": 1}
+	case CONFIG_CHANGED_HOOK:
+		return gin.H{"keys": []string{utils.TITLE_CONF}}
+	default:
+		return nil
+	}
+}
+
+// TestWebHook sends a synthetic event to the webhook immediately, the delivery is not added to the outbox.
+// It returns the request which was sent, the response, and whether the signature is verified by a local verifier.
+// The request is only built but not sent if `DryRun` is true, which is used to preview the payload.
+func TestWebHook(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		ID     uint `binding:"required"`
+		Type   string
+		DryRun bool
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40061,
+			locales.I18n.T(c.GetString("lang"), "general.error_payload"),
+		)
+	}
+
+	var webHook dbold.WebHook
+	dbold.MySQL.Model(&dbold.WebHook{}).Where(&dbold.WebHook{Model: gorm.Model{ID: inputForm.ID}}).Find(&webHook)
+	if webHook.ID == 0 {
+		return utils.MakeErrJSON(404, 40405,
+			locales.I18n.T(c.GetString("lang"), "webhook.not_found"),
+		)
+	}
+
+	// Send the first event type which the webhook subscribes to by default.
+	webHookType := inputForm.Type
+	if webHookType == "" {
+		webHookType = strings.Split(webHook.Type, ",")[0]
+		if webHookType == ANY_HOOK {
+			webHookType = NEW_ROUND_HOOK
+		}
+	}
+	if _, ok := eventSeverities[webHookType]; !ok {
+		return utils.MakeErrJSON(400, 40035,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_type"),
+		)
+	}
+
+	e := &event{Type: webHookType, Data: sampleData(webHookType), Test: true}
+	body, err := e.render(webHook)
+	if err != nil {
+		return utils.MakeErrJSON(400, 40064,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_template", gin.H{"error": err.Error()}),
+		)
+	}
+
+	req, err := newDeliveryRequest(webHook, testDeliveryPrefix+randstr.Hex(16), body)
+	if err != nil {
+		return utils.MakeErrJSON(400, 40062,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_url"),
+		)
+	}
+
+	// Verify the request as the receiver does.
+	signatureError := ""
+	if err := webhooksign.NewVerifier(webHook.Token, 0).Verify(req.Header, body); err != nil {
+		signatureError = err.Error()
+	}
+
+	result := gin.H{
+		"Request": gin.H{
+			"Method": req.Method,
+			"URL":    req.URL.String(),
+			"Header": req.Header,
+			"Body":   string(body),
+		},
+		"SignatureVerified": signatureError == "",
+		"SignatureError":    signatureError,
+	}
+	if inputForm.DryRun {
+		return utils.MakeSuccessJSON(result)
+	}
+
+	startAt := time.Now()
+	statusCode, response, err := send(req)
+	responseResult := gin.H{
+		"StatusCode": statusCode,
+		"Body":       response,
+		"Latency":    time.Since(startAt).Milliseconds(),
+		"Error":      "",
+	}
+	if err != nil {
+		responseResult["Error"] = err.Error()
+	}
+	result["Response"] = responseResult
+
+	return utils.MakeSuccessJSON(result)
+}
@@ -1,7 +1,6 @@
 package webhook
 
 import (
-	"net/http"
 	"strconv"
 	"time"
 
@@ -13,19 +12,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
-	"github.com/parnurzeal/gorequest"
 	"github.com/patrickmn/go-cache"
 	"github.com/thanhpk/randstr"
 )
 
 const (
-	ANY_HOOK         string = "any"
-	NEW_ROUND_HOOK   string = "new_round"
-	SUBMIT_FLAG_HOOK string = "submit_flag"
-	CHECK_DOWN_HOOK  string = "check_down"
-	BEGIN_HOOK       string = "game_begin"
-	PAUSE_HOOK       string = "game_pause"
-	END_HOOK         string = "game_end"
+	ANY_HOOK               string = "any"
+	NEW_ROUND_HOOK         string = "new_round"
+	SUBMIT_FLAG_HOOK       string = "submit_flag"
+	CHECK_DOWN_HOOK        string = "check_down"
+	FIRST_BLOOD_HOOK       string = "first_blood"
+	SCORE_CHECK_HOOK       string = "score_check"
+	BEGIN_HOOK             string = "game_begin"
+	PAUSE_HOOK             string = "game_pause"
+	END_HOOK               string = "game_end"
+	TEAM_LOGIN_HOOK        string = "team_login"
+	BULLETIN_HOOK          string = "bulletin_published"
+	FLAG_PLANT_FAILED_HOOK string = "flag_plant_failed"
+	SCORE_RECOMPUTED_HOOK  string = "score_recomputed"
+	CONFIG_CHANGED_HOOK    string = "config_changed"
 )
 
 func GetWebHook(c *gin.Context) (int, interface{}) {
@@ -37,11 +42,20 @@ func GetWebHook(c *gin.Context) (int, interface{}) {
 func NewWebHook(c *gin.Context) (int, interface{}) {
 	type InputForm struct {
 		URL   string `binding:"required"`
-		Type  string `binding:"required"`
+		Type  string // Deprecated: use Types instead.
+		Types []string
 		Token string // If the token is empty, generate one automatically.
 
 		Retry   int
 		Timeout int
+
+		Format   string
+		Template string
+
+		// The events are only sent if they are related to the teams or challenges, empty means all.
+		TeamIDs      []uint
+		ChallengeIDs []uint
+		MinSeverity  int
 	}
 
 	var inputForm InputForm
@@ -53,24 +67,43 @@ func NewWebHook(c *gin.Context) (int, interface{}) {
 	}
 
 	// Check type
-	switch inputForm.Type {
-	case ANY_HOOK, NEW_ROUND_HOOK, SUBMIT_FLAG_HOOK, CHECK_DOWN_HOOK, BEGIN_HOOK, PAUSE_HOOK, END_HOOK:
-	default:
+	types, ok := parseTypes(inputForm.Types, inputForm.Type)
+	if !ok {
 		return utils.MakeErrJSON(400, 40035,
 			locales.I18n.T(c.GetString("lang"), "webhook.error_type"),
 		)
 	}
 
+	if ok, err := checkFormat(inputForm.Format, inputForm.Template); !ok {
+		return utils.MakeErrJSON(400, 40063,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_format"),
+		)
+	} else if err != nil {
+		return utils.MakeErrJSON(400, 40064,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_template", gin.H{"error": err.Error()}),
+		)
+	}
+	if inputForm.Format == "" {
+		inputForm.Format = FORMAT_NATIVE
+	}
+
 	if inputForm.Token == "" {
 		inputForm.Token = randstr.String(32)
 	}
 
 	newWebHook := &dbold.WebHook{
 		URL:     inputForm.URL,
-		Type:    inputForm.Type,
+		Type:    types,
 		Token:   inputForm.Token,
 		Retry:   inputForm.Retry,
 		Timeout: inputForm.Timeout,
+
+		Format:   inputForm.Format,
+		Template: inputForm.Template,
+
+		TeamIDs:      joinIDs(inputForm.TeamIDs),
+		ChallengeIDs: joinIDs(inputForm.ChallengeIDs),
+		MinSeverity:  inputForm.MinSeverity,
 	}
 
 	tx := dbold.MySQL.Begin()
@@ -89,11 +122,20 @@ func EditWebHook(c *gin.Context) (int, interface{}) {
 	type InputForm struct {
 		ID    uint   `binding:"required"`
 		URL   string `binding:"required"`
-		Type  string `binding:"required"`
+		Type  string // Deprecated: use Types instead.
+		Types []string
 		Token string
 
 		Retry   int
 		Timeout int
+
+		Format   string
+		Template string
+
+		// The events are only sent if they are related to the teams or challenges, empty means all.
+		TeamIDs      []uint
+		ChallengeIDs []uint
+		MinSeverity  int
 	}
 
 	var inputForm InputForm
@@ -113,28 +155,48 @@ func EditWebHook(c *gin.Context) (int, interface{}) {
 	}
 
 	// Check type
-	switch inputForm.Type {
-	case ANY_HOOK, NEW_ROUND_HOOK, SUBMIT_FLAG_HOOK, CHECK_DOWN_HOOK, BEGIN_HOOK, PAUSE_HOOK, END_HOOK:
-	default:
+	types, ok := parseTypes(inputForm.Types, inputForm.Type)
+	if !ok {
 		return utils.MakeErrJSON(400, 40035,
 			locales.I18n.T(c.GetString("lang"), "webhook.error_type"),
 		)
 	}
 
+	if ok, err := checkFormat(inputForm.Format, inputForm.Template); !ok {
+		return utils.MakeErrJSON(400, 40063,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_format"),
+		)
+	} else if err != nil {
+		return utils.MakeErrJSON(400, 40064,
+			locales.I18n.T(c.GetString("lang"), "webhook.error_template", gin.H{"error": err.Error()}),
+		)
+	}
+	if inputForm.Format == "" {
+		inputForm.Format = FORMAT_NATIVE
+	}
+
 	if inputForm.Token == "" {
 		inputForm.Token = randstr.String(32)
 	}
 
-	editWebHook := &dbold.WebHook{
-		URL:     inputForm.URL,
-		Type:    inputForm.Type,
-		Token:   inputForm.Token,
-		Retry:   inputForm.Retry,
-		Timeout: inputForm.Timeout,
+	// The filters can be cleared, so the zero values are updated as well.
+	editWebHook := map[string]interface{}{
+		"url":     inputForm.URL,
+		"type":    types,
+		"token":   inputForm.Token,
+		"retry":   inputForm.Retry,
+		"timeout": inputForm.Timeout,
+
+		"format":   inputForm.Format,
+		"template": inputForm.Template,
+
+		"team_ids":      joinIDs(inputForm.TeamIDs),
+		"challenge_ids": joinIDs(inputForm.ChallengeIDs),
+		"min_severity":  inputForm.MinSeverity,
 	}
 
 	tx := dbold.MySQL.Begin()
-	if tx.Model(&dbold.WebHook{}).Where(&dbold.WebHook{Model: gorm.Model{ID: inputForm.ID}}).Update(&editWebHook).RowsAffected != 1 {
+	if tx.Model(&dbold.WebHook{}).Where(&dbold.WebHook{Model: gorm.Model{ID: inputForm.ID}}).Updates(editWebHook).RowsAffected != 1 {
 		tx.Rollback()
 		return utils.MakeErrJSON(500, 50023,
 			locales.I18n.T(c.GetString("lang"), "webhook.edit_error"))
@@ -185,11 +247,20 @@ func RefreshWebHookStore() {
 	store.Set("webHook", webHooks, cache.NoExpiration)
 }
 
+// payload is the body of the webhook delivery.
+type payload struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	// Test is true if the delivery is sent by the manager to test the webhook, it is not a real game event.
+	Test bool `json:"test,omitempty"`
+}
+
 // Add used to add a webhook.
 func Add(webHookType string, webHookData interface{}) {
 	sendWebHook(webHookType, webHookData)
 }
 
+// sendWebHook adds the deliveries of the matched webhooks to the outbox, the dispatcher will send them.
 func sendWebHook(webHookType string, webHookData interface{}) {
 	webHookStore, ok := store.Get("webHook")
 	if !ok {
@@ -202,30 +273,28 @@ func sendWebHook(webHookType string, webHookData interface{}) {
 		return
 	}
 
+	e := &event{Type: webHookType, Data: webHookData}
 	for _, v := range webHooks {
-		if v.Type == webHookType || v.Type == ANY_HOOK {
-			go func(webhook dbold.WebHook) {
-				nonce := randstr.Hex(16)
-
-				req := gorequest.New().Post(webhook.URL)
-				req.Data = map[string]interface{}{
-					"type": webHookType,
-					"data": webHookData,
-					// TODO: Here is not secure. The data should be added in the signature.
-					"nonce":     nonce,
-					"signature": utils.HmacSha1Encode(nonce, webhook.Token),
-				}
-				req.Retry(webhook.Retry, time.Duration(webhook.Timeout)*time.Second,
-					http.StatusInternalServerError,
-					http.StatusBadGateway,
-					http.StatusBadRequest,
-				)
-				resp, _, _ := req.End()
-
-				if resp == nil || resp.StatusCode != 200 {
-					logger.New(logger.IMPORTANT, "webhook_cache", "WebHook 投递失败: "+webhook.URL)
-				}
-			}(v)
+		if subscribed(v, e) {
+			// The body is rendered in the format of the webhook when it is added,
+			// so that the retries send the same body.
+			body, err := e.render(v)
+			if err != nil {
+				logger.New(logger.IMPORTANT, "webhook", "WebHook 数据生成失败: "+v.URL+" "+err.Error())
+				continue
+			}
+
+			if err := dbold.MySQL.Create(&dbold.WebHookDelivery{
+				WebHookID:     v.ID,
+				DeliveryID:    randstr.Hex(16),
+				Type:          webHookType,
+				Body:          string(body),
+				Status:        DeliveryPending,
+				NextAttemptAt: time.Now(),
+			}).Error; err != nil {
+				logger.New(logger.IMPORTANT, "webhook", "WebHook 投递记录创建失败: "+v.URL+" "+err.Error())
+			}
 		}
 	}
+	wakeUpDispatcher()
 }
@@ -11,6 +11,7 @@ import (
 
 	"Cardinal/internal/db"
 	"Cardinal/internal/store"
+	"Cardinal/internal/timeutil"
 )
 
 const (
@@ -59,6 +60,7 @@ func SetTitle(ctx context.Context) error {
 }
 
 // SetRankList calculates the ranking list for teams and managers.
+// The ranking list for teams may be frozen or delayed, while the managers' one is always live.
 func SetRankList(ctx context.Context) error {
 	rankList, err := db.Ranks.List(ctx)
 	if err != nil {
@@ -66,13 +68,87 @@ func SetRankList(ctx context.Context) error {
 	}
 	store.Set(CacheKeyRankForManager, rankList)
 
-	// Team accounts can't get the score of the game boxes.
-	for _, rankItem := range rankList {
-		for _, gameBox := range rankItem.GameBoxes {
-			gameBox.Score = 0
-		}
+	teamRankList, err := teamRankList(ctx, rankList)
+	if err != nil {
+		return errors.Wrap(err, "get team rank list")
 	}
-	store.Set(CacheKeyRankForTeam, rankList)
+	store.Set(CacheKeyRankForTeam, teamRankList)
 
 	return nil
 }
+
+// teamRankList returns the ranking list for teams.
+// It returns the live ranking list without the game box scores if the ranking list is neither frozen nor delayed,
+// otherwise the ranking list is built from the rank history.
+func teamRankList(ctx context.Context, rankList []*db.RankItem) ([]*db.RankItem, error) {
+	round, limited, err := publicRound(ctx, timeutil.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "get public round")
+	}
+
+	if !limited {
+		// Team accounts can't get the score of the game boxes.
+		teamRankList := make([]*db.RankItem, 0, len(rankList))
+		for _, rankItem := range rankList {
+			gameBoxes := make(db.GameBoxInfoList, 0, len(rankItem.GameBoxes))
+			for _, gameBox := range rankItem.GameBoxes {
+				gameBoxInfo := *gameBox
+				gameBoxInfo.Score = 0
+				gameBoxes = append(gameBoxes, &gameBoxInfo)
+			}
+
+			teamRankItem := *rankItem
+			teamRankItem.GameBoxes = gameBoxes
+			teamRankList = append(teamRankList, &teamRankItem)
+		}
+		return teamRankList, nil
+	}
+
+	if round == 0 {
+		// There is no ranking list can be shown yet.
+		return []*db.RankItem{}, nil
+	}
+
+	rankHistories, err := db.RankHistories.Get(ctx, db.GetRankHistoryOptions{
+		StartRound: round,
+		EndRound:   round,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get rank histories")
+	}
+
+	teams, err := db.Teams.Get(ctx, db.GetTeamsOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "get teams")
+	}
+	teamSets := make(map[uint]*db.Team, len(teams))
+	for _, team := range teams {
+		teamSets[team.ID] = team
+	}
+
+	teamRankList := make([]*db.RankItem, 0, len(rankHistories))
+	for _, rankHistory := range rankHistories {
+		team, ok := teamSets[rankHistory.TeamID]
+		if !ok {
+			// The team has been deleted.
+			continue
+		}
+
+		gameBoxes := make(db.GameBoxInfoList, 0, len(rankHistory.GameBoxes))
+		for _, gameBox := range rankHistory.GameBoxes {
+			gameBoxes = append(gameBoxes, &db.GameBoxInfo{
+				ChallengeID: gameBox.ChallengeID,
+			})
+		}
+
+		teamRankList = append(teamRankList, &db.RankItem{
+			TeamID:    team.ID,
+			TeamName:  team.Name,
+			TeamLogo:  team.Logo,
+			Rank:      rankHistory.Rank,
+			Score:     rankHistory.Score,
+			GameBoxes: gameBoxes,
+		})
+	}
+	return teamRankList, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package rank
+
+import (
+	"context"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+
+	"Cardinal/internal/conf"
+	"Cardinal/internal/db"
+	"Cardinal/internal/timer"
+)
+
+// FreezeAt returns the time when the ranking list for teams is frozen.
+// It returns zero time if the freeze is disabled.
+func FreezeAt() time.Time {
+	if conf.Game.RankFreezeAt == (toml.LocalDateTime{}) {
+		return time.Time{}
+	}
+	return conf.Game.RankFreezeAt.In(time.Local)
+}
+
+// IsFrozen returns true if the ranking list for teams is frozen at the given time.
+// The ranking list will be unfrozen automatically when the game is over.
+func IsFrozen(t time.Time) bool {
+	freezeAt := FreezeAt()
+	if freezeAt.IsZero() {
+		return false
+	}
+	return !t.Before(freezeAt) && t.Before(endAt())
+}
+
+// DelayRound returns how many rounds the ranking list for teams is behind the live one at the given time.
+// It returns zero when the game is over.
+func DelayRound(t time.Time) uint {
+	if !t.Before(endAt()) {
+		return 0
+	}
+	return conf.Game.RankDelayRound
+}
+
+// endAt returns the end time of the game, which may be changed by the manager at runtime.
+func endAt() time.Time {
+	if endTime := timer.GetSnapshot().EndTime; !endTime.IsZero() {
+		return endTime
+	}
+	// The timer has not been started yet.
+	return conf.Game.EndAt.In(time.Local)
+}
+
+// publicRound returns the latest round which can be shown to teams at the given time,
+// the bool is false if the ranking list for teams is neither frozen nor delayed.
+// The round is zero if there is no round can be shown yet.
+func publicRound(ctx context.Context, t time.Time) (uint, bool, error) {
+	isFrozen := IsFrozen(t)
+	delayRound := DelayRound(t)
+	if !isFrozen && delayRound == 0 {
+		return 0, false, nil
+	}
+
+	var before time.Time
+	if isFrozen {
+		before = FreezeAt()
+	}
+	round, err := db.RankHistories.LatestRound(ctx, before)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "get latest rank history round")
+	}
+	if round <= delayRound {
+		return 0, true, nil
+	}
+	return round - delayRound, true, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package rank
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"Cardinal/internal/db"
+	"Cardinal/internal/timeutil"
+)
+
+// TeamHistory is the score timeline of a single team.
+type TeamHistory struct {
+	TeamID   uint
+	TeamName string
+	TeamLogo string
+	Points   []*HistoryPoint // Ordered by round.
+}
+
+// HistoryPoint is the team's standing at the end of a round.
+type HistoryPoint struct {
+	Round     uint
+	Rank      uint
+	Score     float64
+	GameBoxes []*GameBoxHistoryPoint `json:",omitempty"` // Manager only
+}
+
+// GameBoxHistoryPoint is the game box score at the end of a round.
+type GameBoxHistoryPoint struct {
+	ChallengeID uint
+	Score       float64
+}
+
+type HistoryOptions struct {
+	TeamID     uint
+	StartRound uint
+	EndRound   uint
+
+	ShowGameBoxScore bool
+	// Public hides the rounds which are not shown to teams when the ranking list is frozen or delayed.
+	Public bool
+}
+
+// History returns the score timeline of all the teams, or only one team if the team ID is given.
+func History(ctx context.Context, opts HistoryOptions) ([]*TeamHistory, error) {
+	if opts.Public {
+		round, limited, err := publicRound(ctx, timeutil.Now())
+		if err != nil {
+			return nil, errors.Wrap(err, "get public round")
+		}
+		if limited {
+			if round == 0 {
+				return []*TeamHistory{}, nil
+			}
+			if opts.EndRound == 0 || opts.EndRound > round {
+				opts.EndRound = round
+			}
+		}
+	}
+
+	rankHistories, err := db.RankHistories.Get(ctx, db.GetRankHistoryOptions{
+		TeamID:     opts.TeamID,
+		StartRound: opts.StartRound,
+		EndRound:   opts.EndRound,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get rank histories")
+	}
+
+	teams, err := db.Teams.Get(ctx, db.GetTeamsOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "get teams")
+	}
+
+	teamHistories := make([]*TeamHistory, 0, len(teams))
+	teamHistorySets := make(map[uint]*TeamHistory, len(teams))
+	for _, team := range teams {
+		if opts.TeamID != 0 && team.ID != opts.TeamID {
+			continue
+		}
+
+		teamHistory := &TeamHistory{
+			TeamID:   team.ID,
+			TeamName: team.Name,
+			TeamLogo: team.Logo,
+			Points:   []*HistoryPoint{},
+		}
+		teamHistories = append(teamHistories, teamHistory)
+		teamHistorySets[team.ID] = teamHistory
+	}
+
+	for _, rankHistory := range rankHistories {
+		teamHistory, ok := teamHistorySets[rankHistory.TeamID]
+		if !ok {
+			// The team has been deleted.
+			continue
+		}
+
+		point := &HistoryPoint{
+			Round: rankHistory.Round,
+			Rank:  rankHistory.Rank,
+			Score: rankHistory.Score,
+		}
+		if opts.ShowGameBoxScore {
+			point.GameBoxes = make([]*GameBoxHistoryPoint, 0, len(rankHistory.GameBoxes))
+			for _, gameBox := range rankHistory.GameBoxes {
+				point.GameBoxes = append(point.GameBoxes, &GameBoxHistoryPoint{
+					ChallengeID: gameBox.ChallengeID,
+					Score:       gameBox.Score,
+				})
+			}
+		}
+		teamHistory.Points = append(teamHistory.Points, point)
+	}
+
+	return teamHistories, nil
+}
@@ -11,6 +11,7 @@ import (
 	"Cardinal/internal/context"
 	"Cardinal/internal/db"
 	"Cardinal/internal/form"
+	"Cardinal/internal/misc/webhook"
 )
 
 // AuthHandler is the authenticate request handler.
@@ -70,6 +71,8 @@ func (*AuthHandler) TeamLogin(ctx context.Context, session session.Session, f fo
 	}
 
 	session.Set(teamIDSessionKey, team.ID)
+
+	webhook.Add(webhook.TEAM_LOGIN_HOOK, map[string]interface{}{"team": team.ID})
 	return ctx.Success(session.ID())
 }
 
@@ -11,6 +11,8 @@ import (
 	"Cardinal/internal/db"
 	"Cardinal/internal/form"
 	"Cardinal/internal/i18n"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/misc/webhook"
 )
 
 // BulletinHandler is the bulletin request handler.
@@ -34,7 +36,7 @@ func (*BulletinHandler) List(ctx context.Context) error {
 
 // New creates a new bulletin with the given options.
 func (*BulletinHandler) New(ctx context.Context, f form.NewBulletin) error {
-	_, err := db.Bulletins.Create(ctx.Request().Context(), db.CreateBulletinOptions{
+	id, err := db.Bulletins.Create(ctx.Request().Context(), db.CreateBulletinOptions{
 		Title: f.Title,
 		Body:  f.Body,
 	})
@@ -43,6 +45,11 @@ func (*BulletinHandler) New(ctx context.Context, f form.NewBulletin) error {
 		return ctx.ServerError()
 	}
 
+	webhook.Add(webhook.BULLETIN_HOOK, map[string]interface{}{"bulletin": id, "title": f.Title})
+	go func() {
+		_ = livelog.WriteAllTeams(livelog.NewLine("bulletin", map[string]interface{}{"ID": id, "Title": f.Title}))
+	}()
+
 	return ctx.Success()
 }
 
@@ -0,0 +1,54 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"time"
+
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/context"
+	"Cardinal/internal/db"
+)
+
+// ClockHandler is the game clock request handler.
+type ClockHandler struct{}
+
+// NewClockHandler creates and returns a new game clock handler.
+func NewClockHandler() *ClockHandler {
+	return &ClockHandler{}
+}
+
+// Rounds returns the rounds with the transition time and the duration of the score calculation.
+func (*ClockHandler) Rounds(ctx context.Context) error {
+	type round struct {
+		Round     uint       `json:"Round"`
+		StartedAt *time.Time `json:"StartedAt"`
+		EndedAt   *time.Time `json:"EndedAt"`
+		ScoredAt  *time.Time `json:"ScoredAt"`
+		// ScoringDuration is the duration of the score calculation in milliseconds.
+		ScoringDuration int64   `json:"ScoringDuration"`
+		ScoreMultiplier float64 `json:"ScoreMultiplier"`
+	}
+
+	rounds, err := db.Rounds.Get(ctx.Request().Context())
+	if err != nil {
+		log.Error("Failed to get rounds: %v", err)
+		return ctx.ServerError()
+	}
+
+	roundList := make([]*round, 0, len(rounds))
+	for _, r := range rounds {
+		roundList = append(roundList, &round{
+			Round:           r.Round,
+			StartedAt:       r.StartedAt,
+			EndedAt:         r.EndedAt,
+			ScoredAt:        r.ScoredAt,
+			ScoringDuration: r.ScoringDuration.Milliseconds(),
+			ScoreMultiplier: r.ScoreMultiplier,
+		})
+	}
+	return ctx.Success(roundList)
+}
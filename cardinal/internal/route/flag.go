@@ -62,7 +62,7 @@ func (*FlagHandler) BatchCreate(ctx context.Context) error {
 	flagPrefix := conf.Game.FlagPrefix
 	flagSuffix := conf.Game.FlagSuffix
 	salt := utils.Sha1Encode(conf.App.SecuritySalt)
-	totalRound := clock.T.TotalRound
+	totalRound := clock.T.Snapshot().TotalRound
 	log.Trace("Total Round: %d", totalRound)
 
 	flagMetadatas := make([]db.FlagMetadata, 0, int(totalRound)*len(gameBoxes))
@@ -5,11 +5,10 @@
 package route
 
 import (
-	"time"
-
 	"Cardinal/internal/clock"
 	"Cardinal/internal/conf"
 	"Cardinal/internal/context"
+	"Cardinal/internal/timeutil"
 )
 
 // GeneralHandler is the general request handler.
@@ -35,15 +34,33 @@ func (*GeneralHandler) Init(c context.Context) error {
 }
 
 func (*GeneralHandler) Time(c context.Context) error {
+	now := timeutil.Now()
+	snapshot := clock.T.Snapshot()
+
+	roundDuration := clock.T.RoundDuration
+	var phase map[string]interface{}
+	if currentPhase := snapshot.Phase; currentPhase != nil {
+		roundDuration = currentPhase.RoundDuration
+		phase = map[string]interface{}{
+			"Name":            currentPhase.Name,
+			"StartAt":         currentPhase.StartAt.Unix(),
+			"RoundDuration":   currentPhase.RoundDuration.Seconds(),
+			"ScoreMultiplier": currentPhase.ScoreMultiplier,
+			"StartRound":      currentPhase.StartRound,
+			"TotalRound":      currentPhase.TotalRound,
+		}
+	}
+
 	return c.Success(map[string]interface{}{
-		"CurrentTime":         time.Now().Unix(),
-		"StartAt":             clock.T.StartAt.Unix(),
-		"EndAt":               clock.T.EndAt.Unix(),
-		"RoundDuration":       clock.T.RoundDuration.Seconds(),
-		"CurrentRound":        clock.T.CurrentRound,
-		"RoundRemainDuration": int(clock.T.RoundRemainDuration.Seconds()),
-		"Status":              clock.T.Status,
-		"TotalRound":          clock.T.TotalRound,
+		"CurrentTime":         now.Unix(),
+		"StartAt":             snapshot.StartAt.Unix(),
+		"EndAt":               snapshot.EndAt.Unix(),
+		"RoundDuration":       roundDuration.Seconds(),
+		"CurrentRound":        snapshot.Round,
+		"RoundRemainDuration": int(snapshot.RoundRemainDuration(now).Seconds()),
+		"Status":              snapshot.Status,
+		"TotalRound":          snapshot.TotalRound,
+		"Phase":               phase,
 	})
 }
 
@@ -5,6 +5,8 @@
 package route
 
 import (
+	log "unknwon.dev/clog/v2"
+
 	"Cardinal/internal/context"
 	"Cardinal/internal/rank"
 )
@@ -28,3 +30,19 @@ func (*ManagerHandler) Logs() {
 func (*ManagerHandler) Rank(ctx context.Context) error {
 	return ctx.Success(rank.ForManager())
 }
+
+// RankHistory returns the score timeline with the game box scores of all the teams,
+// or only one team if the `teamID` query is given.
+func (*ManagerHandler) RankHistory(ctx context.Context) error {
+	history, err := rank.History(ctx.Request().Context(), rank.HistoryOptions{
+		TeamID:           uint(ctx.QueryInt("teamID")),
+		StartRound:       uint(ctx.QueryInt("startRound")),
+		EndRound:         uint(ctx.QueryInt("endRound")),
+		ShowGameBoxScore: true,
+	})
+	if err != nil {
+		log.Error("Failed to get rank history: %v", err)
+		return ctx.ServerError()
+	}
+	return ctx.Success(history)
+}
@@ -33,7 +33,7 @@ func Init() *gin.Engine {
 	// CORS Header
 	r.Use(cors.New(cors.Config{
 		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"},
-		AllowHeaders: []string{"Authorization", "Content-type", "User-Agent"},
+		AllowHeaders: []string{"Authorization", "Content-type", "User-Agent", "Last-Event-ID"},
 		AllowOrigins: []string{"*"},
 	}))
 
@@ -77,6 +77,13 @@ func Init() *gin.Engine {
 	// Live log
 	api.GET("/livelog", livelog.GlobalStreamHandler)
 
+	// Private live log of the team
+	api.GET("/team/livelog", auth.QueryToken(), auth.TeamAuthRequired(), livelog.TeamStreamHandler)
+
+	// Attack statistics
+	api.GET("/attacks/matrix", __(game.GetPublicAttackMatrix))
+	api.GET("/attacks/challenges", __(game.GetPublicChallengeStats))
+
 	// Submit flag
 	api.POST("/flag", __(game.SubmitFlag))
 
@@ -95,6 +102,7 @@ func Init() *gin.Engine {
 		teamRouter.GET("/rank", func(c *gin.Context) {
 			c.JSON(utils.MakeSuccessJSON(gin.H{"Title": game.GetRankListTitle(), "Rank": game.GetRankList()}))
 		})
+		teamRouter.GET("/rank/history", __(game.GetTeamRankHistory))
 		teamRouter.GET("/bulletins", __(bulletin.GetAllBulletins))
 	}
 
@@ -145,9 +153,19 @@ func Init() *gin.Engine {
 
 		// Flag
 		managerRouter.GET("/flags", __(game.GetFlags))
+		managerRouter.GET("/attacks/matrix", __(game.GetAttackMatrix))
+		managerRouter.GET("/attacks/challenges", __(game.GetChallengeStats))
 		managerRouter.POST("/flag/generate", __(game.GenerateFlag))
 		managerRouter.GET("/flag/export", __(game.ExportFlag))
 
+		// Clock
+		managerRouter.GET("/clock/adjustments", __(timer.GetClockAdjustments))
+		managerRouter.POST("/clock/pause", __(timer.Pause))
+		managerRouter.POST("/clock/resume", __(timer.Resume))
+		managerRouter.POST("/clock/pause/new", __(timer.AddPause))
+		managerRouter.PUT("/clock/endAt", __(timer.SetEndTime))
+		managerRouter.GET("/clock/rounds", __(timer.GetRounds))
+
 		// Asteroid Unity3D
 		managerRouter.GET("/asteroid/status", __(asteroid.GetAsteroidStatus))
 		managerRouter.POST("/asteroid/attack", __(asteroid.Attack))
@@ -158,6 +176,10 @@ func Init() *gin.Engine {
 		managerRouter.POST("/asteroid/time", __(asteroid.Time))
 		managerRouter.POST("/asteroid/clear", __(asteroid.Clear))
 		managerRouter.POST("/asteroid/clearAll", __(asteroid.ClearAll))
+		managerRouter.GET("/asteroid/replay", __(asteroid.GetReplayStatus))
+		managerRouter.POST("/asteroid/replay", __(asteroid.Replay))
+		managerRouter.POST("/asteroid/replay/stop", __(asteroid.StopReplay))
+		managerRouter.GET("/asteroid/events", __(asteroid.GetEvents))
 
 		// Check
 		check.POST("/checkDown", __(game.CheckDown))
@@ -180,13 +202,25 @@ func Init() *gin.Engine {
 		managerRouter.GET("/rank", func(c *gin.Context) {
 			c.JSON(utils.MakeSuccessJSON(gin.H{"Title": game.GetRankListTitle(), "Rank": game.GetManagerRankList()}))
 		})
+		managerRouter.GET("/rank/export", game.ExportStandings)
+		managerRouter.GET("/rank/history", __(game.GetRankHistory))
 		managerRouter.GET("/panel", __(healthy.Panel))
 
+		// Score adjustments
+		managerRouter.GET("/score/adjustments", __(game.GetScoreAdjustments))
+		managerRouter.POST("/score/adjustment", __(game.NewScoreAdjustment))
+		managerRouter.POST("/score/adjustment/revert", __(game.RevertScoreAdjustment))
+		managerRouter.GET("/score/report", __(game.GetScoreReport))
+
 		// WebHook
 		managerRouter.GET("/webhooks", __(webhook.GetWebHook))
 		managerRouter.POST("/webhook", __(webhook.NewWebHook))
 		managerRouter.PUT("/webhook", __(webhook.EditWebHook))
 		managerRouter.DELETE("/webhook", __(webhook.DeleteWebHook))
+		managerRouter.POST("/webhook/test", __(webhook.TestWebHook))
+		managerRouter.GET("/webhook/deliveries", __(webhook.GetWebHookDeliveries))
+		managerRouter.GET("/webhook/delivery", __(webhook.GetWebHookDelivery))
+		managerRouter.POST("/webhook/delivery/redeliver", __(webhook.RedeliverWebHook))
 
 		// Config
 		managerRouter.GET("/configs", __(dynamic_config.GetAllConfig))
@@ -0,0 +1,125 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"github.com/pkg/errors"
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/clock"
+	"Cardinal/internal/context"
+	"Cardinal/internal/db"
+	"Cardinal/internal/form"
+	"Cardinal/internal/i18n"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/rank"
+)
+
+// ScoreAdjustmentHandler is the score adjustment request handler.
+type ScoreAdjustmentHandler struct{}
+
+// NewScoreAdjustmentHandler creates and returns a new score adjustment handler.
+func NewScoreAdjustmentHandler() *ScoreAdjustmentHandler {
+	return &ScoreAdjustmentHandler{}
+}
+
+// List returns the score adjustments, or only one team's if the `teamID` query is given.
+func (*ScoreAdjustmentHandler) List(ctx context.Context) error {
+	scoreAdjustments, err := db.ScoreAdjustments.Get(ctx.Request().Context(), db.GetScoreAdjustmentsOptions{
+		TeamID: uint(ctx.QueryInt("teamID")),
+	})
+	if err != nil {
+		log.Error("Failed to get score adjustments: %v", err)
+		return ctx.ServerError()
+	}
+	return ctx.Success(scoreAdjustments)
+}
+
+// New awards or deducts the score of the team or the game box.
+// The adjustment will be recorded in the current round if the round is not given.
+func (*ScoreAdjustmentHandler) New(ctx context.Context, manager *db.Manager, f form.NewScoreAdjustment, l *i18n.Locale) error {
+	if f.TeamID == 0 && f.GameBoxID == 0 {
+		return ctx.Error(40000, l.T("score_adjustment.target_empty"))
+	}
+
+	round := f.Round
+	if round == 0 {
+		round = clock.T.Snapshot().Round
+	}
+
+	scoreAdjustment, err := db.ScoreAdjustments.Create(ctx.Request().Context(), db.CreateScoreAdjustmentOptions{
+		TeamID:    f.TeamID,
+		GameBoxID: f.GameBoxID,
+		Round:     round,
+		Score:     f.Score,
+		Reason:    f.Reason,
+		ManagerID: manager.ID,
+	})
+	if err != nil {
+		switch err {
+		case db.ErrTeamNotExists:
+			return ctx.Error(40400, l.T("team.not_found"))
+		case db.ErrGameBoxNotExists:
+			return ctx.Error(40400, l.T("gamebox.not_found"))
+		case db.ErrScoreAdjustmentZeroScore:
+			return ctx.Error(40000, l.T("score_adjustment.zero_score"))
+		}
+		log.Error("Failed to create score adjustment: %v", err)
+		return ctx.ServerError()
+	}
+
+	if err := refreshScore(ctx); err != nil {
+		log.Error("Failed to refresh score after adjustment: %v", err)
+		return ctx.ServerError()
+	}
+
+	if err := livelog.WriteTeam(scoreAdjustment.TeamID, livelog.NewLine("score_adjustment", map[string]interface{}{
+		"Round":  scoreAdjustment.Round,
+		"Score":  scoreAdjustment.Score,
+		"Reason": scoreAdjustment.Reason,
+	})); err != nil {
+		log.Error("Failed to write the team live log: %v", err)
+	}
+
+	return ctx.Success(scoreAdjustment)
+}
+
+// Revert reverts the score adjustment with the given id.
+func (*ScoreAdjustmentHandler) Revert(ctx context.Context, manager *db.Manager, l *i18n.Locale) error {
+	id := uint(ctx.QueryInt("id"))
+
+	err := db.ScoreAdjustments.Revert(ctx.Request().Context(), id, manager.ID)
+	if err != nil {
+		switch err {
+		case db.ErrScoreAdjustmentNotExists:
+			return ctx.Error(40400, l.T("score_adjustment.not_found"))
+		case db.ErrScoreAdjustmentReverted:
+			return ctx.Error(40000, l.T("score_adjustment.reverted"))
+		}
+		log.Error("Failed to revert score adjustment: %v", err)
+		return ctx.ServerError()
+	}
+
+	if err := refreshScore(ctx); err != nil {
+		log.Error("Failed to refresh score after reverting adjustment: %v", err)
+		return ctx.ServerError()
+	}
+
+	return ctx.Success()
+}
+
+// refreshScore recounts the game box and team score, then refreshes the ranking list.
+func refreshScore(ctx context.Context) error {
+	if err := db.Scores.RefreshGameBoxScore(ctx.Request().Context()); err != nil {
+		return errors.Wrap(err, "refresh game box score")
+	}
+	if err := db.Scores.RefreshTeamScore(ctx.Request().Context()); err != nil {
+		return errors.Wrap(err, "refresh team score")
+	}
+	if err := rank.SetRankList(ctx.Request().Context()); err != nil {
+		return errors.Wrap(err, "set rank list")
+	}
+	return nil
+}
@@ -8,15 +8,21 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/thanhpk/randstr"
 	log "unknwon.dev/clog/v2"
 
+	"Cardinal/internal/asteroid"
 	"Cardinal/internal/clock"
+	"Cardinal/internal/conf"
 	"Cardinal/internal/context"
 	"Cardinal/internal/db"
 	"Cardinal/internal/form"
 	"Cardinal/internal/i18n"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/misc/webhook"
 	"Cardinal/internal/rank"
+	"Cardinal/internal/timeutil"
 )
 
 type TeamHandler struct{}
@@ -169,7 +175,13 @@ func (*TeamHandler) ResetPassword(ctx context.Context, l *i18n.Locale) error {
 }
 
 // SubmitFlag submits a flag.
-func (*TeamHandler) SubmitFlag(ctx context.Context, team *db.Team, f form.SubmitFlag) error {
+func (*TeamHandler) SubmitFlag(ctx context.Context, team *db.Team, f form.SubmitFlag, l *i18n.Locale) error {
+	// The flag is validated against the round when it is submitted, even if a new round starts meanwhile.
+	snapshot := clock.T.Snapshot()
+	if snapshot.Status != clock.StatusRunning {
+		return ctx.Error(40300, l.T("general.not_begin"))
+	}
+
 	flagStr := f.Flag
 
 	flag, err := db.Flags.Check(ctx.Request().Context(), flagStr)
@@ -182,7 +194,7 @@ func (*TeamHandler) SubmitFlag(ctx context.Context, team *db.Team, f form.Submit
 	}
 
 	// The team can only submit the other teams' current round flag.
-	if flag.TeamID == team.ID || flag.Round != clock.T.CurrentRound {
+	if flag.TeamID == team.ID || flag.Round != snapshot.Round {
 		return ctx.Error(40000, "error flag")
 	}
 
@@ -196,9 +208,65 @@ func (*TeamHandler) SubmitFlag(ctx context.Context, team *db.Team, f form.Submit
 		return ctx.ServerError()
 	}
 
+	if conf.Game.FirstBloodScore > 0 {
+		if err := takeFirstBlood(ctx, team, flag); err != nil {
+			log.Error("Failed to take first blood: %v", err)
+		}
+	}
+
 	return ctx.Success()
 }
 
+// takeFirstBlood records the first blood if the team is the first one to capture the challenge (or the victim),
+// then announces it through the live log, webhook and asteroid.
+func takeFirstBlood(ctx context.Context, team *db.Team, flag *db.Flag) error {
+	action, err := db.Actions.CreateFirstBlood(ctx.Request().Context(), db.CreateFirstBloodOptions{
+		GameBoxID:      flag.GameBoxID,
+		AttackerTeamID: team.ID,
+		Round:          flag.Round,
+		Score:          float64(conf.Game.FirstBloodScore),
+		PerVictim:      conf.Game.FirstBloodPerVictim,
+	})
+	if err != nil {
+		if err == db.ErrFirstBloodTaken {
+			return nil
+		}
+		return errors.Wrap(err, "create first blood action")
+	}
+
+	victim, err := db.Teams.GetByID(ctx.Request().Context(), action.TeamID)
+	if err != nil {
+		return errors.Wrap(err, "get victim team")
+	}
+	challenge, err := db.Challenges.GetByID(ctx.Request().Context(), action.ChallengeID)
+	if err != nil {
+		return errors.Wrap(err, "get challenge")
+	}
+
+	webhook.Add(webhook.FIRST_BLOOD_HOOK, map[string]interface{}{
+		"from":      team.ID,
+		"to":        victim.ID,
+		"challenge": challenge.ID,
+		"gamebox":   action.GameBoxID,
+		"score":     action.Score,
+	})
+
+	// The public announcements are hidden while the ranking list is frozen.
+	if !rank.IsFrozen(timeutil.Now()) {
+		_ = livelog.Stream.Write(livelog.GlobalStream, livelog.NewLine("first_blood", map[string]interface{}{
+			"Round":     action.Round,
+			"From":      team.Name,
+			"To":        victim.Name,
+			"Challenge": challenge.Title,
+			"Score":     action.Score,
+		}))
+		asteroid.SendFirstBlood(int(team.ID), int(victim.ID), challenge.Title)
+	}
+
+	// Refresh the ranking list to show the first blood on the scoreboard.
+	return rank.SetRankList(ctx.Request().Context())
+}
+
 func (*TeamHandler) Info(ctx context.Context, team *db.Team) error {
 	return ctx.Success(team)
 }
@@ -215,6 +283,18 @@ func (*TeamHandler) GameBoxes(ctx context.Context, team *db.Team) error {
 	return ctx.Success(gameBoxes)
 }
 
+// ScoreAdjustments returns the score adjustments of the team, including the reverted ones.
+func (*TeamHandler) ScoreAdjustments(ctx context.Context, team *db.Team) error {
+	scoreAdjustments, err := db.ScoreAdjustments.Get(ctx.Request().Context(), db.GetScoreAdjustmentsOptions{
+		TeamID: team.ID,
+	})
+	if err != nil {
+		log.Error("Failed to get team score adjustments: %v", err)
+		return ctx.ServerError()
+	}
+	return ctx.Success(scoreAdjustments)
+}
+
 func (*TeamHandler) Bulletins(ctx context.Context) error {
 	bulletins, err := db.Bulletins.Get(ctx.Request().Context())
 	if err != nil {
@@ -230,3 +310,18 @@ func (*TeamHandler) Rank(ctx context.Context) error {
 		"Rank":  rank.ForTeam(),
 	})
 }
+
+// RankHistory returns the score timeline of all the teams, or only one team if the `teamID` query is given.
+func (*TeamHandler) RankHistory(ctx context.Context) error {
+	history, err := rank.History(ctx.Request().Context(), rank.HistoryOptions{
+		TeamID:     uint(ctx.QueryInt("teamID")),
+		StartRound: uint(ctx.QueryInt("startRound")),
+		EndRound:   uint(ctx.QueryInt("endRound")),
+		Public:     true,
+	})
+	if err != nil {
+		log.Error("Failed to get rank history: %v", err)
+		return ctx.ServerError()
+	}
+	return ctx.Success(history)
+}
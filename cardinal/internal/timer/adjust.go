@@ -0,0 +1,310 @@
+package timer
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/logger"
+	"Cardinal/internal/timeutil"
+	"Cardinal/internal/utils"
+)
+
+const (
+	pauseAdjustment = "pause"
+	endAtAdjustment = "end_at"
+)
+
+// errPaused is returned when the game has been paused by the manager.
+var errPaused = errors.New("game has been paused")
+
+// reload loads the clock adjustments from database, and recalculates the time cycle and total round.
+// The previous time cycle is kept if the adjustments can not be loaded.
+func reload() error {
+	var adjustments []dbold.ClockAdjustment
+	if err := dbold.MySQL.Model(&dbold.ClockAdjustment{}).Order("id").Find(&adjustments).Error; err != nil {
+		return err
+	}
+
+	endTime := t.configEndTime
+	restTime := make([][]time.Time, 0, len(t.configRestTime)+len(adjustments))
+	for _, dur := range t.configRestTime {
+		restTime = append(restTime, []time.Time{dur[0], dur[1]})
+	}
+
+	var pauses []dbold.ClockAdjustment
+	for _, adjustment := range adjustments {
+		switch adjustment.Type {
+		case endAtAdjustment:
+			// The latest end time takes effect.
+			endTime = adjustment.EndAt.In(time.Local)
+		case pauseAdjustment:
+			pauses = append(pauses, adjustment)
+		}
+	}
+	for _, pause := range pauses {
+		// The pause without end time lasts until the game is over.
+		pauseEndTime := endTime
+		if pause.EndAt != nil {
+			pauseEndTime = pause.EndAt.In(time.Local)
+		}
+		restTime = append(restTime, []time.Time{pause.StartAt.In(time.Local), pauseEndTime})
+	}
+
+	// Cut the rest time which is out of the game time, for the end time may be changed.
+	validRestTime := make([][]time.Time, 0, len(restTime))
+	for _, dur := range restTime {
+		if dur[0].Before(t.BeginTime) {
+			dur[0] = t.BeginTime
+		}
+		if dur[1].After(endTime) {
+			dur[1] = endTime
+		}
+		if dur[0].Before(dur[1]) {
+			validRestTime = append(validRestTime, dur)
+		}
+	}
+	restTime = combineRestTime(validRestTime)
+
+	// Set the competition time cycle.
+	var runTime [][]time.Time
+	if len(restTime) != 0 {
+		runTime = append(runTime, []time.Time{t.BeginTime, restTime[0][0]})
+		for i := 0; i < len(restTime)-1; i++ {
+			runTime = append(runTime, []time.Time{restTime[i][1], restTime[i+1][0]})
+		}
+		runTime = append(runTime, []time.Time{restTime[len(restTime)-1][1], endTime})
+
+	} else {
+		runTime = append(runTime, []time.Time{t.BeginTime, endTime})
+	}
+
+	// Calculate the rounds of the phases and the total round.
+	phases := schedulePhases(t.configPhases, runTime)
+	var totalRound int
+	for _, phase := range phases {
+		totalRound += phase.TotalRound
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.EndTime = endTime
+	t.RestTime = restTime
+	t.RunTime = runTime
+	t.TotalRound = totalRound
+	t.Phases = phases
+
+	// Wake up the timer process to reschedule with the new time.
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// combineRestTime sorts the rest time by the start time, and combines the overlapped ones.
+func combineRestTime(restTime [][]time.Time) [][]time.Time {
+	sort.Slice(restTime, func(i, j int) bool { return restTime[i][0].Before(restTime[j][0]) })
+
+	combined := make([][]time.Time, 0, len(restTime))
+	for _, dur := range restTime {
+		last := len(combined) - 1
+		if last >= 0 && !dur[0].After(combined[last][1]) {
+			if dur[1].After(combined[last][1]) {
+				combined[last][1] = dur[1]
+			}
+			continue
+		}
+		combined = append(combined, []time.Time{dur[0], dur[1]})
+	}
+	return combined
+}
+
+// GetClockAdjustments is the HTTP handler used to return the game time changed by the managers.
+func GetClockAdjustments(c *gin.Context) (int, interface{}) {
+	var adjustments []dbold.ClockAdjustment
+	dbold.MySQL.Model(&dbold.ClockAdjustment{}).Order("id").Find(&adjustments)
+	return utils.MakeSuccessJSON(adjustments)
+}
+
+// Pause is the HTTP handler used to pause the game from now until it is resumed.
+func Pause(c *gin.Context) (int, interface{}) {
+	if GetSnapshot().Status != "on" {
+		return utils.MakeErrJSON(400, 40048,
+			locales.I18n.T(c.GetString("lang"), "timer.not_running"),
+		)
+	}
+
+	// The open pause is checked and created in one transaction, so only one of the concurrent requests pauses the game.
+	manager := c.MustGet("managerData").(dbold.Manager)
+	if err := dbold.MySQL.Transaction(func(tx *gorm.DB) error {
+		// Lock the pauses, the other requests wait until the pause is created.
+		var openPauses []dbold.ClockAdjustment
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Model(&dbold.ClockAdjustment{}).
+			Where("type = ? AND end_at IS NULL", pauseAdjustment).Find(&openPauses).Error; err != nil {
+			return err
+		}
+		if len(openPauses) != 0 {
+			return errPaused
+		}
+
+		return tx.Create(&dbold.ClockAdjustment{
+			Type:      pauseAdjustment,
+			StartAt:   timeutil.Now(),
+			ManagerID: manager.ID,
+		}).Error
+	}); err != nil {
+		if err == errPaused {
+			return utils.MakeErrJSON(400, 40049,
+				locales.I18n.T(c.GetString("lang"), "timer.paused"),
+			)
+		}
+		return utils.MakeErrJSON(500, 50031,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	if err := reload(); err != nil {
+		log.Error("Failed to reload the clock adjustments: %v", err)
+		return utils.MakeErrJSON(500, 50040,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+
+	logger.New(logger.NORMAL, "manager_operate",
+		string(locales.I18n.T(c.GetString("lang"), "timer.paused")),
+	)
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "general.success"))
+}
+
+// Resume is the HTTP handler used to resume the game paused by the manager from now.
+func Resume(c *gin.Context) (int, interface{}) {
+	manager := c.MustGet("managerData").(dbold.Manager)
+	if dbold.MySQL.Model(&dbold.ClockAdjustment{}).Where("type = ? AND end_at IS NULL", pauseAdjustment).Updates(map[string]interface{}{
+		"end_at":             timeutil.Now(),
+		"resumed_manager_id": manager.ID,
+	}).RowsAffected == 0 {
+		return utils.MakeErrJSON(400, 40050,
+			locales.I18n.T(c.GetString("lang"), "timer.not_paused"),
+		)
+	}
+	if err := reload(); err != nil {
+		log.Error("Failed to reload the clock adjustments: %v", err)
+		return utils.MakeErrJSON(500, 50041,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+
+	logger.New(logger.NORMAL, "manager_operate",
+		string(locales.I18n.T(c.GetString("lang"), "timer.resumed")),
+	)
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "general.success"))
+}
+
+// AddPause is the HTTP handler used to add a pause window of the game.
+// The pause can only be added in the future, so the rounds which have passed will never be changed.
+func AddPause(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		StartAt int64 `binding:"required"`
+		EndAt   int64 `binding:"required"`
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40051,
+			locales.I18n.T(c.GetString("lang"), "general.error_payload"),
+		)
+	}
+
+	if inputForm.StartAt < timeutil.Now().Unix() {
+		return utils.MakeErrJSON(400, 40052,
+			locales.I18n.T(c.GetString("lang"), "timer.pause_in_past"),
+		)
+	}
+	if inputForm.StartAt >= inputForm.EndAt {
+		return utils.MakeErrJSON(400, 40053,
+			locales.I18n.T(c.GetString("lang"), "timer.pause_order_error"),
+		)
+	}
+	t.mu.RLock()
+	beginTime, endTime := t.BeginTime.Unix(), t.EndTime.Unix()
+	t.mu.RUnlock()
+	if inputForm.StartAt < beginTime || inputForm.EndAt > endTime {
+		return utils.MakeErrJSON(400, 40054,
+			locales.I18n.T(c.GetString("lang"), "timer.pause_overflow_error"),
+		)
+	}
+
+	manager := c.MustGet("managerData").(dbold.Manager)
+	pauseEndTime := time.Unix(inputForm.EndAt, 0)
+	if dbold.MySQL.Create(&dbold.ClockAdjustment{
+		Type:      pauseAdjustment,
+		StartAt:   time.Unix(inputForm.StartAt, 0),
+		EndAt:     &pauseEndTime,
+		ManagerID: manager.ID,
+	}).RowsAffected != 1 {
+		return utils.MakeErrJSON(500, 50032,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	if err := reload(); err != nil {
+		log.Error("Failed to reload the clock adjustments: %v", err)
+		return utils.MakeErrJSON(500, 50042,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "general.success"))
+}
+
+// SetEndTime is the HTTP handler used to extend or shorten the game end time before the game is over.
+func SetEndTime(c *gin.Context) (int, interface{}) {
+	var inputForm struct {
+		EndAt int64 `binding:"required"`
+	}
+	if err := c.BindJSON(&inputForm); err != nil {
+		return utils.MakeErrJSON(400, 40055,
+			locales.I18n.T(c.GetString("lang"), "general.error_payload"),
+		)
+	}
+
+	if GetSnapshot().Status == "end" {
+		return utils.MakeErrJSON(400, 40056,
+			locales.I18n.T(c.GetString("lang"), "timer.end"),
+		)
+	}
+	if inputForm.EndAt < timeutil.Now().Unix() {
+		return utils.MakeErrJSON(400, 40057,
+			locales.I18n.T(c.GetString("lang"), "timer.end_time_in_past"),
+		)
+	}
+	if inputForm.EndAt < t.BeginTime.Unix() {
+		return utils.MakeErrJSON(400, 40058,
+			locales.I18n.T(c.GetString("lang"), "timer.start_time_error"),
+		)
+	}
+
+	manager := c.MustGet("managerData").(dbold.Manager)
+	endTime := time.Unix(inputForm.EndAt, 0)
+	if dbold.MySQL.Create(&dbold.ClockAdjustment{
+		Type:      endAtAdjustment,
+		EndAt:     &endTime,
+		ManagerID: manager.ID,
+	}).RowsAffected != 1 {
+		return utils.MakeErrJSON(500, 50033,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+	if err := reload(); err != nil {
+		log.Error("Failed to reload the clock adjustments: %v", err)
+		return utils.MakeErrJSON(500, 50043,
+			locales.I18n.T(c.GetString("lang"), "general.server_error"),
+		)
+	}
+
+	return utils.MakeSuccessJSON(locales.I18n.T(c.GetString("lang"), "general.success"))
+}
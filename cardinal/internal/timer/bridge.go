@@ -3,6 +3,5 @@ package timer
 var SetRankListTitle func()
 var SetRankList func()
 var CleanGameBoxStatus func()
-var GetLatestScoreRound func() int
 var RefreshFlag func()
-var CalculateRoundScore func(int)
+var CalculateRoundScore func(int) error
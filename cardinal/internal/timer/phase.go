@@ -0,0 +1,173 @@
+package timer
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/conf"
+	"Cardinal/internal/locales"
+)
+
+// Phase is a part of the game schedule which has its own round duration and score multiplier.
+// The phase lasts until the next phase starts, the last round of the phase ends when the phase ends.
+type Phase struct {
+	Name            string
+	StartTime       time.Time
+	Duration        uint // The round duration in minutes.
+	ScoreMultiplier float64
+
+	// StartRound and TotalRound are the rounds of the phase calculated from the run time.
+	StartRound int
+	TotalRound int
+
+	// workStart and workEnd are the cumulative run time in seconds when the phase starts and ends.
+	workStart int64
+	workEnd   int64
+}
+
+// configPhases returns the phases from the configuration file.
+// The phase with the game round duration is added if the first phase starts after the game starts.
+func configPhases() []*Phase {
+	phases := make([]*Phase, 0, len(conf.Game.Phases)+1)
+	for _, phase := range conf.Game.Phases {
+		duration := phase.RoundDuration
+		if duration == 0 {
+			duration = t.Duration
+		}
+		scoreMultiplier := phase.ScoreMultiplier
+		if scoreMultiplier == 0 {
+			scoreMultiplier = 1
+		}
+
+		phases = append(phases, &Phase{
+			Name:            phase.Name,
+			StartTime:       phase.StartAt.In(time.Local),
+			Duration:        duration,
+			ScoreMultiplier: scoreMultiplier,
+		})
+	}
+
+	if len(phases) == 0 || phases[0].StartTime.After(t.BeginTime) {
+		phases = append([]*Phase{{
+			StartTime:       t.BeginTime,
+			Duration:        t.Duration,
+			ScoreMultiplier: 1,
+		}}, phases...)
+	}
+	return phases
+}
+
+func checkPhaseConfig() {
+	for key, phase := range t.configPhases {
+		if phase.ScoreMultiplier < 0 {
+			log.Fatal(locales.T("timer.phase_score_multiplier_error", gin.H{"name": phase.Name}))
+		}
+		if phase.StartTime.Before(t.BeginTime) || !phase.StartTime.Before(t.EndTime) {
+			log.Fatal(locales.T("timer.phase_overflow_error", gin.H{"name": phase.Name}))
+		}
+		// Phases should in order.
+		if key != 0 && !phase.StartTime.After(t.configPhases[key-1].StartTime) {
+			log.Fatal(locales.T("timer.phase_order_error", gin.H{"name": phase.Name}))
+		}
+	}
+}
+
+// workTimeAt returns the cumulative run time in seconds at the given time.
+func workTimeAt(runTime [][]time.Time, at time.Time) int64 {
+	var workTime int64
+	for _, dur := range runTime {
+		if !at.After(dur[0]) {
+			break
+		}
+		if at.Before(dur[1]) {
+			return workTime + at.Unix() - dur[0].Unix()
+		}
+		workTime += dur[1].Unix() - dur[0].Unix()
+	}
+	return workTime
+}
+
+// schedulePhases calculates the run time and the rounds of the phases with the given run time cycles.
+// The phase which starts after the game is over has no round.
+func schedulePhases(configPhases []*Phase, runTime [][]time.Time) []*Phase {
+	totalWorkTime := workTimeAt(runTime, runTime[len(runTime)-1][1])
+
+	phases := make([]*Phase, 0, len(configPhases))
+	startRound := 1
+	for key, configPhase := range configPhases {
+		phase := *configPhase
+		phase.workStart = workTimeAt(runTime, phase.StartTime)
+		phase.workEnd = totalWorkTime
+		if key != len(configPhases)-1 {
+			phase.workEnd = workTimeAt(runTime, configPhases[key+1].StartTime)
+		}
+
+		roundTime := int64(phase.Duration) * 60
+		phase.StartRound = startRound
+		phase.TotalRound = int((phase.workEnd - phase.workStart + roundTime - 1) / roundTime)
+		startRound += phase.TotalRound
+
+		phases = append(phases, &phase)
+	}
+	return phases
+}
+
+// roundAt returns the round, the time to the next round in seconds and the phase of the round at the given work time.
+// The round which ends at the work time is returned when the game is paused, or the round starts at it is returned.
+// It returns nil phase if there is no round in the game.
+func roundAt(phases []*Phase, workTime int64, paused bool) (int, int, *Phase) {
+	var current *Phase
+	for _, phase := range phases {
+		if phase.TotalRound == 0 {
+			continue
+		}
+		if current == nil ||
+			(!paused && phase.workStart <= workTime) ||
+			(paused && phase.workStart < workTime) {
+			current = phase
+		}
+	}
+	if current == nil {
+		return 0, 0, nil
+	}
+
+	roundTime := int64(current.Duration) * 60
+	elapsed := workTime - current.workStart
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	index := elapsed / roundTime
+	if paused && elapsed != 0 && elapsed%roundTime == 0 {
+		index--
+	}
+	if index >= int64(current.TotalRound) {
+		index = int64(current.TotalRound) - 1
+	}
+
+	roundEnd := current.workStart + (index+1)*roundTime
+	if roundEnd > current.workEnd {
+		roundEnd = current.workEnd
+	}
+	return current.StartRound + int(index), int(roundEnd - workTime), current
+}
+
+// roundWorkTime returns the cumulative run time in seconds when the given round starts and ends,
+// and the phase of the round. It returns nil phase if the round does not exist.
+func roundWorkTime(phases []*Phase, round int) (int64, int64, *Phase) {
+	for _, phase := range phases {
+		if round < phase.StartRound || round >= phase.StartRound+phase.TotalRound {
+			continue
+		}
+
+		roundTime := int64(phase.Duration) * 60
+		start := phase.workStart + int64(round-phase.StartRound)*roundTime
+		end := start + roundTime
+		if end > phase.workEnd {
+			end = phase.workEnd
+		}
+		return start, end, phase
+	}
+	return 0, 0, nil
+}
@@ -0,0 +1,116 @@
+package timer
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/dbold"
+	"Cardinal/internal/utils"
+)
+
+// runningTimeAt returns the moment when the cumulative run time reaches the given seconds.
+// If the moment is at the end of a run time cycle, the end of the cycle is returned when `end` is true,
+// otherwise the start of the next cycle is returned.
+func runningTimeAt(runTime [][]time.Time, seconds int64, end bool) time.Time {
+	for _, dur := range runTime {
+		length := dur[1].Unix() - dur[0].Unix()
+		if seconds < length || (end && seconds == length) {
+			return dur[0].Add(time.Duration(seconds) * time.Second)
+		}
+		seconds -= length
+	}
+	return runTime[len(runTime)-1][1]
+}
+
+// roundTime returns the start and end time of the given round, and the phase of the round.
+// The round out of the phases, which may be cut off by the end time, starts and ends when the game is over.
+func roundTime(runTime [][]time.Time, phases []*Phase, round int) (time.Time, time.Time, *Phase) {
+	start, end, phase := roundWorkTime(phases, round)
+	if phase == nil {
+		endTime := runTime[len(runTime)-1][1]
+		return endTime, endTime, nil
+	}
+	return runningTimeAt(runTime, start, false), runningTimeAt(runTime, end, true), phase
+}
+
+// recordRoundTime sets the time column of the round if it has not been set, the round is created if not exists.
+func recordRoundTime(round int, column string, at time.Time) {
+	dbold.MySQL.Where(dbold.Round{Round: round}).FirstOrCreate(&dbold.Round{})
+	dbold.MySQL.Model(&dbold.Round{}).Where("round = ? AND "+column+" IS NULL", round).Update(column, at)
+}
+
+// startRound records the start time and the score multiplier of the round's phase,
+// the score of the round is multiplied by it even if the phases are changed later.
+func startRound(round int, runTime [][]time.Time, phases []*Phase) {
+	startTime, _, phase := roundTime(runTime, phases, round)
+	recordRoundTime(round, "started_at", startTime)
+
+	scoreMultiplier := 1.0
+	if phase != nil {
+		scoreMultiplier = phase.ScoreMultiplier
+	}
+	dbold.MySQL.Model(&dbold.Round{}).Where("round = ? AND score_multiplier = 0", round).Update("score_multiplier", scoreMultiplier)
+}
+
+// recordRounds records the start and end time of the rounds until the given round has ended,
+// the rounds missed when Cardinal was down are recorded as well.
+func recordRounds(endedRound int, runTime [][]time.Time, phases []*Phase) {
+	var latestRound dbold.Round
+	dbold.MySQL.Model(&dbold.Round{}).Where("ended_at IS NOT NULL").Order("round DESC").Limit(1).Find(&latestRound)
+
+	for round := latestRound.Round + 1; round <= endedRound; round++ {
+		startRound(round, runTime, phases)
+		_, endTime, _ := roundTime(runTime, phases, round)
+		recordRoundTime(round, "ended_at", endTime)
+	}
+}
+
+// scoreRounds calculates the score of the rounds which have ended but not been scored in order,
+// so that no round is skipped even if Cardinal has been down for several rounds.
+func scoreRounds() {
+	var rounds []dbold.Round
+	dbold.MySQL.Model(&dbold.Round{}).Where("ended_at IS NOT NULL AND scored_at IS NULL").Order("round ASC").Find(&rounds)
+	if len(rounds) == 0 {
+		return
+	}
+
+	for _, round := range rounds {
+		// The scored marker is set with the score in one transaction, so the failed round is scored again later.
+		if err := CalculateRoundScore(round.Round); err != nil {
+			log.Error("Failed to calculate the score of round %d: %v", round.Round, err)
+			// The later rounds are not scored until this one succeeds, for the rounds are scored in order.
+			return
+		}
+	}
+}
+
+// GetRounds is the HTTP handler used to return the rounds with the transition time and the duration of the score calculation.
+func GetRounds(c *gin.Context) (int, interface{}) {
+	type round struct {
+		Round           int
+		ScoreMultiplier float64
+		StartedAt       *time.Time
+		EndedAt         *time.Time
+		ScoredAt        *time.Time
+		// ScoringDuration is the duration of the score calculation in milliseconds.
+		ScoringDuration int64
+	}
+
+	var rounds []dbold.Round
+	dbold.MySQL.Model(&dbold.Round{}).Order("round ASC").Find(&rounds)
+
+	roundList := make([]*round, 0, len(rounds))
+	for _, r := range rounds {
+		roundList = append(roundList, &round{
+			Round:           r.Round,
+			ScoreMultiplier: r.ScoreMultiplier,
+			StartedAt:       r.StartedAt,
+			EndedAt:         r.EndedAt,
+			ScoredAt:        r.ScoredAt,
+			ScoringDuration: r.ScoringDuration.Milliseconds(),
+		})
+	}
+	return utils.MakeSuccessJSON(roundList)
+}
@@ -0,0 +1,89 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_roundTime(t *testing.T) {
+	date := func(hour, min int) time.Time {
+		return time.Date(2021, 10, 3, hour, min, 0, 0, time.Local)
+	}
+	// 8:00 - 10:00, rest, 11:00 - 12:30
+	runTime := [][]time.Time{
+		{date(8, 0), date(10, 0)},
+		{date(11, 0), date(12, 30)},
+	}
+	// The rounds are 30 minutes with double score from 11:30.
+	phases := schedulePhases([]*Phase{
+		{StartTime: date(8, 0), Duration: 60, ScoreMultiplier: 1},
+		{Name: "Final", StartTime: date(11, 30), Duration: 30, ScoreMultiplier: 2},
+	}, runTime)
+
+	for _, tc := range []struct {
+		round           int
+		wantStart       time.Time
+		wantEnd         time.Time
+		wantMultiplier  float64
+		wantOutOfPhases bool
+	}{
+		{round: 1, wantStart: date(8, 0), wantEnd: date(9, 0), wantMultiplier: 1},
+		// The round ends when the rest time starts.
+		{round: 2, wantStart: date(9, 0), wantEnd: date(10, 0), wantMultiplier: 1},
+		// The next round starts when the rest time ends, and is cut off by the next phase.
+		{round: 3, wantStart: date(11, 0), wantEnd: date(11, 30), wantMultiplier: 1},
+		{round: 4, wantStart: date(11, 30), wantEnd: date(12, 0), wantMultiplier: 2},
+		{round: 5, wantStart: date(12, 0), wantEnd: date(12, 30), wantMultiplier: 2},
+		{round: 6, wantStart: date(12, 30), wantEnd: date(12, 30), wantOutOfPhases: true},
+	} {
+		start, end, phase := roundTime(runTime, phases, tc.round)
+		assert.Equal(t, tc.wantStart, start, "round %d", tc.round)
+		assert.Equal(t, tc.wantEnd, end, "round %d", tc.round)
+		if tc.wantOutOfPhases {
+			assert.Nil(t, phase, "round %d", tc.round)
+		} else {
+			assert.Equal(t, tc.wantMultiplier, phase.ScoreMultiplier, "round %d", tc.round)
+		}
+	}
+}
+
+func Test_roundAt(t *testing.T) {
+	date := func(hour, min int) time.Time {
+		return time.Date(2021, 10, 3, hour, min, 0, 0, time.Local)
+	}
+	runTime := [][]time.Time{{date(8, 0), date(11, 0)}}
+	phases := schedulePhases([]*Phase{
+		{StartTime: date(8, 0), Duration: 60, ScoreMultiplier: 1},
+		{Name: "Final", StartTime: date(10, 0), Duration: 30, ScoreMultiplier: 2},
+	}, runTime)
+
+	for _, tc := range []struct {
+		workTime       int64
+		paused         bool
+		wantRound      int
+		wantRemainTime int
+		wantPhaseName  string
+	}{
+		{workTime: 0, wantRound: 1, wantRemainTime: 3600},
+		{workTime: 1, wantRound: 1, wantRemainTime: 3599},
+		// The next round starts when the work time reaches the end of the round.
+		{workTime: 3600, wantRound: 2, wantRemainTime: 3600},
+		// The round ends when the game is paused at the end of it.
+		{workTime: 3600, paused: true, wantRound: 1, wantRemainTime: 0},
+		{workTime: 3601, paused: true, wantRound: 2, wantRemainTime: 3599},
+		{workTime: 7199, wantRound: 2, wantRemainTime: 1},
+		// The next phase starts.
+		{workTime: 7200, wantRound: 3, wantRemainTime: 1800, wantPhaseName: "Final"},
+		{workTime: 7200, paused: true, wantRound: 2, wantRemainTime: 0},
+		{workTime: 9000, paused: true, wantRound: 3, wantRemainTime: 0, wantPhaseName: "Final"},
+		{workTime: 10799, wantRound: 4, wantRemainTime: 1, wantPhaseName: "Final"},
+		{workTime: 10800, paused: true, wantRound: 4, wantRemainTime: 0, wantPhaseName: "Final"},
+	} {
+		round, remainTime, phase := roundAt(phases, tc.workTime, tc.paused)
+		assert.Equal(t, tc.wantRound, round, "work time %d", tc.workTime)
+		assert.Equal(t, tc.wantRemainTime, remainTime, "work time %d", tc.workTime)
+		assert.Equal(t, tc.wantPhaseName, phase.Name, "work time %d", tc.workTime)
+	}
+}
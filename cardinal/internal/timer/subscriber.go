@@ -0,0 +1,87 @@
+package timer
+
+import (
+	"context"
+
+	log "unknwon.dev/clog/v2"
+
+	"Cardinal/internal/asteroid"
+	"Cardinal/internal/event"
+	"Cardinal/internal/livelog"
+	"Cardinal/internal/locales"
+	"Cardinal/internal/logger"
+	"Cardinal/internal/misc/webhook"
+)
+
+// subscribe registers the subsystems which are driven by the timer to the event bus.
+// The handlers of the same event are called in the order below.
+func subscribe() {
+	// Record the round transitions before the score calculation,
+	// so the round which has ended but not been scored can be recovered when Cardinal restarts.
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		t.mu.RLock()
+		runTime, phases := t.RunTime, t.Phases
+		t.mu.RUnlock()
+
+		switch e.Type {
+		case event.RoundStart:
+			startRound(int(e.Round), runTime, phases)
+		case event.RoundEnd:
+			recordRounds(int(e.Round), runTime, phases)
+		}
+	}, event.RoundStart, event.RoundEnd)
+
+	// Clean the status of the game boxes and refresh the ranking list when the new round starts.
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		CleanGameBoxStatus()
+		SetRankList()
+	}, event.RoundStart)
+
+	// Auto refresh flag
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		RefreshFlag()
+	}, event.RoundStart)
+
+	// Calculate the score of every round which has ended but not been scored.
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		scoreRounds()
+	}, event.RoundEnd)
+
+	// WebHook
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		switch e.Type {
+		case event.GameStart:
+			webhook.Add(webhook.BEGIN_HOOK, nil)
+		case event.RoundStart:
+			webhook.Add(webhook.NEW_ROUND_HOOK, int(e.Round))
+		case event.Pause:
+			webhook.Add(webhook.PAUSE_HOOK, nil)
+		case event.GameEnd:
+			webhook.Add(webhook.END_HOOK, nil)
+		}
+	}, event.GameStart, event.RoundStart, event.Pause, event.GameEnd)
+
+	// Asteroid Unity3D refresh.
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		asteroid.NewRoundAction()
+	}, event.RoundStart)
+
+	// Live log
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		if livelog.Stream == nil {
+			return
+		}
+		_ = livelog.Stream.Write(livelog.GlobalStream, livelog.NewLine(string(e.Type), map[string]interface{}{
+			"Round": e.Round,
+		}))
+	}, event.GameStart, event.RoundStart, event.RoundEnd, event.Pause, event.Resume, event.GameEnd)
+
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		switch e.Type {
+		case event.RoundStart:
+			log.Trace("New round: %d", e.Round)
+		case event.GameEnd:
+			logger.New(logger.IMPORTANT, "system", locales.T("timer.end"))
+		}
+	}, event.RoundStart, event.GameEnd)
+}
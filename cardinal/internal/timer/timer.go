@@ -1,33 +1,65 @@
 package timer
 
 import (
-	"math"
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	log "unknwon.dev/clog/v2"
 
-	"Cardinal/internal/asteroid"
 	"Cardinal/internal/conf"
+	"Cardinal/internal/event"
 	"Cardinal/internal/locales"
-	"Cardinal/internal/logger"
-	"Cardinal/internal/misc/webhook"
+	"Cardinal/internal/timeutil"
 	"Cardinal/internal/utils"
 )
 
 var t = new(timer)
 
+// Events is the event bus of the timer.
+var Events = event.NewBus()
+
+var subscribeOnce sync.Once
+
 // timer is the time data struct of the Cardinal.
 type timer struct {
-	BeginTime       time.Time     // init
-	EndTime         time.Time     // init
-	Duration        uint          // init
-	RestTime        [][]time.Time // init
-	RunTime         [][]time.Time // init
-	TotalRound      int           // init
+	BeginTime  time.Time     // init
+	EndTime    time.Time     // init
+	Duration   uint          // init
+	RestTime   [][]time.Time // init
+	RunTime    [][]time.Time // init
+	TotalRound int           // init
+	Phases     []*Phase      // init
+
+	// wake wakes up the timer process when the time is changed by the manager.
+	wake chan struct{}
+
+	// snapshot is the latest *Snapshot published by the timer process.
+	snapshot atomic.Value
+
+	// mu protects the time fields which may be changed by the manager at runtime.
+	mu sync.RWMutex
+	// configEndTime and configRestTime are the time from the configuration file,
+	// the clock adjustments are applied to them.
+	configEndTime  time.Time
+	configRestTime [][]time.Time
+	configPhases   []*Phase
+}
+
+// Snapshot is the state of the timer at a moment, it is immutable once published.
+// The readers should get the snapshot once and use it for the whole request,
+// so that the round and the status they see are consistent.
+type Snapshot struct {
+	BeginTime       time.Time
+	EndTime         time.Time
+	Duration        uint // The round duration of the current phase.
+	TotalRound      int
 	NowRound        int
 	RoundRemainTime int
 	Status          string
+	Phase           *Phase // The phase of the current round, it is nil before the game starts.
 }
 
 // Get returns the timer.
@@ -35,16 +67,44 @@ func Get() *timer {
 	return t
 }
 
+// GetSnapshot returns the latest snapshot of the timer.
+func GetSnapshot() *Snapshot {
+	if snapshot, ok := t.snapshot.Load().(*Snapshot); ok {
+		return snapshot
+	}
+	return &Snapshot{NowRound: -1, RoundRemainTime: -1, Status: "wait"}
+}
+
+// SetSnapshot publishes the given snapshot, it is used to mock the game state in tests.
+func SetSnapshot(snapshot *Snapshot) {
+	t.snapshot.Store(snapshot)
+}
+
 // GetTime is the HTTP Handler of the time.
 func GetTime(c *gin.Context) (int, interface{}) {
+	snapshot := GetSnapshot()
+
+	var phase gin.H
+	if snapshot.Phase != nil {
+		phase = gin.H{
+			"Name":            snapshot.Phase.Name,
+			"StartTime":       snapshot.Phase.StartTime.Unix(),
+			"Duration":        snapshot.Phase.Duration,
+			"ScoreMultiplier": snapshot.Phase.ScoreMultiplier,
+			"StartRound":      snapshot.Phase.StartRound,
+			"TotalRound":      snapshot.Phase.TotalRound,
+		}
+	}
+
 	return utils.MakeSuccessJSON(gin.H{
-		"BeginTime":       t.BeginTime.Unix(),
-		"EndTime":         t.EndTime.Unix(),
-		"Duration":        t.Duration,
-		"NowRound":        t.NowRound,
-		"NowTime":         time.Now().Unix(),
-		"RoundRemainTime": t.RoundRemainTime,
-		"Status":          t.Status,
+		"BeginTime":       snapshot.BeginTime.Unix(),
+		"EndTime":         snapshot.EndTime.Unix(),
+		"Duration":        snapshot.Duration,
+		"NowRound":        snapshot.NowRound,
+		"NowTime":         timeutil.Now().Unix(),
+		"RoundRemainTime": snapshot.RoundRemainTime,
+		"Status":          snapshot.Status,
+		"Phase":           phase,
 	})
 }
 
@@ -53,7 +113,6 @@ func Init() {
 	if SetRankListTitle == nil ||
 		SetRankList == nil ||
 		CleanGameBoxStatus == nil ||
-		GetLatestScoreRound == nil ||
 		RefreshFlag == nil ||
 		CalculateRoundScore == nil {
 
@@ -70,159 +129,230 @@ func Init() {
 		EndTime:   conf.Game.EndAt.In(time.Local),
 		Duration:  conf.Game.RoundDuration,
 		RestTime:  restTime,
-		NowRound:  -1,
+		wake:      make(chan struct{}, 1),
 	}
 	checkTimeConfig()
 
-	// Calculate the rest time cycle.
-	for i := 0; i < len(t.RestTime)-1; i++ {
-		j := i + 1
-		if t.RestTime[i][1].Unix() >= t.RestTime[j][0].Unix() {
-			if t.RestTime[i][1].Unix() >= t.RestTime[j][1].Unix() {
-				t.RestTime[j] = t.RestTime[i]
-			} else {
-				t.RestTime[j][0] = t.RestTime[i][0]
-			}
-			t.RestTime[i] = nil
-		} else {
-			i++
-		}
-	}
+	t.configPhases = configPhases()
+	checkPhaseConfig()
 
-	// Remove the empty element.
-	for i := 0; i < len(t.RestTime); i++ {
-		if t.RestTime[i] == nil {
-			t.RestTime = append(t.RestTime[:i], t.RestTime[i+1:]...)
-			i++
-		}
-	}
+	// Calculate the rest time cycle.
+	t.RestTime = combineRestTime(t.RestTime)
 
-	// Set the competition time cycle.
-	if len(t.RestTime) != 0 {
-		t.RunTime = append(t.RunTime, []time.Time{t.BeginTime, t.RestTime[0][0]})
-		for i := 0; i < len(t.RestTime)-1; i++ {
-			t.RunTime = append(t.RunTime, []time.Time{t.RestTime[i][1], t.RestTime[i+1][0]})
-		}
-		t.RunTime = append(t.RunTime, []time.Time{t.RestTime[len(t.RestTime)-1][1], t.EndTime})
+	t.configEndTime = t.EndTime
+	t.configRestTime = t.RestTime
 
-	} else {
-		t.RunTime = append(t.RunTime, []time.Time{t.BeginTime, t.EndTime})
+	// Apply the game time changed by the manager at runtime.
+	if err := reload(); err != nil {
+		log.Fatal("Failed to load the clock adjustments: %v", err)
 	}
 
-	// Calculate the total round.
+	t.snapshot.Store(&Snapshot{
+		BeginTime:       t.BeginTime,
+		EndTime:         t.EndTime,
+		Duration:        t.Duration,
+		TotalRound:      t.TotalRound,
+		NowRound:        -1,
+		RoundRemainTime: -1,
+		Status:          "wait",
+	})
+
+	// Calculate the total time.
 	var totalTime int64
 	for _, dur := range t.RunTime {
 		totalTime += dur[1].Unix() - dur[0].Unix()
 	}
-	t.TotalRound = int(totalTime / 60 / int64(t.Duration))
 
 	log.Trace(locales.T("timer.total_round", gin.H{"round": t.TotalRound}))
 
 	log.Trace(locales.T("timer.total_time", gin.H{"time": int(totalTime / 60)}))
 
+	subscribeOnce.Do(subscribe)
 	go timerProcess()
 }
 
-// timerProcess is the main process of the timer.
+// timerProcess runs the timer, it sleeps until the next round or pause boundary,
+// and publishes the events when the game status or the round changes.
 func timerProcess() {
-	beginTime := t.BeginTime.Unix()
-	endTime := t.EndTime.Unix()
-	lastRoundCalculate := false // A sign for the last round score calculate.
+	ctx := context.Background()
+
+	// Cardinal may have been restarted by accident, score the rounds which have been missed.
+	recoverRounds(momentAt(timeutil.Now()))
 
 	{
 		SetRankListTitle() // Refresh ranking list table header.
 		SetRankList()      // Refresh ranking list.
 	}
 
-	for {
-		nowTime := time.Now().Unix()
-		t.RoundRemainTime = -1
-
-		if nowTime > beginTime && nowTime < endTime {
-			nowRunTimeIndex := -1
-			for index, dur := range t.RunTime {
-				if nowTime > dur[0].Unix() && nowTime < dur[1].Unix() {
-					nowRunTimeIndex = index // Get which time cycle now.
-					break
-				}
-			}
+	// The time may be changed by the manager at runtime, the timer is woken up to reschedule.
+	timeutil.Schedule(func(now time.Time) time.Time {
+		m := momentAt(now)
+		transit(ctx, m)
+		return m.next
+	}, t.wake, nil)
+}
 
-			if nowRunTimeIndex == -1 {
-				// Suspended
-				if t.Status != "pause" {
-					go webhook.Add(webhook.PAUSE_HOOK, nil)
-				}
-				t.Status = "pause"
-			} else {
-				// In progress
-				t.Status = "on"
-				var nowRound int
-				var workTime int64 // Cumulative time until now.
-
-				for index, dur := range t.RunTime {
-					if index < nowRunTimeIndex {
-						workTime += dur[1].Unix() - dur[0].Unix()
-					} else {
-						workTime += nowTime - dur[0].Unix()
-						break
-					}
-				}
-				nowRound = int(math.Ceil(float64(workTime) / float64(t.Duration*60))) // Calculate current round.
-				t.RoundRemainTime = nowRound*int(t.Duration)*60 - int(workTime)       // Calculate the time to next round.
-
-				// Check if it is a new round.
-				if t.NowRound < nowRound {
-					t.NowRound = nowRound
-					if t.NowRound == 1 {
-						// Game start hook
-						go webhook.Add(webhook.BEGIN_HOOK, nil)
-					}
-
-					// New round hook
-					go webhook.Add(webhook.BEGIN_HOOK, t.NowRound)
-
-					// Clean the status of the gameboxes.
-					CleanGameBoxStatus()
-					SetRankList()
-
-					// Calculate scores.
-					// Get the latest score record.
-					latestScoreRound := GetLatestScoreRound()
-
-					// If Cardinal has been restart by unexpected error, get the latest round score and chick if need calculate the scores of previous round.
-					if latestScoreRound < t.NowRound-1 {
-						CalculateRoundScore(t.NowRound - 1)
-					}
-
-					// Auto refresh flag
-					RefreshFlag()
-
-					// Asteroid Unity3D refresh.
-					asteroid.NewRoundAction()
-
-					log.Trace("New round: %d", t.NowRound)
-				}
-			}
+// moment is the state of the timer at a given time.
+type moment struct {
+	at              time.Time
+	status          string
+	round           int // It is zero if no round has started.
+	roundRemainTime int // It is -1 if the game is not in progress.
+	phase           *Phase
+	// beginTime, endTime and totalRound are the game time when the moment is calculated.
+	beginTime  time.Time
+	endTime    time.Time
+	totalRound int
+	// next is the time when the status or the round changes next, it is zero if the game is over.
+	next time.Time
+}
 
-		} else if nowTime < beginTime {
-			// Not started.
-			t.Status = "wait"
-		} else {
-			// Over.
-			// Calculate the score of the last round when the competition is over.
-			if !lastRoundCalculate {
-				lastRoundCalculate = true
-				go CalculateRoundScore(t.TotalRound)
-				// Game over hook
-				go webhook.Add(webhook.END_HOOK, nil)
-				logger.New(logger.IMPORTANT, "system", locales.T("timer.end"))
+// momentAt returns the state of the timer at the given time.
+func momentAt(at time.Time) moment {
+	t.mu.RLock()
+	beginTime, endTime, runTime, totalRound, phases := t.BeginTime, t.EndTime, t.RunTime, t.TotalRound, t.Phases
+	t.mu.RUnlock()
+
+	m := moment{at: at, roundRemainTime: -1, beginTime: beginTime, endTime: endTime, totalRound: totalRound}
+	nowTime := at.Unix()
+	if nowTime < beginTime.Unix() {
+		// Not started.
+		m.status = "wait"
+		m.next = beginTime
+		return m
+	} else if nowTime >= endTime.Unix() {
+		// Over.
+		m.status = "end"
+		m.round = totalRound
+		for _, phase := range phases {
+			if phase.TotalRound != 0 {
+				m.phase = phase
 			}
+		}
+		return m
+	}
 
-			t.Status = "end"
+	// Get which time cycle now, and the cumulative time until now.
+	var workTime int64
+	nowRunTimeIndex := -1
+	for index, dur := range runTime {
+		if nowTime < dur[0].Unix() {
+			// Suspended until the next time cycle.
+			m.next = dur[0]
+			break
+		}
+		if nowTime < dur[1].Unix() {
+			nowRunTimeIndex = index
+			workTime += nowTime - dur[0].Unix()
+			break
 		}
+		workTime += dur[1].Unix() - dur[0].Unix()
+	}
+
+	if nowRunTimeIndex == -1 {
+		// Suspended, the round in progress continues when resumed.
+		m.status = "pause"
+		if workTime > 0 {
+			m.round, _, m.phase = roundAt(phases, workTime, true)
+		}
+		if m.next.IsZero() {
+			m.next = endTime
+		}
+	} else {
+		// In progress
+		m.status = "on"
+		m.round, m.roundRemainTime, m.phase = roundAt(phases, workTime, false)
+
+		// The round ends or the game is suspended, whichever comes first.
+		m.next = runTime[nowRunTimeIndex][1]
+		if roundEndTime := at.Add(time.Duration(m.roundRemainTime) * time.Second); roundEndTime.Before(m.next) {
+			m.next = roundEndTime
+		}
+	}
+	return m
+}
+
+// transit publishes the snapshot of the given moment, and publishes the events of the changes.
+// The snapshot is published before the events, so that the event handlers see the new round.
+func transit(ctx context.Context, m moment) {
+	previous := GetSnapshot()
+	previousRound := previous.NowRound
+	if previousRound < 0 {
+		previousRound = 0
+	}
+	// The round never goes back, even if the game time is changed.
+	if m.round < previousRound {
+		m.round = previousRound
+	}
+
+	nowRound, duration := m.round, t.Duration
+	if nowRound == 0 {
+		nowRound = -1
+	}
+	if m.phase != nil {
+		duration = m.phase.Duration
+	}
+	t.snapshot.Store(&Snapshot{
+		BeginTime:       m.beginTime,
+		EndTime:         m.endTime,
+		Duration:        duration,
+		TotalRound:      m.totalRound,
+		NowRound:        nowRound,
+		RoundRemainTime: m.roundRemainTime,
+		Status:          m.status,
+		Phase:           m.phase,
+	})
 
-		time.Sleep(1 * time.Second)
+	publish := func(typ event.Type, round int) {
+		Events.Publish(ctx, event.Event{Type: typ, Round: uint(round), Time: m.at})
 	}
+
+	switch m.status {
+	case "on", "pause":
+		if previousRound == 0 && m.round == 1 {
+			publish(event.GameStart, m.round)
+		}
+		// Only the rounds started in this process are ended by the events,
+		// the rounds ended before Cardinal started are recovered by `recoverRounds`.
+		for round := previousRound; round != 0 && round < m.round; round++ {
+			publish(event.RoundEnd, round)
+		}
+		if m.round > previousRound {
+			publish(event.RoundStart, m.round)
+		}
+
+		if m.status == "pause" && previous.Status != "pause" {
+			publish(event.Pause, m.round)
+		} else if m.status == "on" && previous.Status == "pause" {
+			publish(event.Resume, m.round)
+		}
+
+	case "end":
+		if previous.Status != "end" {
+			// The last round may be cut off by the end time.
+			for round := previousRound; round != 0 && round <= m.round; round++ {
+				publish(event.RoundEnd, round)
+			}
+			publish(event.GameEnd, m.round)
+		}
+	}
+}
+
+// recoverRounds records the rounds which have passed before the given moment,
+// then calculates the score of the ended rounds which have not been scored in order.
+func recoverRounds(m moment) {
+	endedRound := m.round
+	if m.status != "end" && endedRound != 0 {
+		// The current round is in progress.
+		endedRound--
+	}
+
+	t.mu.RLock()
+	runTime, phases := t.RunTime, t.Phases
+	t.mu.RUnlock()
+
+	recordRounds(endedRound, runTime, phases)
+	scoreRounds()
 }
 
 func checkTimeConfig() {
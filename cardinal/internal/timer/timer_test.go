@@ -0,0 +1,108 @@
+package timer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/event"
+)
+
+// mockTimer replaces the timer with the given one, and returns the function to restore it.
+func mockTimer(mock *timer) func() {
+	previous := t
+	t = mock
+	return func() { t = previous }
+}
+
+func Test_momentAt(t *testing.T) {
+	date := func(hour, min, sec int) time.Time {
+		return time.Date(2021, 10, 3, hour, min, sec, 0, time.Local)
+	}
+
+	// 8:00 - 10:00, rest, 10:30 - 11:30, the rounds are 60 minutes.
+	runTime := [][]time.Time{
+		{date(8, 0, 0), date(10, 0, 0)},
+		{date(10, 30, 0), date(11, 30, 0)},
+	}
+	phases := schedulePhases([]*Phase{{StartTime: date(8, 0, 0), Duration: 60, ScoreMultiplier: 1}}, runTime)
+	defer mockTimer(&timer{BeginTime: date(8, 0, 0), EndTime: date(11, 30, 0), RunTime: runTime, TotalRound: 3, Phases: phases})()
+
+	for _, tc := range []struct {
+		at             time.Time
+		wantStatus     string
+		wantRound      int
+		wantRemainTime int
+		wantNext       time.Time
+	}{
+		{at: date(7, 0, 0), wantStatus: "wait", wantRemainTime: -1, wantNext: date(8, 0, 0)},
+		{at: date(8, 0, 0), wantStatus: "on", wantRound: 1, wantRemainTime: 3600, wantNext: date(9, 0, 0)},
+		{at: date(9, 59, 59), wantStatus: "on", wantRound: 2, wantRemainTime: 1, wantNext: date(10, 0, 0)},
+		// The round in progress is kept when the game is paused.
+		{at: date(10, 0, 0), wantStatus: "pause", wantRound: 2, wantRemainTime: -1, wantNext: date(10, 30, 0)},
+		{at: date(10, 30, 0), wantStatus: "on", wantRound: 3, wantRemainTime: 3600, wantNext: date(11, 30, 0)},
+		{at: date(11, 30, 0), wantStatus: "end", wantRound: 3, wantRemainTime: -1},
+	} {
+		m := momentAt(tc.at)
+		assert.Equal(t, tc.wantStatus, m.status, "at %v", tc.at)
+		assert.Equal(t, tc.wantRound, m.round, "at %v", tc.at)
+		assert.Equal(t, tc.wantRemainTime, m.roundRemainTime, "at %v", tc.at)
+		assert.Equal(t, tc.wantNext, m.next, "at %v", tc.at)
+	}
+}
+
+func Test_transit(t *testing.T) {
+	events, snapshot := Events, GetSnapshot()
+	defer func() {
+		Events = events
+		SetSnapshot(snapshot)
+	}()
+
+	Events = event.NewBus()
+	var got []event.Event
+	Events.Subscribe(func(ctx context.Context, e event.Event) {
+		got = append(got, event.Event{Type: e.Type, Round: e.Round})
+	}, event.GameStart, event.RoundStart, event.RoundEnd, event.Pause, event.Resume, event.GameEnd)
+
+	SetSnapshot(&Snapshot{NowRound: -1, RoundRemainTime: -1, Status: "wait"})
+	for _, m := range []moment{
+		{status: "wait"},
+		{status: "on", round: 1},
+		{status: "on", round: 1},
+		{status: "on", round: 2},
+		{status: "pause", round: 2},
+		{status: "on", round: 3},
+		{status: "on", round: 5},
+		{status: "end", round: 5},
+		{status: "end", round: 5},
+	} {
+		transit(context.Background(), m)
+	}
+
+	want := []event.Event{
+		{Type: event.GameStart, Round: 1},
+		{Type: event.RoundStart, Round: 1},
+		{Type: event.RoundEnd, Round: 1},
+		{Type: event.RoundStart, Round: 2},
+		{Type: event.Pause, Round: 2},
+		{Type: event.RoundEnd, Round: 2},
+		{Type: event.RoundStart, Round: 3},
+		{Type: event.Resume, Round: 3},
+		{Type: event.RoundEnd, Round: 3},
+		{Type: event.RoundEnd, Round: 4},
+		{Type: event.RoundStart, Round: 5},
+		{Type: event.RoundEnd, Round: 5},
+		{Type: event.GameEnd, Round: 5},
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, "end", GetSnapshot().Status)
+	assert.Equal(t, 5, GetSnapshot().NowRound)
+
+	// The rounds ended before the timer process started are not published.
+	got = nil
+	SetSnapshot(&Snapshot{NowRound: -1, RoundRemainTime: -1, Status: "wait"})
+	transit(context.Background(), moment{status: "on", round: 3})
+	assert.Equal(t, []event.Event{{Type: event.RoundStart, Round: 3}}, got)
+}
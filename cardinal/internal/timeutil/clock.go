@@ -0,0 +1,106 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package timeutil
+
+import (
+	"time"
+)
+
+// Clock is the source of the time, the time of the game should be got from it rather than the `time` package,
+// so the game can be run by a simulated clock.
+type Clock interface {
+	// Now returns the current time of the clock.
+	Now() time.Time
+	// NewTimer creates a new timer which fires after the given duration of the clock.
+	NewTimer(d time.Duration) *time.Timer
+	// Sleep pauses the current goroutine for the given duration of the clock.
+	Sleep(d time.Duration)
+}
+
+var clock Clock = realClock{}
+
+// SetClock sets the clock used by Cardinal, it should be called before the game clock starts.
+func SetClock(c Clock) {
+	clock = c
+}
+
+// Now returns the current time of the clock.
+func Now() time.Time {
+	return clock.Now()
+}
+
+// Since returns the clock time elapsed since t.
+func Since(t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
+
+// Until returns the clock duration until t.
+func Until(t time.Time) time.Duration {
+	return t.Sub(clock.Now())
+}
+
+// NewTimer creates a new timer which fires after the given duration of the clock.
+func NewTimer(d time.Duration) *time.Timer {
+	return clock.NewTimer(d)
+}
+
+// Sleep pauses the current goroutine for the given duration of the clock.
+func Sleep(d time.Duration) {
+	clock.Sleep(d)
+}
+
+// realClock is the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) *time.Timer {
+	return time.NewTimer(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// scaledClock is the clock runs at a speed multiplier of the wall clock.
+type scaledClock struct {
+	origin time.Time
+	from   time.Time
+	speed  float64
+}
+
+// NewScaledClock returns a clock which starts from the given time now, and runs at the given speed multiplier.
+// For example, the speed 60 makes a 10-minute round end in 10 seconds.
+func NewScaledClock(from time.Time, speed float64) Clock {
+	return &scaledClock{
+		origin: time.Now(),
+		from:   from,
+		speed:  speed,
+	}
+}
+
+func (c *scaledClock) Now() time.Time {
+	return c.from.Add(c.scale(time.Since(c.origin)))
+}
+
+func (c *scaledClock) NewTimer(d time.Duration) *time.Timer {
+	return time.NewTimer(c.unscale(d))
+}
+
+func (c *scaledClock) Sleep(d time.Duration) {
+	time.Sleep(c.unscale(d))
+}
+
+// scale converts the wall clock duration to the duration of this clock.
+func (c *scaledClock) scale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * c.speed)
+}
+
+// unscale converts the duration of this clock to the wall clock duration.
+func (c *scaledClock) unscale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / c.speed)
+}
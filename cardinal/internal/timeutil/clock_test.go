@@ -0,0 +1,44 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package timeutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaledClock(t *testing.T) {
+	from := time.Date(2021, 10, 3, 12, 0, 0, 0, time.Local)
+	c := NewScaledClock(from, 600).(*scaledClock)
+
+	t.Run("now", func(t *testing.T) {
+		c.origin = time.Now().Add(-time.Second)
+		assert.WithinDuration(t, from.Add(10*time.Minute), c.Now(), time.Minute)
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		assert.Equal(t, 10*time.Minute, c.scale(time.Second))
+		assert.Equal(t, time.Second, c.unscale(10*time.Minute))
+	})
+
+	t.Run("timer", func(t *testing.T) {
+		start := time.Now()
+		timer := c.NewTimer(time.Minute)
+		<-timer.C
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+}
+
+func TestSetClock(t *testing.T) {
+	defer SetClock(realClock{})
+
+	from := time.Date(2021, 10, 3, 12, 0, 0, 0, time.Local)
+	SetClock(NewScaledClock(from, 1))
+	assert.WithinDuration(t, from, Now(), time.Second)
+	assert.Less(t, int64(Since(from)), int64(time.Second))
+	assert.Less(t, int64(Until(from.Add(time.Hour))), int64(time.Hour+time.Second))
+}
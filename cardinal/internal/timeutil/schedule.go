@@ -0,0 +1,40 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package timeutil
+
+import (
+	"time"
+)
+
+// Schedule calls the step with the current time of the clock, then sleeps until the time returned by it,
+// which is the next time the state changes. A zero time means there is no change ahead.
+// The step is called earlier if the wake channel receives, for the schedule may be changed at runtime.
+// It returns when the stop channel receives or is closed.
+func Schedule(step func(now time.Time) (next time.Time), wake, stop <-chan struct{}) {
+	for {
+		next := step(Now())
+
+		var wakeup <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = NewTimer(Until(next))
+			wakeup = timer.C
+		}
+
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wake:
+		case <-wakeup:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package timeutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	wake, stop := make(chan struct{}), make(chan struct{})
+	steps := make(chan time.Time)
+	done := make(chan struct{})
+
+	var count int
+	go func() {
+		Schedule(func(now time.Time) time.Time {
+			count++
+			steps <- now
+			switch count {
+			case 1:
+				// Wake up at the next time.
+				return now.Add(10 * time.Millisecond)
+			default:
+				// Sleep until woken.
+				return time.Time{}
+			}
+		}, wake, stop)
+		close(done)
+	}()
+
+	first := <-steps
+	second := <-steps
+	assert.GreaterOrEqual(t, int64(second.Sub(first)), int64(10*time.Millisecond))
+
+	wake <- struct{}{}
+	<-steps
+
+	close(stop)
+	<-done
+	assert.Equal(t, 3, count)
+}
@@ -14,7 +14,10 @@ const (
 	FLAG_PREFIX_CONF    = "flag_prefix"
 	FLAG_SUFFIX_CONF    = "flag_suffix"
 	ANIMATE_ASTEROID    = "animate_asteroid"
+	ASTEROID_TOKEN      = "asteroid_token"
 	SHOW_OTHERS_GAMEBOX = "show_others_gamebox"
+	SHOW_ATTACKER       = "show_attacker"
+	PUBLIC_ATTACK_STATS = "public_attack_stats"
 	DEFAULT_LANGUAGE    = "default_language"
 
 	BOOLEAN_TRUE  = "true"
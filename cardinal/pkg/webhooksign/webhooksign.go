@@ -0,0 +1,209 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+// Package webhooksign signs the webhook deliveries of Cardinal, and helps the receivers to verify them.
+//
+// Each delivery is sent with the following headers:
+//
+//	X-Cardinal-Delivery:  the unique ID of the delivery, it is kept when the delivery is retried.
+//	X-Cardinal-Timestamp: the unix timestamp in seconds when the delivery is sent.
+//	X-Cardinal-Signature: "sha256=" + hex(HMAC-SHA256(token, timestamp + "." + delivery ID + "." + body)).
+//
+// A receiver should reject the delivery if the signature does not match or the timestamp is not in the
+// tolerance. The delivery whose ID has been handled successfully is a replay, it should be rejected
+// without being handled again. The Verifier does all these checks.
+package webhooksign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderDelivery  = "X-Cardinal-Delivery"
+	HeaderTimestamp = "X-Cardinal-Timestamp"
+	HeaderSignature = "X-Cardinal-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// DefaultTolerance is the default maximum difference between the delivery timestamp and the receiver's time.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingHeader    = errors.New("missing webhook signature header")
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	ErrExpired          = errors.New("webhook timestamp is out of the tolerance")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrReplayed         = errors.New("webhook delivery has been received")
+)
+
+// Sign returns the signature of the delivery body with the given token.
+func Sign(token string, timestamp int64, deliveryID string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(token))
+	_, _ = h.Write([]byte(strconv.FormatInt(timestamp, 10) + "." + deliveryID + "."))
+	_, _ = h.Write(body)
+	return signaturePrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// SetHeaders signs the delivery body and sets the signature headers of the request.
+func SetHeaders(header http.Header, token string, timestamp time.Time, deliveryID string, body []byte) {
+	unix := timestamp.Unix()
+	header.Set(HeaderDelivery, deliveryID)
+	header.Set(HeaderTimestamp, strconv.FormatInt(unix, 10))
+	header.Set(HeaderSignature, Sign(token, unix, deliveryID, body))
+}
+
+// Verifier verifies the webhook deliveries, and remembers the received delivery IDs in the tolerance to reject the replays.
+// It is safe for concurrent use.
+type Verifier struct {
+	token     string
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu sync.Mutex
+	// seen is the delivery IDs which have been handled successfully, with their timestamps.
+	seen map[string]time.Time
+}
+
+// NewVerifier returns a new verifier of the given token.
+// The DefaultTolerance is used if the tolerance is not positive.
+func NewVerifier(token string, tolerance time.Duration) *Verifier {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	return &Verifier{
+		token:     token,
+		tolerance: tolerance,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// Verify verifies the signature headers and the body of a delivery, and checks whether it has been received.
+// The delivery is not remembered until Received is called, so that it can be retried if it fails to be handled.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	deliveryID := header.Get(HeaderDelivery)
+	timestampStr := header.Get(HeaderTimestamp)
+	signature := header.Get(HeaderSignature)
+	if deliveryID == "" || timestampStr == "" || signature == "" {
+		return ErrMissingHeader
+	}
+
+	unix, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	timestamp := time.Unix(unix, 0)
+
+	now := v.now()
+	if diff := now.Sub(timestamp); diff > v.tolerance || diff < -v.tolerance {
+		return ErrExpired
+	}
+
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(v.token, unix, deliveryID, body))) {
+		return ErrInvalidSignature
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// The delivery IDs out of the tolerance can be forgotten, for their timestamps will be rejected.
+	for id, seenAt := range v.seen {
+		if now.Sub(seenAt) > v.tolerance {
+			delete(v.seen, id)
+		}
+	}
+	if _, ok := v.seen[deliveryID]; ok {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// Received remembers the verified delivery as received, the later one with the same delivery ID is rejected
+// as a replay. It should be called after the delivery has been handled successfully.
+func (v *Verifier) Received(header http.Header) {
+	unix, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.seen[header.Get(HeaderDelivery)] = time.Unix(unix, 0)
+}
+
+// VerifyRequest reads the body of the request and verifies the delivery.
+// The body is restored so that it can be read again by the handler.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body")
+	}
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := v.Verify(r.Header, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Middleware returns a HTTP middleware which rejects the unverified deliveries with 401 Unauthorized,
+// and the replayed deliveries with 409 Conflict without calling the handler.
+// The delivery is remembered as received only if the handler responds with a 2xx status code,
+// so that the failed one can be retried by the sender.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.VerifyRequest(r); err != nil {
+			if err == ErrReplayed {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			// The handler writes nothing, the server responds with 200 OK.
+			recorder.status = http.StatusOK
+		}
+		if recorder.status >= 200 && recorder.status < 300 {
+			v.Received(r.Header)
+		}
+	})
+}
+
+// statusRecorder records the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
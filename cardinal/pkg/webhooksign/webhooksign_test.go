@@ -0,0 +1,132 @@
+// Copyright 2021 E99p1ant. All rights reserved.
+// Use of this source code is governed by an AGPL-style
+// license that can be found in the LICENSE file.
+
+package webhooksign
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifier(t *testing.T) {
+	now := time.Date(2021, 10, 3, 12, 0, 0, 0, time.Local)
+	body := []byte(`{"type":"new_round","data":2}`)
+
+	newVerifier := func() *Verifier {
+		v := NewVerifier("token", time.Minute)
+		v.now = func() time.Time { return now }
+		return v
+	}
+	signed := func(token string, at time.Time, deliveryID string) http.Header {
+		header := http.Header{}
+		SetHeaders(header, token, at, deliveryID, body)
+		return header
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.Nil(t, newVerifier().Verify(signed("token", now, "1"), body))
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		header := signed("token", now, "1")
+		header.Del(HeaderSignature)
+		assert.Equal(t, ErrMissingHeader, newVerifier().Verify(header, body))
+	})
+
+	t.Run("invalid timestamp", func(t *testing.T) {
+		header := signed("token", now, "1")
+		header.Set(HeaderTimestamp, "now")
+		assert.Equal(t, ErrInvalidTimestamp, newVerifier().Verify(header, body))
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		assert.Equal(t, ErrInvalidSignature, newVerifier().Verify(signed("wrong", now, "1"), body))
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		assert.Equal(t, ErrInvalidSignature, newVerifier().Verify(signed("token", now, "1"), []byte(`{"type":"new_round","data":3}`)))
+	})
+
+	t.Run("tampered timestamp", func(t *testing.T) {
+		header := signed("token", now.Add(-2*time.Minute), "1")
+		header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+		assert.Equal(t, ErrInvalidSignature, newVerifier().Verify(header, body))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		assert.Equal(t, ErrExpired, newVerifier().Verify(signed("token", now.Add(-2*time.Minute), "1"), body))
+		assert.Equal(t, ErrExpired, newVerifier().Verify(signed("token", now.Add(2*time.Minute), "1"), body))
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		v := newVerifier()
+		// The delivery is not remembered until it has been received.
+		assert.Nil(t, v.Verify(signed("token", now, "1"), body))
+		assert.Nil(t, v.Verify(signed("token", now, "1"), body))
+
+		v.Received(signed("token", now, "1"))
+		assert.Equal(t, ErrReplayed, v.Verify(signed("token", now, "1"), body))
+		assert.Nil(t, v.Verify(signed("token", now, "2"), body))
+
+		// The delivery IDs out of the tolerance are forgotten.
+		now = now.Add(2 * time.Minute)
+		defer func() { now = now.Add(-2 * time.Minute) }()
+		v.Received(signed("token", now, "3"))
+		assert.Nil(t, v.Verify(signed("token", now, "4"), body))
+		assert.Equal(t, 1, len(v.seen))
+	})
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	v := NewVerifier("token", 0)
+	handled := 0
+	failed := true
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled++
+		if failed {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	body := `{"type":"game_begin","data":null}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	SetHeaders(req.Header, "token", time.Now(), "1", []byte(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 1, handled)
+
+	// Retry the failed delivery, it is handled again.
+	failed = false
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	SetHeaders(req.Header, "token", time.Now(), "1", []byte(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 2, handled)
+
+	// Replay the received delivery, it is rejected without being handled again.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	SetHeaders(req.Header, "token", time.Now(), "1", []byte(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 2, handled)
+
+	// Sign with the wrong token.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	SetHeaders(req.Header, "wrong", time.Now(), "2", []byte(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 2, handled)
+}
@@ -0,0 +1,73 @@
+package cardinal_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"Cardinal/internal/dbold"
+)
+
+func Test_exportStandings(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/manager/rank/export", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"standings"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/export?format=csv&exclude=1", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "Pos,TeamID,Team,Score"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/export?format=json", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	// The exported scores include the first bloods and score adjustments, the same as the team scores.
+	var standings struct {
+		Standings []struct {
+			Pos    int
+			TeamID uint
+			Score  float64
+		}
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &standings)
+	assert.Nil(t, err)
+	var teams []dbold.Team
+	dbold.MySQL.Model(&dbold.Team{}).Order("score DESC, id ASC").Find(&teams)
+	assert.Equal(t, len(teams), len(standings.Standings))
+	for i, team := range teams {
+		assert.Equal(t, team.ID, standings.Standings[i].TeamID)
+		assert.InDelta(t, team.Score, standings.Standings[i].Score, 1e-6)
+	}
+
+	// error query
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/export?format=xml", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/export?exclude=a", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// freeze is not set
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/export?scoreboard=frozen", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
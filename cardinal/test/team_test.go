@@ -6,10 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 
+	"Cardinal/internal/conf"
 	"Cardinal/internal/dbold"
 	"Cardinal/internal/game"
 	"Cardinal/internal/healthy"
@@ -620,11 +622,18 @@ func Test_GetFlags(t *testing.T) {
 	assert.Equal(t, 200, w.Code)
 }
 
+// setTimerSnapshot publishes a copy of the current timer snapshot changed by the given function.
+func setTimerSnapshot(change func(snapshot *timer.Snapshot)) {
+	snapshot := *timer.GetSnapshot()
+	change(&snapshot)
+	timer.SetSnapshot(&snapshot)
+}
+
 // Vidar -> e99 web1	flag1
 // Vidar -> e99 pwn1	flag2
 // e99 -> Vidar pwn1	flag3
 func Test_SubmitFlag(t *testing.T) {
-	timer.Get().NowRound = 1
+	setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.NowRound = 1 })
 
 	var flag1 dbold.Flag
 	dbold.MySQL.Model(&dbold.Flag{}).Where(&dbold.Flag{
@@ -651,7 +660,7 @@ func Test_SubmitFlag(t *testing.T) {
 	assert.NotEqual(t, flag3.Flag, "")
 
 	// not begin
-	timer.Get().Status = "wait"
+	setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.Status = "wait" })
 	w := httptest.NewRecorder()
 	jsonData, _ := json.Marshal(map[string]interface{}{
 		"flag": flag1.Flag,
@@ -661,7 +670,7 @@ func Test_SubmitFlag(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, 403, w.Code)
 
-	timer.Get().Status = "on"
+	setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.Status = "on" })
 
 	// empty token
 	w = httptest.NewRecorder()
@@ -703,6 +712,10 @@ func Test_SubmitFlag(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, 403, w.Code)
 
+	var vidarWeb1 dbold.GameBox
+	dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: 1}}).Find(&vidarWeb1)
+	vidarWeb1Score := vidarWeb1.Score
+
 	// success flag1
 	w = httptest.NewRecorder()
 	jsonData, _ = json.Marshal(map[string]string{
@@ -742,12 +755,116 @@ func Test_SubmitFlag(t *testing.T) {
 	req.Header.Set("Authorization", team[0].AccessKey)
 	router.ServeHTTP(w, req)
 	assert.Equal(t, 403, w.Code)
+
+	// Vidar took the first blood of web1 and pwn1, the first blood of pwn1 was taken before E99 captured it.
+	var firstBloods []dbold.FirstBlood
+	dbold.MySQL.Model(&dbold.FirstBlood{}).Order("id").Find(&firstBloods)
+	assert.Equal(t, 2, len(firstBloods))
+	assert.Equal(t, uint(1), firstBloods[0].AttackerTeamID)
+	assert.Equal(t, uint(1), firstBloods[0].ChallengeID)
+	assert.Equal(t, 50.0, firstBloods[0].Score)
+	assert.Equal(t, uint(1), firstBloods[1].AttackerTeamID)
+	assert.Equal(t, uint(3), firstBloods[1].ChallengeID)
+
+	// The bonus is added to Vidar's web1 gamebox.
+	dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: 1}}).Find(&vidarWeb1)
+	assert.Equal(t, vidarWeb1Score+50, vidarWeb1.Score)
+
+	// The first blood is shown in the ranking list.
+	rankList := game.GetManagerRankList()
+	assert.Equal(t, "Vidar", rankList[0].TeamName)
+	assert.True(t, rankList[0].GameBoxStatus.([]*game.GameBoxInfo)[0].FirstBlood)
+}
+
+func Test_GetAttackMatrix(t *testing.T) {
+	var matrix struct {
+		Error int               `json:"error"`
+		Data  game.AttackMatrix `json:"data"`
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/manager/attacks/matrix?startRound=1&endRound=3", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &matrix))
+
+	// Vidar captured two flags of E99, and E99 captured one flag of Vidar.
+	assert.Equal(t, 2, len(matrix.Data.Teams))
+	assert.Equal(t, "Vidar", matrix.Data.Teams[0].Name)
+	assert.Equal(t, [][]int{{0, 2}, {1, 0}}, matrix.Data.Matrix)
+
+	// Only the captures of the given challenge are counted.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/attacks/matrix?challenge=1", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &matrix))
+	assert.Equal(t, [][]int{{0, 1}, {0, 0}}, matrix.Data.Matrix)
+
+	// error query
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/attacks/matrix?challenge=a", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// not public
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/attacks/matrix", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 403, w.Code)
+}
+
+func Test_GetChallengeStats(t *testing.T) {
+	var stats struct {
+		Error int                   `json:"error"`
+		Data  []game.ChallengeStats `json:"data"`
+	}
+
+	// The end round is limited to the current round.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/manager/attacks/challenges?startRound=1&endRound=3", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+
+	assert.Equal(t, 2, len(stats.Data))
+	// web1: Vidar -> E99
+	assert.Equal(t, uint(1), stats.Data[0].ChallengeID)
+	assert.Equal(t, uint(1), stats.Data[0].FirstCapture.TeamID)
+	assert.Equal(t, uint(2), stats.Data[0].FirstCapture.VictimID)
+	assert.Equal(t, 1, len(stats.Data[0].Rounds))
+	assert.Equal(t, 1, stats.Data[0].Rounds[0].Round)
+	assert.Equal(t, 1, stats.Data[0].Rounds[0].Attackers)
+	assert.Equal(t, 1, stats.Data[0].Rounds[0].Victims)
+	// pwn1: Vidar -> E99, E99 -> Vidar
+	assert.Equal(t, uint(3), stats.Data[1].ChallengeID)
+	assert.Equal(t, uint(1), stats.Data[1].FirstCapture.TeamID)
+	assert.Equal(t, 1, len(stats.Data[1].Rounds))
+	assert.Equal(t, 2, stats.Data[1].Rounds[0].Attackers)
+	assert.Equal(t, 2, stats.Data[1].Rounds[0].Victims)
+
+	// error query
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/attacks/challenges?endRound=-1", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// not public
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/attacks/challenges", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 403, w.Code)
 }
 
 // e99 pwn1 ID:4
 func Test_CheckDown(t *testing.T) {
 	// not begin
-	timer.Get().Status = "wait"
+	setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.Status = "wait" })
 	w := httptest.NewRecorder()
 	jsonData, _ := json.Marshal(map[string]interface{}{
 		"GameBoxID": 4,
@@ -757,7 +874,7 @@ func Test_CheckDown(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, 403, w.Code)
 
-	timer.Get().Status = "on"
+	setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.Status = "on" })
 	// payload error
 	w = httptest.NewRecorder()
 	jsonData, _ = json.Marshal(map[string]interface{}{
@@ -800,25 +917,206 @@ func Test_CheckDown(t *testing.T) {
 }
 
 func Test_CalculateRoundScore(t *testing.T) {
-
-	game.CalculateRoundScore(1)
+	err := game.CalculateRoundScore(1)
+	assert.Nil(t, err)
+	// The round has been scored, calculating it again does nothing.
+	err = game.CalculateRoundScore(1)
+	assert.Nil(t, err)
+
+	var round dbold.Round
+	dbold.MySQL.Model(&dbold.Round{}).Where(&dbold.Round{Round: 1}).Find(&round)
+	assert.NotNil(t, round.ScoredAt)
 	// Check team score
 	var vidar dbold.Team
 	dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: 1}}).Find(&vidar)
 	var e99 dbold.Team
 	dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: 2}}).Find(&e99)
-	assert.Equal(t, 2020.0, vidar.Score)
+	// Vidar took the first blood of web1 and pwn1.
+	assert.Equal(t, 2120.0, vidar.Score)
 	assert.Equal(t, 1980.0, e99.Score)
 
 	// Check gamebox score
 	var gameboxes []dbold.GameBox
 	dbold.MySQL.Model(&dbold.GameBox{}).Order("`id` ASC").Find(&gameboxes)
-	assert.Equal(t, 1010.0, gameboxes[0].Score)
+	assert.Equal(t, 1060.0, gameboxes[0].Score)
 	assert.Equal(t, 990.0, gameboxes[1].Score)
-	assert.Equal(t, 1010.0, gameboxes[2].Score)
+	assert.Equal(t, 1060.0, gameboxes[2].Score)
 	assert.Equal(t, 990.0, gameboxes[3].Score)
 }
 
+func Test_GetScoreReport(t *testing.T) {
+	var report struct {
+		Error int                  `json:"error"`
+		Data  *healthy.ScoreReport `json:"data"`
+	}
+
+	// the latest report of round 1
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/manager/score/report", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, report.Data.Round)
+	assert.Equal(t, 0, len(report.Data.Violations))
+
+	// break the gamebox score
+	dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: 1}}).Update("score", 2000)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/score/report?round=1", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	report.Data = nil
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(report.Data.Violations))
+	assert.Equal(t, healthy.ScoreViolationGameBoxScore, report.Data.Violations[0].Kind)
+	assert.Equal(t, uint(1), report.Data.Violations[0].GameBoxID)
+	assert.Equal(t, 1060.0, report.Data.Violations[0].Expected)
+	dbold.MySQL.Model(&dbold.GameBox{}).Where(&dbold.GameBox{Model: gorm.Model{ID: 1}}).Update("score", 1060)
+
+	// error query
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/score/report?round=a", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func Test_GetRankHistory(t *testing.T) {
+	var history struct {
+		Error int                 `json:"error"`
+		Data  []*game.TeamHistory `json:"data"`
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/manager/rank/history", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	err := json.Unmarshal(w.Body.Bytes(), &history)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(history.Data))
+	assert.Equal(t, "Vidar", history.Data[0].TeamName)
+	assert.Equal(t, 1, len(history.Data[0].Points))
+	assert.Equal(t, 1, history.Data[0].Points[0].Round)
+	assert.Equal(t, 1, history.Data[0].Points[0].Rank)
+	assert.Equal(t, 2120.0, history.Data[0].Points[0].Score)
+	assert.Equal(t, 2, len(history.Data[0].Points[0].GameBoxes))
+	assert.Equal(t, 2, history.Data[1].Points[0].Rank)
+	assert.Equal(t, 1980.0, history.Data[1].Points[0].Score)
+
+	// only one team, without the gamebox scores
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/team/rank/history?teamID=2", nil)
+	req.Header.Set("Authorization", team[0].Token)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	history.Data = nil
+	err = json.Unmarshal(w.Body.Bytes(), &history)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(history.Data))
+	assert.Equal(t, uint(2), history.Data[0].TeamID)
+	assert.Equal(t, 1, len(history.Data[0].Points))
+	assert.Nil(t, history.Data[0].Points[0].GameBoxes)
+
+	// rounds out of range
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/history?startRound=2", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	history.Data = nil
+	err = json.Unmarshal(w.Body.Bytes(), &history)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(history.Data[0].Points))
+
+	// error query
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/rank/history?endRound=a", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func Test_ScoreAdjustment(t *testing.T) {
+	// target empty
+	w := httptest.NewRecorder()
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"Score":  100,
+		"Reason": "Bonus",
+	})
+	req, _ := http.NewRequest("POST", "/api/manager/score/adjustment", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// gamebox not found
+	w = httptest.NewRecorder()
+	jsonData, _ = json.Marshal(map[string]interface{}{
+		"GameBoxID": 233,
+		"Score":     100,
+		"Reason":    "Bonus",
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/score/adjustment", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	// award the team e99
+	w = httptest.NewRecorder()
+	jsonData, _ = json.Marshal(map[string]interface{}{
+		"TeamID": 2,
+		"Score":  100,
+		"Reason": "Bonus",
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/score/adjustment", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var adjustment struct {
+		Data dbold.ScoreAdjustment `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &adjustment)
+	assert.Equal(t, nil, err)
+
+	var e99 dbold.Team
+	dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: 2}}).Find(&e99)
+	assert.Equal(t, 2080.0, e99.Score)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/score/adjustments?teamID=2", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var adjustments struct {
+		Data []dbold.ScoreAdjustment `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &adjustments)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(adjustments.Data))
+
+	// revert
+	w = httptest.NewRecorder()
+	jsonData, _ = json.Marshal(map[string]interface{}{"ID": adjustment.Data.ID})
+	req, _ = http.NewRequest("POST", "/api/manager/score/adjustment/revert", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	dbold.MySQL.Model(&dbold.Team{}).Where(&dbold.Team{Model: gorm.Model{ID: 2}}).Find(&e99)
+	assert.Equal(t, 1980.0, e99.Score)
+
+	// repeat revert
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/manager/score/adjustment/revert", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
 // Healthy check
 func Test_PreviousRoundScore(t *testing.T) {
 	assert.Equal(t, healthy.PreviousRoundScore(), float64(0))
@@ -864,3 +1162,61 @@ func Test_GetManagerRankList(t *testing.T) {
 func Test_GetRankListTitle(t *testing.T) {
 	assert.Equal(t, len(game.GetRankList()), 2)
 }
+
+func Test_GetDelayedRankList(t *testing.T) {
+	conf.Game.RankDelayRound = 1
+	// The ranking list of round 2 is not in memory, it should be rebuilt from the rank history of round 1.
+	setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.NowRound = 3 })
+	defer func() {
+		conf.Game.RankDelayRound = 0
+		setTimerSnapshot(func(snapshot *timer.Snapshot) { snapshot.NowRound = 1 })
+		game.SetRankList()
+	}()
+
+	game.SetRankList()
+	rankList := game.GetRankList()
+	assert.Equal(t, 2, len(rankList))
+	assert.Equal(t, "Vidar", rankList[0].TeamName)
+	assert.Equal(t, 2120.0, rankList[0].Score)
+	assert.Equal(t, "E99", rankList[1].TeamName)
+	assert.Equal(t, 1980.0, rankList[1].Score)
+
+	// There is no action in round 2, only the first bloods are kept.
+	gameBoxStatuses := rankList[0].GameBoxStatus.([]*game.GameBoxStatus)
+	assert.Equal(t, 2, len(gameBoxStatuses))
+	assert.Equal(t, &game.GameBoxStatus{FirstBlood: true}, gameBoxStatuses[0])
+	assert.Equal(t, &game.GameBoxStatus{FirstBlood: true}, gameBoxStatuses[1])
+
+	// The live ranking list for manager is not delayed.
+	assert.Equal(t, 2, len(game.GetManagerRankList()))
+}
+
+func Test_GetRounds(t *testing.T) {
+	var rounds struct {
+		Error int `json:"error"`
+		Data  []struct {
+			Round     int
+			StartedAt *time.Time
+			EndedAt   *time.Time
+			ScoredAt  *time.Time
+		} `json:"data"`
+	}
+
+	// The first round is recorded by the timer when it starts.
+	assert.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/manager/clock/rounds", nil)
+		req.Header.Set("Authorization", managerToken)
+		router.ServeHTTP(w, req)
+		if w.Code != 200 || json.Unmarshal(w.Body.Bytes(), &rounds) != nil {
+			return false
+		}
+		return len(rounds.Data) != 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	assert.Equal(t, 1, len(rounds.Data))
+	assert.Equal(t, 1, rounds.Data[0].Round)
+	assert.NotNil(t, rounds.Data[0].StartedAt)
+	assert.Nil(t, rounds.Data[0].EndedAt)
+	assert.Nil(t, rounds.Data[0].ScoredAt)
+}
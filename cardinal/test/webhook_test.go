@@ -83,6 +83,33 @@ func Test_newWebHook(t *testing.T) {
 	assert.Equal(t, 200, w.Code)
 }
 
+func Test_webHookFormat(t *testing.T) {
+	// format error
+	w := httptest.NewRecorder()
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"URL":    "https://cardinal.ink",
+		"Type":   "any",
+		"Format": "telegram",
+	})
+	req, _ := http.NewRequest("POST", "/api/manager/webhook", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// template error
+	w = httptest.NewRecorder()
+	jsonData, _ = json.Marshal(map[string]interface{}{
+		"URL":      "https://cardinal.ink",
+		"Type":     "any",
+		"Format":   "template",
+		"Template": "{{ .Message ",
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/webhook", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
 func Test_editWebHook(t *testing.T) {
 	// empty payload
 	w := httptest.NewRecorder()
@@ -176,6 +203,48 @@ func Test_editWebHook(t *testing.T) {
 	assert.Equal(t, 200, w.Code)
 }
 
+func Test_testWebHook(t *testing.T) {
+	// error payload
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/manager/webhook/test", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// not found
+	w = httptest.NewRecorder()
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"ID": 2333,
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/webhook/test", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	// type error
+	w = httptest.NewRecorder()
+	jsonData, _ = json.Marshal(map[string]interface{}{
+		"ID":   1,
+		"Type": "asdadasdasda",
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/webhook/test", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// preview
+	w = httptest.NewRecorder()
+	jsonData, _ = json.Marshal(map[string]interface{}{
+		"ID":     1,
+		"Type":   "new_round",
+		"DryRun": true,
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/webhook/test", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
+
 func Test_deleteWebHook(t *testing.T) {
 	// missing param
 	w := httptest.NewRecorder()
@@ -206,3 +275,42 @@ func Test_deleteWebHook(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, 200, w.Code)
 }
+
+func Test_webHookDeliveries(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/manager/webhook/deliveries", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	// param type error
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/webhook/deliveries?page=aaa", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// not found
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/manager/webhook/delivery?id=2333", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	// redeliver error payload
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/manager/webhook/delivery/redeliver", nil)
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// redeliver not found
+	w = httptest.NewRecorder()
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"ID": 2333,
+	})
+	req, _ = http.NewRequest("POST", "/api/manager/webhook/delivery/redeliver", bytes.NewBuffer(jsonData))
+	req.Header.Set("Authorization", managerToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}